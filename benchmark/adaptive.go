@@ -0,0 +1,109 @@
+package benchmark
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// Gains of the PID controller. The error is normalized by the target so the
+// same gains work for targets of any size.
+const (
+	pidProportional = 0.5
+	pidIntegral     = 0.1
+	pidDerivative   = 0.1
+)
+
+// intervalLatencies collects the response times of the requests completed
+// since the last adjustment. It is not a rolling window: every adjustment
+// looks at its own interval only, so the controller reacts to the latest load.
+type intervalLatencies struct {
+	mu            sync.Mutex
+	responseTimes []time.Duration
+}
+
+// add records the response time of a measured, successful request. Warm-up
+// requests are left out, and so are failures, which are often fast and would
+// pull the p95 down and let the controller add load to a failing server.
+func (w *intervalLatencies) add(result metrics.RequestResult) {
+	if result.Warmup || metrics.ClassifyError(result) != "" {
+		return
+	}
+	w.mu.Lock()
+	w.responseTimes = append(w.responseTimes, result.ResponseTime)
+	w.mu.Unlock()
+}
+
+// p95 returns the 95th percentile of the interval and starts the next one
+func (w *intervalLatencies) p95() (time.Duration, bool) {
+	w.mu.Lock()
+	responseTimes := w.responseTimes
+	w.responseTimes = nil
+	w.mu.Unlock()
+
+	if len(responseTimes) == 0 {
+		return 0, false
+	}
+	sort.Slice(responseTimes, func(i, j int) bool { return responseTimes[i] < responseTimes[j] })
+	return metrics.Percentile(responseTimes, 95), true
+}
+
+// concurrencyController adjusts the concurrency limit to keep the p95 of each interval at the target
+type concurrencyController struct {
+	mode           string
+	target         time.Duration
+	maxConcurrency int
+
+	// PID state
+	integral  float64
+	lastError float64
+}
+
+// next returns the new concurrency limit for the observed p95
+func (c *concurrencyController) next(limit int, p95 time.Duration) int {
+	var next float64
+	switch c.mode {
+	case "pid":
+		// Positive error means there is latency budget left
+		err := float64(c.target-p95) / float64(c.target)
+		c.integral = math.Max(-1, math.Min(1, c.integral+err))
+		output := pidProportional*err + pidIntegral*c.integral + pidDerivative*(err-c.lastError)
+		c.lastError = err
+		next = math.Round(float64(limit) * (1 + output))
+	default:
+		// Additive increase, multiplicative decrease
+		if p95 <= c.target {
+			next = float64(limit + 1)
+		} else {
+			next = math.Floor(float64(limit) / 2)
+		}
+	}
+
+	if next < 1 {
+		next = 1
+	}
+	if next > float64(c.maxConcurrency) {
+		next = float64(c.maxConcurrency)
+	}
+	return int(next)
+}
+
+// run adjusts the limiter on every interval until done is closed
+func (c *concurrencyController) run(lim *limiter, latencies *intervalLatencies, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if p95, ok := latencies.p95(); ok {
+				lim.setLimit(c.next(lim.currentLimit(), p95))
+			}
+		case <-done:
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package benchmark
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+func TestConcurrencyControllerNext(t *testing.T) {
+	type adjustment struct {
+		limit int
+		p95   time.Duration
+		want  int
+	}
+	tests := []struct {
+		name        string
+		mode        string
+		adjustments []adjustment // Applied in turn to the same controller
+	}{
+		{
+			name:        "aimd increases under the target",
+			mode:        "aimd",
+			adjustments: []adjustment{{10, 80 * time.Millisecond, 11}, {11, 100 * time.Millisecond, 12}},
+		},
+		{
+			name:        "aimd halves over the target",
+			mode:        "aimd",
+			adjustments: []adjustment{{10, 150 * time.Millisecond, 5}, {5, 150 * time.Millisecond, 2}},
+		},
+		{
+			name:        "aimd clamps to the bounds",
+			mode:        "aimd",
+			adjustments: []adjustment{{100, 50 * time.Millisecond, 100}, {1, 150 * time.Millisecond, 1}},
+		},
+		{
+			name: "pid grows with the budget left and holds at the target",
+			mode: "pid",
+			// Error 0.5: 0.5*0.5 + 0.1*0.5 + 0.1*0.5 = 0.35, then the derivative cancels the integral
+			adjustments: []adjustment{{40, 50 * time.Millisecond, 54}, {54, 100 * time.Millisecond, 54}},
+		},
+		{
+			name: "pid backs off over the target",
+			mode: "pid",
+			// Error -1: -0.5 - 0.1 - 0.1 = -0.7
+			adjustments: []adjustment{{50, 200 * time.Millisecond, 15}},
+		},
+		{
+			name:        "pid clamps to the bounds",
+			mode:        "pid",
+			adjustments: []adjustment{{90, 0, 100}, {3, 10 * time.Second, 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			controller := &concurrencyController{mode: tt.mode, target: 100 * time.Millisecond, maxConcurrency: 100}
+			for i, a := range tt.adjustments {
+				if got := controller.next(a.limit, a.p95); got != a.want {
+					t.Errorf("adjustment %d: next(%d, %s) = %d, want %d", i, a.limit, a.p95, got, a.want)
+				}
+			}
+		})
+	}
+}
+
+func TestIntervalLatenciesP95(t *testing.T) {
+	latencies := &intervalLatencies{}
+	if _, ok := latencies.p95(); ok {
+		t.Errorf("p95() of an empty interval reported a value")
+	}
+
+	// Added out of order, the interval sorts them
+	for i := 100; i >= 1; i-- {
+		latencies.add(metrics.RequestResult{StatusCode: 200, ResponseTime: time.Duration(i) * time.Millisecond})
+	}
+	p95, ok := latencies.p95()
+	if !ok || p95 != 95*time.Millisecond {
+		t.Errorf("p95() = %s %v, want 95ms true", p95, ok)
+	}
+
+	// Each adjustment only looks at the responses since the previous one
+	if _, ok := latencies.p95(); ok {
+		t.Errorf("p95() did not start a new interval")
+	}
+	latencies.add(metrics.RequestResult{StatusCode: 200, ResponseTime: 7 * time.Millisecond})
+	if p95, _ := latencies.p95(); p95 != 7*time.Millisecond {
+		t.Errorf("p95() = %s, want 7ms", p95)
+	}
+}
+
+func TestIntervalLatenciesAdd(t *testing.T) {
+	tests := []struct {
+		name   string
+		result metrics.RequestResult
+		want   bool
+	}{
+		{name: "measured success", result: metrics.RequestResult{StatusCode: 200, ResponseTime: time.Millisecond}, want: true},
+		{name: "warm-up", result: metrics.RequestResult{StatusCode: 200, ResponseTime: time.Millisecond, Warmup: true}},
+		{name: "error status", result: metrics.RequestResult{StatusCode: 503, ResponseTime: time.Millisecond}},
+		{name: "connection error", result: metrics.RequestResult{Error: fmt.Errorf("connection refused"), ResponseTime: time.Millisecond}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			latencies := &intervalLatencies{}
+			latencies.add(tt.result)
+			if _, ok := latencies.p95(); ok != tt.want {
+				t.Errorf("add() counted the result = %v, want %v", ok, tt.want)
+			}
+		})
+	}
+}
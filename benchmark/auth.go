@@ -0,0 +1,35 @@
+package benchmark
+
+import (
+	"fmt"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// authenticate fetches the OAuth2 token, if any, before the first request
+func authenticate(config *BenchmarkConfig, client *httpclient.Client) error {
+	if config.Client.OAuth2TokenURL == "" {
+		return nil
+	}
+	if err := client.Authenticate(); err != nil {
+		return fmt.Errorf("error fetching OAuth2 token: %v", err)
+	}
+	return nil
+}
+
+// tokenFetchResults returns the requests the client made to the OAuth2 token
+// endpoint as results tagged to be reported apart from the benchmark requests
+func tokenFetchResults(client *httpclient.Client) []metrics.RequestResult {
+	var results []metrics.RequestResult
+	for _, fetch := range client.TokenFetches() {
+		results = append(results, metrics.RequestResult{
+			StatusCode:   fetch.StatusCode,
+			ResponseTime: fetch.ResponseTime,
+			StartTime:    fetch.StartTime,
+			Error:        fetch.Error,
+			TokenFetch:   true,
+		})
+	}
+	return results
+}
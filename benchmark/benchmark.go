@@ -1,13 +1,15 @@
 package benchmark
 
 import (
-	"fmt"
-	"io"
+	"context"
+	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/komuvill/api_benchmarker/httpclient"
 	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/workload"
 )
 
 type BenchmarkConfig struct {
@@ -17,83 +19,318 @@ type BenchmarkConfig struct {
 	Concurrency int
 	Duration    int
 	Body        string
+	ContentType string                 // Content type of Body, application/json when empty
+	Form        []httpclient.FormField // URL-encoded form body instead of Body
+	Multipart   []httpclient.FormField // multipart/form-data body instead of Body
+	BodySize    int64                  // Size of a generated body instead of Body, streamed rather than held in memory
+	BodyPattern string                 // Bytes of the generated body, "zero" or "random"
+	Client      httpclient.Options     // Transport settings such as TLS
+	Rate        int                    // Requests started per second, 0 for as fast as concurrency allows
+
+	// Report every redirect followed as a separate timed result instead of
+	// folding it into the response time of the request
+	RedirectHops bool
+
+	// Several targets, e.g. every instance of a service, share the requests
+	// instead of URL when set. Distribution is "round-robin", "random" or "weighted".
+	Targets      []Target
+	Distribution string
+
+	// Endpoint mix. When set, every request is sampled from the endpoints by
+	// weight and sent to the endpoint path relative to the URL or target.
+	// Method and Body are not used.
+	Workload []workload.Endpoint
+
+	// Traffic pattern on top of the rate. Arrival is "uniform" or "poisson".
+	Arrival string
+	Burst   BurstProfile
+
+	// Adaptive concurrency. When a mode ("aimd" or "pid") is set, Concurrency is
+	// only the starting point and is adjusted to keep the p95 of every
+	// AdjustInterval at TargetP95.
+	AdaptiveMode   string
+	TargetP95      time.Duration
+	MaxConcurrency int
+	AdjustInterval time.Duration
+
+	// Warm-up phase. Requests sent during warm-up drive load like any other
+	// request but are tagged so they are left out of the aggregate metrics.
+	WarmupDuration time.Duration
+	WarmupRequests int
+	ReportWarmup   bool // Show warm-up results in the report
 }
 
-func RunBenchmark(config *BenchmarkConfig) []metrics.RequestResult {
-	fmt.Printf("Benchmarking %s with %s method, %d requests, %d concurrent requests, for %d seconds\n", config.URL, config.Method, config.Requests, config.Concurrency, config.Duration)
+// RunBenchmark sends the requests of the config over HTTP, printing what is
+// benchmarked, and returns every result.
+//
+// Deprecated: Use NewRunner and Run, which also take a context and report the
+// metrics and progress of the run.
+func RunBenchmark(config *BenchmarkConfig) ([]metrics.RequestResult, error) {
+	runner, err := NewRunner(Options{Config: *config, Log: os.Stdout})
+	if err != nil {
+		return nil, err
+	}
+	result, err := runner.Run(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return result.Results, nil
+}
+
+// runHTTP sends the requests of the config, or of its workload, over HTTP
+func runHTTP(r *run, config *BenchmarkConfig) ([]metrics.RequestResult, error) {
+	executor, err := NewHTTPExecutor(config)
+	if err != nil {
+		return nil, err
+	}
+	r.logToken(config)
+
+	r.logf("Benchmarking %s with %s method, %d requests, %d concurrent requests, for %d seconds\n", describeTargets(config), config.Method, config.Requests, config.Concurrency, config.Duration)
+	if len(config.Workload) > 0 {
+		r.logf("Sampling requests from %d endpoints\n", len(config.Workload))
+	}
+	r.logSchedule(config, "requests")
 
 	results := make(chan metrics.RequestResult, config.Requests)
 
-	go startWorkers(config, results)
+	go startWorkers(r.ctx, config, executor, results)
 
-	allResults := collectResults(results)
-	return allResults
+	allResults := r.collect(results)
+	return append(allResults, executor.TokenFetches()...), nil
 }
 
-func startWorkers(config *BenchmarkConfig, results chan<- metrics.RequestResult) {
+// startWorkers hands the work to the executor with the pacing, concurrency,
+// targets and warm-up of the config until the requests are made, the time is
+// up or the context is done, and closes the results once all are in.
+func startWorkers(ctx context.Context, config *BenchmarkConfig, executor Executor, results chan<- metrics.RequestResult) {
 	var wg sync.WaitGroup
-	concurrencySemaphore := make(chan struct{}, config.Concurrency)
-	testDuration := time.Duration(config.Duration) * time.Second
-	timer := time.NewTimer(testDuration)
+	concurrencyLimiter := newLimiter(config.Concurrency)
+	sampler := newEndpointSampler(config)
 
-	for i := 0; i < config.Requests; i++ {
-		wg.Add(1)
-		go func(i int) {
-			defer wg.Done()
-			// Create a new reader for each request inside the goroutine
-			var requestBody io.Reader
-			var err error
-			if config.Body != "" {
-				requestBody, _, err = httpclient.GetRequestBody(config.Body)
-				if err != nil {
-					results <- metrics.RequestResult{
-						RequestID:    i,
-						Response:     "Failed to construct request body",
-						StatusCode:   0,
-						ResponseTime: 0,
-						Error:        err,
-					}
-					return
-				}
-			}
+	// The warm-up phase is added on top of the measured test duration and request count
+	runStart := time.Now()
+	warmupEnd := runStart.Add(config.WarmupDuration)
+	testDuration := config.WarmupDuration + time.Duration(config.Duration)*time.Second
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() {
+		// Stop making new requests once the time is up or the context is done
+		stopOnce.Do(func() {
+			close(done)
+			concurrencyLimiter.close()
+		})
+	}
+	timer := time.AfterFunc(testDuration, stop)
+	defer timer.Stop()
+	go func() {
+		select {
+		case <-ctx.Done():
+			stop()
+		case <-done:
+		}
+	}()
+	defer stop()
 
+	// In adaptive mode a controller keeps adjusting the concurrency limit
+	var latencies *intervalLatencies
+	if config.AdaptiveMode != "" {
+		latencies = &intervalLatencies{}
+		controller := &concurrencyController{
+			mode:           config.AdaptiveMode,
+			target:         config.TargetP95,
+			maxConcurrency: config.MaxConcurrency,
+		}
+		stopController := make(chan struct{})
+		defer close(stopController)
+		go controller.run(concurrencyLimiter, latencies, config.AdjustInterval, stopController)
+	}
+
+	// With a rate the pacer decides when each request is started
+	var requestPacer *pacer
+	if config.Rate > 0 {
+		requestPacer = newPacer(config)
+	}
+	nextStart := runStart
+
+	picker := newTargetPicker(config)
+
+	measured := 0
+dispatch:
+	for i := 0; measured < config.Requests; i++ {
+		if requestPacer != nil {
+			pause := time.NewTimer(time.Until(nextStart))
 			select {
-			case concurrencySemaphore <- struct{}{}:
-				// This blocks if concurrency limit is reached
-				startTime := time.Now()
-				// Perform the HTTP request here and capture the result...
-				responseBody, statusCode, err := httpclient.HttpRequest(config.Method, config.URL, requestBody)
-				responseTime := time.Since(startTime)
-
-				// Send the result to the results channel
-				results <- metrics.RequestResult{
-					RequestID:    i,
-					Response:     responseBody,
-					StatusCode:   statusCode,
-					ResponseTime: responseTime,
-					Error:        err,
-				}
-
-				// Release the concurrency semaphore
-				<-concurrencySemaphore
-			case <-timer.C:
-				// If the timer has expired, stop making new requests
+			case <-pause.C:
+			case <-done:
+				pause.Stop()
+				break dispatch
+			}
+			nextStart = nextStart.Add(requestPacer.interval(nextStart.Sub(runStart)))
+		}
+
+		// This blocks if concurrency limit is reached
+		if !concurrencyLimiter.acquire() {
+			break dispatch
+		}
+
+		warmup := i < config.WarmupRequests || time.Now().Before(warmupEnd)
+		if !warmup {
+			measured++
+		}
+		url := picker.pick()
+		endpoint := -1
+		if sampler != nil {
+			endpoint = sampler.pick()
+		}
+
+		wg.Add(1)
+		go func(i int, url string, endpoint int, warmup bool, concurrency int) {
+			defer wg.Done()
+			result := executor.Execute(ctx, Work{ID: i, URL: url, Endpoint: endpoint})
+			if result.Error != nil && ctx.Err() != nil {
+				// The request was cut short by the end of the context
+				concurrencyLimiter.release()
 				return
 			}
-		}(i)
+			if picker.multiple() {
+				result.Target = url
+			}
+			result.Warmup = warmup
+			result.Concurrency = concurrency
+			if latencies != nil {
+				latencies.add(result)
+			}
+			sendResult(config, results, result)
+
+			// Release the concurrency slot
+			concurrencyLimiter.release()
+		}(i, url, endpoint, warmup, concurrencyLimiter.currentLimit())
 	}
 
 	wg.Wait()
 	close(results)
-	timer.Stop()
 }
 
-func collectResults(results <-chan metrics.RequestResult) []metrics.RequestResult {
-	var allResults []metrics.RequestResult
+// sendResult sends the result, classifying its error unless the executor did,
+// and split into its redirect hops if the config asks for it
+func sendResult(config *BenchmarkConfig, results chan<- metrics.RequestResult, result metrics.RequestResult) {
+	result.ErrorClass = metrics.ClassifyError(result)
+	if !config.RedirectHops {
+		results <- result
+		return
+	}
+	for _, hop := range splitRedirectHops(result) {
+		results <- hop
+	}
+}
+
+// bodyFunc creates a new request body along with a cleanup function
+type bodyFunc func() (httpclient.Body, func(), error)
 
-	for result := range results {
-		allResults = append(allResults, result)
+// configBody returns the body given in the config, or nil if there is none
+func configBody(config *BenchmarkConfig) bodyFunc {
+	switch {
+	case config.BodySize > 0:
+		return func() (httpclient.Body, func(), error) {
+			body := httpclient.NewSyntheticBody(config.BodySize, config.BodyPattern)
+			if config.ContentType != "" {
+				body.ContentType = config.ContentType
+			}
+			return body, func() {}, nil
+		}
+	case len(config.Multipart) > 0:
+		return func() (httpclient.Body, func(), error) {
+			return httpclient.NewMultipartBody(config.Multipart)
+		}
+	case len(config.Form) > 0:
+		return func() (httpclient.Body, func(), error) {
+			return httpclient.NewFormBody(config.Form), func() {}, nil
+		}
+	case config.Body != "":
+		return func() (httpclient.Body, func(), error) {
+			body, cleanup, err := httpclient.GetBody(config.Body)
+			body.ContentType = config.ContentType
+			return body, cleanup, err
+		}
 	}
+	return nil
+}
 
-	return allResults
+// performRequest sends a single request and captures its result.
+func performRequest(ctx context.Context, client *httpclient.Client, i int, method, url string, header http.Header, body bodyFunc) metrics.RequestResult {
+	// Create a new body for each request
+	var requestBody httpclient.Body
+	var err error
+	if body != nil {
+		var cleanup func()
+		requestBody, cleanup, err = body()
+		if err != nil {
+			return metrics.RequestResult{
+				RequestID:    i,
+				Response:     "Failed to construct request body",
+				StatusCode:   0,
+				ResponseTime: 0,
+				StartTime:    time.Now(),
+				Error:        err,
+			}
+		}
+		defer cleanup()
+	}
+
+	return sendRequest(ctx, client, i, httpclient.Request{
+		Method:        method,
+		URL:           url,
+		Header:        header,
+		Body:          requestBody.Reader,
+		ContentType:   requestBody.ContentType,
+		ContentLength: requestBody.ContentLength,
+	})
+}
+
+// sendRequest sends the request and captures its result
+func sendRequest(ctx context.Context, client *httpclient.Client, i int, request httpclient.Request) metrics.RequestResult {
+	startTime := time.Now()
+	// Perform the HTTP request here and capture the result...
+	response, err := client.DoRequestContext(ctx, request)
+	responseTime := time.Since(startTime)
+
+	// The request only started once its OAuth2 token was ready
+	startTime = startTime.Add(response.TokenWait)
+	responseTime -= response.TokenWait
+
+	return metrics.RequestResult{
+		RequestID:    i,
+		Response:     response.Body,
+		StatusCode:   response.StatusCode,
+		ResponseTime: responseTime,
+		StartTime:    startTime,
+		Error:        err,
+		Protocol:     response.Protocol,
+		TLSVersion:   response.TLSVersion,
+		CipherSuite:  response.CipherSuite,
+
+		ConnectionID:     response.ConnectionID,
+		ConnectionReused: response.ConnectionReused,
+		Phases:           metrics.PhaseTimings(response.Phases),
+
+		BodySize:  response.BodySize,
+		BodyHash:  response.BodyHash,
+		Oversized: response.Oversized,
+
+		ContentEncoding: response.ContentEncoding,
+		ReceivedBytes:   response.ReceivedBytes,
+		RequestBodySize: response.RequestBodySize,
+		SentBytes:       response.SentBytes,
+		UploadTime:      response.UploadTime,
+
+		Redirects: len(response.Hops),
+		Hops:      redirectHops(response.Hops),
+		Redirect:  response.RedirectReturned,
+
+		TokenWait: response.TokenWait,
+
+		GraphQL:       request.GraphQL,
+		GraphQLErrors: response.GraphQLErrors,
+		GraphQLError:  response.GraphQLError,
+	}
 }
@@ -0,0 +1,89 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// BurstProfile multiplies the request rate during spikes. Spikes start At
+// the given offset from the start of the run and, if Every is set, repeat
+// periodically after that.
+type BurstProfile struct {
+	Multiplier float64
+	Duration   time.Duration
+	Every      time.Duration
+	At         time.Duration
+}
+
+// SpikeWindow is a period of the run during which the rate is multiplied
+type SpikeWindow struct {
+	Start time.Duration
+	End   time.Duration
+}
+
+// Enabled reports whether the profile has any spikes
+func (b BurstProfile) Enabled() bool {
+	return b.Duration > 0 && b.Multiplier > 0 && b.Multiplier != 1
+}
+
+// Windows lists the spikes that start within the given run length
+func (b BurstProfile) Windows(runLength time.Duration) []SpikeWindow {
+	if !b.Enabled() {
+		return nil
+	}
+
+	var windows []SpikeWindow
+	for start := b.At; start < runLength; start += b.Every {
+		windows = append(windows, SpikeWindow{Start: start, End: start + b.Duration})
+		if b.Every <= 0 {
+			break
+		}
+	}
+	return windows
+}
+
+// factor returns the rate multiplier at the given offset from the start of the run
+func (b BurstProfile) factor(offset time.Duration) float64 {
+	if !b.Enabled() || offset < b.At {
+		return 1
+	}
+
+	sinceFirst := offset - b.At
+	if b.Every > 0 {
+		sinceFirst %= b.Every
+	}
+	if sinceFirst < b.Duration {
+		return b.Multiplier
+	}
+	return 1
+}
+
+// pacer decides when the next request is started
+type pacer struct {
+	rate    float64
+	arrival string
+	burst   BurstProfile
+	random  *rand.Rand
+}
+
+func newPacer(config *BenchmarkConfig) *pacer {
+	return &pacer{
+		rate:    float64(config.Rate),
+		arrival: config.Arrival,
+		burst:   config.Burst,
+		random:  rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// interval returns the time until the next request for a request started at the given offset
+func (p *pacer) interval(offset time.Duration) time.Duration {
+	rate := p.rate * p.burst.factor(offset)
+	mean := float64(time.Second) / rate
+
+	if p.arrival == "poisson" {
+		// Exponentially distributed inter-arrival times give a Poisson process
+		return time.Duration(-math.Log(1-p.random.Float64()) * mean)
+	}
+	return time.Duration(mean)
+}
@@ -0,0 +1,118 @@
+package benchmark
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestBurstProfileFactor(t *testing.T) {
+	single := BurstProfile{Multiplier: 3, Duration: 2 * time.Second, At: 5 * time.Second}
+	repeating := BurstProfile{Multiplier: 3, Duration: 2 * time.Second, At: 5 * time.Second, Every: 10 * time.Second}
+
+	tests := []struct {
+		name    string
+		profile BurstProfile
+		offset  time.Duration
+		want    float64
+	}{
+		{name: "before the spike", profile: single, offset: 5*time.Second - time.Nanosecond, want: 1},
+		{name: "start of the spike", profile: single, offset: 5 * time.Second, want: 3},
+		{name: "last moment of the spike", profile: single, offset: 7*time.Second - time.Nanosecond, want: 3},
+		{name: "end of the spike", profile: single, offset: 7 * time.Second, want: 1},
+		{name: "single spike does not repeat", profile: single, offset: 15 * time.Second, want: 1},
+		{name: "repeated spike", profile: repeating, offset: 15 * time.Second, want: 3},
+		{name: "between repeated spikes", profile: repeating, offset: 17 * time.Second, want: 1},
+		{name: "third spike", profile: repeating, offset: 26 * time.Second, want: 3},
+		{name: "disabled by a multiplier of 1", profile: BurstProfile{Multiplier: 1, Duration: time.Second}, offset: 0, want: 1},
+		{name: "disabled without a duration", profile: BurstProfile{Multiplier: 3}, offset: 0, want: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.factor(tt.offset); got != tt.want {
+				t.Errorf("factor(%s) = %g, want %g", tt.offset, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBurstProfileWindows(t *testing.T) {
+	tests := []struct {
+		name      string
+		profile   BurstProfile
+		runLength time.Duration
+		want      []SpikeWindow
+	}{
+		{
+			name:      "single spike",
+			profile:   BurstProfile{Multiplier: 2, Duration: 2 * time.Second, At: 5 * time.Second},
+			runLength: 30 * time.Second,
+			want:      []SpikeWindow{{Start: 5 * time.Second, End: 7 * time.Second}},
+		},
+		{
+			name:      "repeating spikes starting within the run",
+			profile:   BurstProfile{Multiplier: 2, Duration: 2 * time.Second, At: 5 * time.Second, Every: 10 * time.Second},
+			runLength: 25 * time.Second,
+			want: []SpikeWindow{
+				{Start: 5 * time.Second, End: 7 * time.Second},
+				{Start: 15 * time.Second, End: 17 * time.Second},
+			},
+		},
+		{
+			name:      "spike after the run",
+			profile:   BurstProfile{Multiplier: 2, Duration: 2 * time.Second, At: 30 * time.Second},
+			runLength: 30 * time.Second,
+		},
+		{
+			name:      "disabled",
+			profile:   BurstProfile{Multiplier: 1, Duration: 2 * time.Second},
+			runLength: 30 * time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.profile.Windows(tt.runLength); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Windows() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPacerInterval(t *testing.T) {
+	burst := BurstProfile{Multiplier: 4, Duration: time.Second, At: 10 * time.Second}
+
+	uniform := &pacer{rate: 100, burst: burst}
+	if got := uniform.interval(0); got != 10*time.Millisecond {
+		t.Errorf("uniform interval() = %s, want 10ms", got)
+	}
+	if got := uniform.interval(10 * time.Second); got != 2500*time.Microsecond {
+		t.Errorf("uniform interval() during a spike = %s, want 2.5ms", got)
+	}
+
+	// Poisson intervals vary but average 1/rate, also during a spike
+	poisson := &pacer{rate: 100, arrival: "poisson", burst: burst, random: rand.New(rand.NewSource(1))}
+	for _, tt := range []struct {
+		offset time.Duration
+		want   time.Duration
+	}{{0, 10 * time.Millisecond}, {10 * time.Second, 2500 * time.Microsecond}} {
+		const samples = 20000
+		var total time.Duration
+		distinct := map[time.Duration]bool{}
+		for i := 0; i < samples; i++ {
+			interval := poisson.interval(tt.offset)
+			total += interval
+			distinct[interval] = true
+		}
+		mean := total / samples
+		if math.Abs(float64(mean-tt.want)) > 0.03*float64(tt.want) {
+			t.Errorf("poisson interval() at %s averages %s, want %s", tt.offset, mean, tt.want)
+		}
+		if len(distinct) < samples/2 {
+			t.Errorf("poisson interval() at %s gave only %d distinct intervals", tt.offset, len(distinct))
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package benchmark
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/workload"
+)
+
+// Executor executes one unit of work of a benchmark, such as an HTTP request
+// or a gRPC call, and captures its result: the response time, status code,
+// body bytes, error, error class and tags. The scheduler decides when and how
+// often it is called, with the pacing, concurrency, targets and warm-up of
+// the config, so an executor must be safe for concurrent use.
+//
+// A result counts as a failure when it has an error or an error class, or a
+// status code outside 2xx; an executor of another protocol maps its outcome
+// onto those. The scheduler classifies the errors the executor left unclassified.
+type Executor interface {
+	Execute(ctx context.Context, work Work) metrics.RequestResult
+}
+
+// Work is the unit of work the scheduler hands an executor
+type Work struct {
+	ID       int    // Number of the request in the run
+	URL      string // URL or target picked for it
+	Endpoint int    // Index of the workload endpoint sampled for it, -1 without a workload
+}
+
+// ExecutorFunc lets an ordinary function be used as an Executor
+type ExecutorFunc func(ctx context.Context, work Work) metrics.RequestResult
+
+// Execute calls f(ctx, work)
+func (f ExecutorFunc) Execute(ctx context.Context, work Work) metrics.RequestResult {
+	return f(ctx, work)
+}
+
+// runExecutor hands the work of the config to a custom executor, with the
+// pacing, concurrency, targets and warm-up of the config. Custom protocols
+// and workloads written in Go are benchmarked this way.
+func runExecutor(r *run, config *BenchmarkConfig, executor Executor) []metrics.RequestResult {
+	r.logf("Benchmarking %s with %d requests, %d concurrent requests, for %d seconds\n", describeTargets(config), config.Requests, config.Concurrency, config.Duration)
+	r.logSchedule(config, "requests")
+
+	results := make(chan metrics.RequestResult, config.Requests)
+
+	go startWorkers(r.ctx, config, executor, results)
+
+	return r.collect(results)
+}
+
+// HTTPExecutor sends the requests of a config with the HTTP client, either
+// the method and body of the config or the sampled workload endpoint. It is
+// the executor of the default HTTP mode.
+type HTTPExecutor struct {
+	client    *httpclient.Client
+	method    string
+	body      bodyFunc
+	endpoints []workload.Endpoint
+	headers   []http.Header // Headers of each endpoint
+}
+
+// NewHTTPExecutor creates the HTTP client of the config, fetching its OAuth2
+// token if it has one.
+func NewHTTPExecutor(config *BenchmarkConfig) (*HTTPExecutor, error) {
+	client, err := httpclient.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticate(config, client); err != nil {
+		return nil, err
+	}
+	return &HTTPExecutor{
+		client:    client,
+		method:    config.Method,
+		body:      configBody(config),
+		endpoints: config.Workload,
+		headers:   endpointHeaders(config.Workload),
+	}, nil
+}
+
+// Execute sends one request to the URL of the work
+func (e *HTTPExecutor) Execute(ctx context.Context, work Work) metrics.RequestResult {
+	if work.Endpoint < 0 {
+		return performRequest(ctx, e.client, work.ID, e.method, work.URL, nil, e.body)
+	}
+
+	// Endpoint paths are relative to the URL
+	endpoint := e.endpoints[work.Endpoint]
+	url := strings.TrimSuffix(work.URL, "/") + endpoint.Path
+	result := performRequest(ctx, e.client, work.ID, endpoint.Method, url, e.headers[work.Endpoint], endpointBody(endpoint))
+	result.Endpoint = endpoint.Name
+	return result
+}
+
+// TokenFetches returns the requests the client made to the OAuth2 token
+// endpoint, as results to be reported apart from the benchmark requests.
+func (e *HTTPExecutor) TokenFetches() []metrics.RequestResult {
+	return tokenFetchResults(e.client)
+}
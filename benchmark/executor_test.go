@@ -0,0 +1,145 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/workload"
+)
+
+// recordingExecutor answers every work at once and remembers what it was handed
+type recordingExecutor struct {
+	mu    sync.Mutex
+	works []Work
+}
+
+func (e *recordingExecutor) Execute(ctx context.Context, work Work) metrics.RequestResult {
+	e.mu.Lock()
+	e.works = append(e.works, work)
+	e.mu.Unlock()
+
+	status := 200
+	if work.ID == 4 {
+		status = 503
+	}
+	return metrics.RequestResult{RequestID: work.ID, StatusCode: status, StartTime: time.Now(), ResponseTime: time.Millisecond}
+}
+
+func TestRunExecutorWork(t *testing.T) {
+	tests := []struct {
+		name         string
+		workload     []workload.Endpoint
+		wantEndpoint int
+	}{
+		{name: "without a workload", wantEndpoint: -1},
+		{name: "with a workload", workload: []workload.Endpoint{{Name: "GET /items", Method: "GET", Path: "/items", Weight: 1}}, wantEndpoint: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			executor := &recordingExecutor{}
+			// One at a time, so the work comes in order
+			runner, err := NewRunner(Options{
+				Config: BenchmarkConfig{
+					Targets:        []Target{{URL: "http://a", Weight: 1}, {URL: "http://b", Weight: 1}},
+					Requests:       4,
+					Concurrency:    1,
+					Duration:       5,
+					WarmupRequests: 2,
+					Workload:       tt.workload,
+				},
+				Executor: executor,
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			result, err := runner.Run(context.Background())
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			// The warm-up requests come on top of the measured ones
+			var ids []int
+			for i, work := range executor.works {
+				ids = append(ids, work.ID)
+				if want := []string{"http://a", "http://b"}[i%2]; work.URL != want {
+					t.Errorf("work %d URL = %s, want %s", work.ID, work.URL, want)
+				}
+				if work.Endpoint != tt.wantEndpoint {
+					t.Errorf("work %d endpoint = %d, want %d", work.ID, work.Endpoint, tt.wantEndpoint)
+				}
+			}
+			if want := []int{0, 1, 2, 3, 4, 5}; !reflect.DeepEqual(ids, want) {
+				t.Fatalf("work IDs = %v, want %v", ids, want)
+			}
+
+			if len(result.Results) != 6 {
+				t.Fatalf("got %d results, want 6", len(result.Results))
+			}
+			for _, r := range result.Results {
+				wantClass := ""
+				if r.RequestID == 4 {
+					wantClass = metrics.ErrorClassStatus
+				}
+				got := fmt.Sprintf("%v %s %d %q", r.Warmup, r.Target, r.Concurrency, r.ErrorClass)
+				want := fmt.Sprintf("%v %s %d %q", r.RequestID < 2, executor.works[r.RequestID].URL, 1, wantClass)
+				if got != want {
+					t.Errorf("result %d warm-up, target, concurrency, error class = %s, want %s", r.RequestID, got, want)
+				}
+			}
+			if result.Metrics.TotalRequests != 4 {
+				t.Errorf("metrics counted %d requests, want the 4 measured ones", result.Metrics.TotalRequests)
+			}
+		})
+	}
+}
+
+func TestRunExecutorCancel(t *testing.T) {
+	// The first requests answer at once, the rest wait for the end of the context
+	executor := ExecutorFunc(func(ctx context.Context, work Work) metrics.RequestResult {
+		if work.ID >= 3 {
+			<-ctx.Done()
+			return metrics.RequestResult{RequestID: work.ID, StartTime: time.Now(), Error: ctx.Err()}
+		}
+		return metrics.RequestResult{RequestID: work.ID, StatusCode: 200, StartTime: time.Now(), ResponseTime: time.Millisecond}
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runner, err := NewRunner(Options{
+		Config:   BenchmarkConfig{URL: "http://service", Requests: 100, Concurrency: 2, Duration: 10},
+		Executor: executor,
+		OnResult: func(result metrics.RequestResult) {
+			if result.RequestID == 2 {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	result, err := runner.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s after the context was cancelled", elapsed)
+	}
+
+	// Only the completed requests are reported, not the ones cut short
+	if len(result.Results) != 3 {
+		t.Fatalf("got %d results, want 3", len(result.Results))
+	}
+	for _, r := range result.Results {
+		if r.Error != nil {
+			t.Errorf("result %d was cut short but reported: %v", r.RequestID, r.Error)
+		}
+	}
+}
@@ -0,0 +1,103 @@
+package benchmark
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// anonymousOperation names the operation of a document without named operations in the results
+const anonymousOperation = "anonymous"
+
+// GraphQLConfig describes the operations the GraphQL mode runs. The variables
+// are a JSON object template rendered for every request, where {{id}} is the
+// number of the request.
+type GraphQLConfig struct {
+	Query      string   // GraphQL document
+	Operations []string // Operations run in turn, one empty name for the anonymous operation
+	Variables  string   // Template of the variables, no variables when empty
+}
+
+// ValidateVariables checks that the variables template only uses the
+// placeholders of a request and renders to a JSON object.
+func ValidateVariables(variables string) error {
+	if err := validatePlaceholders(variables, "id", "timestamp", "uuid"); err != nil {
+		return err
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(renderTemplate(variables, templateValues{id: 1})), &object); err != nil || object == nil {
+		return fmt.Errorf("the variables are not a JSON object")
+	}
+	return nil
+}
+
+// runGraphQL posts the operations of the GraphQL config to the URL of the
+// config, or to the targets, in turn. Responses with errors in their errors
+// array count as failures whatever their HTTP status code.
+func runGraphQL(r *run, config *BenchmarkConfig, graphQL GraphQLConfig) ([]metrics.RequestResult, error) {
+	client, err := httpclient.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticate(config, client); err != nil {
+		return nil, err
+	}
+	r.logToken(config)
+
+	r.logf("Running %s on %s with %d requests, %d concurrent requests, for %d seconds\n", describeOperations(graphQL.Operations), describeTargets(config), config.Requests, config.Concurrency, config.Duration)
+	r.logSchedule(config, "requests")
+
+	results := make(chan metrics.RequestResult, config.Requests)
+
+	go startWorkers(r.ctx, config, ExecutorFunc(func(ctx context.Context, work Work) metrics.RequestResult {
+		return performOperation(ctx, client, work.ID, work.URL, graphQL)
+	}), results)
+
+	allResults := r.collect(results)
+	return append(allResults, tokenFetchResults(client)...), nil
+}
+
+// performOperation posts the operation of request i and captures its result
+func performOperation(ctx context.Context, client *httpclient.Client, i int, url string, graphQL GraphQLConfig) metrics.RequestResult {
+	operation := graphQL.Operations[i%len(graphQL.Operations)]
+	variables := ""
+	if graphQL.Variables != "" {
+		variables = renderTemplate(graphQL.Variables, templateValues{id: int64(i)})
+	}
+
+	var result metrics.RequestResult
+	body, err := httpclient.NewGraphQLBody(graphQL.Query, operation, variables)
+	if err != nil {
+		result = metrics.RequestResult{RequestID: i, Response: "Failed to construct request body", StartTime: time.Now(), Error: err, GraphQL: true}
+	} else {
+		result = sendRequest(ctx, client, i, httpclient.Request{
+			Method:      "POST",
+			URL:         url,
+			Body:        body.Reader,
+			ContentType: body.ContentType,
+			GraphQL:     true,
+		})
+	}
+
+	result.Operation = operation
+	if operation == "" {
+		result.Operation = anonymousOperation
+	}
+	return result
+}
+
+// describeOperations names the operations run for the start message
+func describeOperations(operations []string) string {
+	if len(operations) == 1 && operations[0] == "" {
+		return "the anonymous operation"
+	}
+	if len(operations) == 1 {
+		return "operation " + operations[0]
+	}
+	return "operations " + strings.Join(operations, ", ")
+}
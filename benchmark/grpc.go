@@ -0,0 +1,90 @@
+package benchmark
+
+import (
+	"context"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/grpcclient"
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+	"google.golang.org/grpc/metadata"
+)
+
+// GRPCConfig describes the call the gRPC mode makes
+type GRPCConfig struct {
+	Method        string      // Full method name, e.g. helloworld.Greeter/SayHello
+	Message       string      // Request message as JSON
+	Metadata      metadata.MD // Sent with every call
+	DescriptorSet string      // FileDescriptorSet describing the method, server reflection when empty
+}
+
+// runGRPC calls the method of the gRPC config on the URL of the config, or
+// on the targets, with the concurrency, rate, duration and warm-up of the
+// config. Every call is one result, its status code is the gRPC one.
+func runGRPC(r *run, config *BenchmarkConfig, call GRPCConfig) ([]metrics.RequestResult, error) {
+	tlsConfig, err := httpclient.TLSConfig(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	client, err := grpcclient.NewClient(grpcclient.Options{
+		DescriptorSet: call.DescriptorSet,
+		TLSConfig:     tlsConfig,
+		Connections:   config.Client.Connections,
+		Timeout:       config.Client.Timeout,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer client.Close()
+
+	r.logf("Calling %s on %s with %d calls, %d concurrent calls, for %d seconds\n", call.Method, describeTargets(config), config.Requests, config.Concurrency, config.Duration)
+	r.logSchedule(config, "calls")
+
+	results := make(chan metrics.RequestResult, config.Requests)
+
+	go startWorkers(r.ctx, config, ExecutorFunc(func(ctx context.Context, work Work) metrics.RequestResult {
+		return performCall(ctx, client, work.ID, work.URL, call)
+	}), results)
+
+	return r.collect(results), nil
+}
+
+// performCall makes a single gRPC call and captures its result
+func performCall(ctx context.Context, client *grpcclient.Client, i int, target string, call GRPCConfig) metrics.RequestResult {
+	startTime := time.Now()
+	response, err := client.Invoke(ctx, grpcclient.Call{
+		Target:   target,
+		Method:   call.Method,
+		Message:  call.Message,
+		Metadata: call.Metadata,
+	})
+	responseTime := time.Since(startTime)
+
+	result := metrics.RequestResult{
+		RequestID:    i,
+		Response:     response.Body,
+		StatusCode:   int(response.Code),
+		ResponseTime: responseTime,
+		StartTime:    startTime,
+		Error:        err,
+		Protocol:     "gRPC",
+
+		// Message sizes are those of the protobuf encoding, not of the JSON kept as the response
+		BodySize:        response.ReceivedBytes,
+		ReceivedBytes:   response.ReceivedBytes,
+		RequestBodySize: response.SentBytes,
+		SentBytes:       response.SentBytes,
+
+		GRPC:       true,
+		GRPCStatus: response.Code.String(),
+		Streaming:  response.Streaming,
+	}
+	if err != nil {
+		result.GRPCStatus = ""
+	}
+	if response.Streaming {
+		result.StreamMessages = response.Messages
+		result.FirstMessage = response.FirstMessage
+	}
+	return result
+}
@@ -0,0 +1,78 @@
+package benchmark
+
+import (
+	"testing"
+	"time"
+)
+
+// acquireAsync acquires a slot in the background, reporting the outcome on the channel
+func acquireAsync(l *limiter) <-chan bool {
+	acquired := make(chan bool, 1)
+	go func() { acquired <- l.acquire() }()
+	return acquired
+}
+
+func expectBlocked(t *testing.T, acquired <-chan bool) {
+	t.Helper()
+	select {
+	case <-acquired:
+		t.Fatalf("acquire() returned while no slot was free")
+	case <-time.After(20 * time.Millisecond):
+	}
+}
+
+func expectAcquired(t *testing.T, acquired <-chan bool, want bool) {
+	t.Helper()
+	select {
+	case got := <-acquired:
+		if got != want {
+			t.Fatalf("acquire() = %v, want %v", got, want)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("acquire() is still blocked")
+	}
+}
+
+func TestLimiterShrink(t *testing.T) {
+	l := newLimiter(3)
+	for i := 0; i < 3; i++ {
+		if !l.acquire() {
+			t.Fatalf("acquire() = false on an open limiter")
+		}
+	}
+
+	// Shrinking below the slots held blocks new requests until enough are released
+	l.setLimit(1)
+	acquired := acquireAsync(l)
+	expectBlocked(t, acquired)
+	l.release()
+	expectBlocked(t, acquired)
+	l.release()
+	expectBlocked(t, acquired)
+	l.release()
+	expectAcquired(t, acquired, true)
+
+	if got := l.currentLimit(); got != 1 {
+		t.Errorf("currentLimit() = %d, want 1", got)
+	}
+}
+
+func TestLimiterGrowAndClose(t *testing.T) {
+	l := newLimiter(1)
+	l.acquire()
+
+	// Growing the limit wakes a waiting request
+	acquired := acquireAsync(l)
+	expectBlocked(t, acquired)
+	l.setLimit(2)
+	expectAcquired(t, acquired, true)
+
+	// Closing wakes the remaining waiters and refuses new requests
+	acquired = acquireAsync(l)
+	expectBlocked(t, acquired)
+	l.close()
+	expectAcquired(t, acquired, false)
+	if l.acquire() {
+		t.Errorf("acquire() = true on a closed limiter")
+	}
+}
@@ -0,0 +1,54 @@
+package benchmark
+
+import (
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// redirectHops converts the redirects recorded by the client
+func redirectHops(hops []httpclient.RedirectHop) []metrics.RedirectHop {
+	if len(hops) == 0 {
+		return nil
+	}
+	converted := make([]metrics.RedirectHop, len(hops))
+	for i, hop := range hops {
+		converted[i] = metrics.RedirectHop(hop)
+	}
+	return converted
+}
+
+// splitRedirectHops turns every redirect followed by the request into a result
+// of its own, timed from when its request was sent until its response headers
+// arrived. The final response keeps the rest of the result and is timed from
+// when the last redirect was followed.
+func splitRedirectHops(result metrics.RequestResult) []metrics.RequestResult {
+	if len(result.Hops) == 0 {
+		return []metrics.RequestResult{result}
+	}
+
+	split := make([]metrics.RequestResult, 0, len(result.Hops)+1)
+	for i, hop := range result.Hops {
+		split = append(split, metrics.RequestResult{
+			RequestID:    result.RequestID,
+			StatusCode:   hop.StatusCode,
+			ResponseTime: hop.ResponseTime,
+			StartTime:    hop.StartTime,
+			Warmup:       result.Warmup,
+			Concurrency:  result.Concurrency,
+			Target:       result.Target,
+			Endpoint:     result.Endpoint,
+			Tags:         result.Tags,
+			Hop:          i + 1,
+			HopURL:       hop.URL,
+			Redirect:     true,
+		})
+	}
+
+	last := result.Hops[len(result.Hops)-1]
+	final := result
+	final.StartTime = last.StartTime.Add(last.ResponseTime)
+	final.ResponseTime = result.StartTime.Add(result.ResponseTime).Sub(final.StartTime)
+	final.Hops = nil
+	final.Hop = len(result.Hops) + 1
+	return append(split, final)
+}
@@ -0,0 +1,107 @@
+package benchmark
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/replay"
+)
+
+// runReplay reissues the logged requests against the base URL of the config,
+// or against the targets as base URLs, preserving their relative timing scaled
+// by the speed factor. The concurrency of the config caps the requests in
+// flight; Requests and Duration are ignored.
+func runReplay(r *run, config *BenchmarkConfig, entries []replay.Entry, speed float64) ([]metrics.RequestResult, error) {
+	client, err := httpclient.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticate(config, client); err != nil {
+		return nil, err
+	}
+	r.logToken(config)
+
+	r.logf("Replaying %d requests against %s at %gx speed with up to %d concurrent requests\n", len(entries), describeTargets(config), speed, config.Concurrency)
+
+	results := make(chan metrics.RequestResult, len(entries))
+
+	go replayEntries(r.ctx, config, client, entries, speed, results)
+
+	return append(r.collect(results), tokenFetchResults(client)...), nil
+}
+
+func replayEntries(ctx context.Context, config *BenchmarkConfig, client *httpclient.Client, entries []replay.Entry, speed float64, results chan<- metrics.RequestResult) {
+	var wg sync.WaitGroup
+	concurrencyLimiter := newLimiter(config.Concurrency)
+	picker := newTargetPicker(config)
+
+	runStart := time.Now()
+	warmupEnd := runStart.Add(config.WarmupDuration)
+
+	// Stop replaying once the context is done
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			concurrencyLimiter.close()
+		case <-done:
+		}
+	}()
+
+dispatch:
+	for i, entry := range entries {
+		// Wait until the request is due. If the concurrency limit held back
+		// earlier requests, the late ones are sent right away.
+		pause := time.NewTimer(time.Until(runStart.Add(replay.Offset(entries[0], entry, speed))))
+		select {
+		case <-pause.C:
+		case <-ctx.Done():
+			pause.Stop()
+			break dispatch
+		}
+
+		if !concurrencyLimiter.acquire() {
+			break dispatch
+		}
+		warmup := i < config.WarmupRequests || time.Now().Before(warmupEnd)
+		baseURL := picker.pick()
+
+		wg.Add(1)
+		go func(i int, baseURL string, entry replay.Entry, warmup bool) {
+			defer wg.Done()
+			result := performRequest(ctx, client, i, entry.Method, strings.TrimSuffix(baseURL, "/")+entry.Path, nil, entryBody(entry))
+			if result.Error != nil && ctx.Err() != nil {
+				// The request was cut short by the end of the context
+				concurrencyLimiter.release()
+				return
+			}
+			if picker.multiple() {
+				result.Target = baseURL
+			}
+			result.Warmup = warmup
+			result.Concurrency = config.Concurrency
+			sendResult(config, results, result)
+
+			// Release the concurrency slot
+			concurrencyLimiter.release()
+		}(i, baseURL, entry, warmup)
+	}
+
+	wg.Wait()
+	close(results)
+}
+
+// entryBody returns the logged body as is, without the @file handling of the body flag
+func entryBody(entry replay.Entry) bodyFunc {
+	if entry.Body == "" {
+		return nil
+	}
+	return func() (httpclient.Body, func(), error) {
+		return httpclient.Body{Reader: strings.NewReader(entry.Body)}, func() {}, nil
+	}
+}
@@ -0,0 +1,278 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/replay"
+)
+
+// Options configures a Runner. Config describes the load and where it goes,
+// with the same meaning as the flags of the command line. At most one of the
+// mode fields is set; without any, the requests of Config are sent over HTTP.
+type Options struct {
+	Config BenchmarkConfig
+
+	GRPC      *GRPCConfig
+	GraphQL   *GraphQLConfig
+	SSE       *SSEConfig
+	WebSocket *WebSocketConfig
+	Replay    *ReplayConfig
+	Executor  Executor // Custom executor the work of Config is handed to
+
+	// Callbacks, both called from the same goroutine while the run lasts.
+	// OnResult gets every result as it comes in. OnProgress gets a snapshot
+	// every ProgressInterval, a second when zero, and once more at the end.
+	OnResult         func(metrics.RequestResult)
+	OnProgress       func(Progress)
+	ProgressInterval time.Duration
+
+	Log io.Writer // Receives the messages of the run, such as what is benchmarked, discarded when nil
+}
+
+// ReplayConfig describes the logged requests the replay mode reissues
+type ReplayConfig struct {
+	Entries []replay.Entry
+	Speed   float64 // Time-scale factor, 1 when zero
+}
+
+// Progress is a snapshot of a run in progress
+type Progress struct {
+	Elapsed  time.Duration
+	Requests int // Results in so far, warm-up excluded
+	Failed   int // Failed results among them
+	Warmup   int // Warm-up results in so far
+}
+
+// Result is the outcome of a run
+type Result struct {
+	Config    BenchmarkConfig // Config as run, e.g. with the method of the mode
+	StartTime time.Time
+	Duration  time.Duration
+	Results   []metrics.RequestResult // Every result, including warm-up and token fetches
+	Metrics   metrics.AggregateMetrics
+}
+
+// Runner runs a benchmark. It prints nothing and writes no files, so it can
+// be embedded in other programs such as integration tests.
+type Runner struct {
+	options Options
+}
+
+// NewRunner checks the options and fills in the defaults of the ones left empty
+func NewRunner(options Options) (*Runner, error) {
+	modes := 0
+	for _, set := range []bool{options.GRPC != nil, options.GraphQL != nil, options.SSE != nil, options.WebSocket != nil, options.Replay != nil, options.Executor != nil} {
+		if set {
+			modes++
+		}
+	}
+	if modes > 1 {
+		return nil, fmt.Errorf("only one of GRPC, GraphQL, SSE, WebSocket, Replay and Executor can be set")
+	}
+
+	config := &options.Config
+	if config.Method == "" {
+		config.Method = "GET"
+	}
+	if err := ValidateConfig(config); err != nil {
+		return nil, err
+	}
+	if config.Concurrency < 1 {
+		return nil, fmt.Errorf("concurrency must be at least 1")
+	}
+	if options.Replay == nil {
+		if config.Duration < 1 {
+			return nil, fmt.Errorf("duration must be at least 1 second")
+		}
+		if config.Requests < 1 && options.SSE == nil && options.WebSocket == nil {
+			return nil, fmt.Errorf("requests must be at least 1")
+		}
+	}
+
+	switch {
+	case options.GRPC != nil:
+		if err := ValidateGRPC(config, options.GRPC); err != nil {
+			return nil, err
+		}
+		// The results show the gRPC method in place of the HTTP one
+		config.Method = options.GRPC.Method
+	case options.GraphQL != nil:
+		graphQL := *options.GraphQL
+		if err := ValidateGraphQL(config, &graphQL); err != nil {
+			return nil, err
+		}
+		config.Method = "POST"
+		options.GraphQL = &graphQL
+	case options.SSE != nil:
+		if err := ValidateSSE(config, options.SSE); err != nil {
+			return nil, err
+		}
+	case options.WebSocket != nil:
+		if err := ValidateWebSocket(config, options.WebSocket); err != nil {
+			return nil, err
+		}
+	case options.Replay != nil:
+		if len(options.Replay.Entries) == 0 {
+			return nil, fmt.Errorf("there are no requests to replay")
+		}
+		if options.Replay.Speed < 0 {
+			return nil, fmt.Errorf("replay speed must be positive")
+		}
+		replayConfig := *options.Replay
+		if replayConfig.Speed == 0 {
+			replayConfig.Speed = 1
+		}
+		options.Replay = &replayConfig
+	}
+
+	if options.ProgressInterval <= 0 {
+		options.ProgressInterval = time.Second
+	}
+	if options.Log == nil {
+		options.Log = io.Discard
+	}
+	return &Runner{options: options}, nil
+}
+
+// Run runs the benchmark until its requests are made or its time is up.
+// Cancelling the context stops the run early, and the results so far are
+// returned, without the requests it cut short. A Runner can be run again,
+// every run starting afresh.
+func (r *Runner) Run(ctx context.Context) (Result, error) {
+	options := r.options
+	config := options.Config
+	current := &run{ctx: ctx, options: &options, start: time.Now()}
+
+	var results []metrics.RequestResult
+	var err error
+	switch {
+	case options.GRPC != nil:
+		results, err = runGRPC(current, &config, *options.GRPC)
+	case options.GraphQL != nil:
+		results, err = runGraphQL(current, &config, *options.GraphQL)
+	case options.SSE != nil:
+		results, err = runSSE(current, &config, *options.SSE)
+	case options.WebSocket != nil:
+		results, err = runWebSocket(current, &config, *options.WebSocket)
+	case options.Replay != nil:
+		results, err = runReplay(current, &config, options.Replay.Entries, options.Replay.Speed)
+	case options.Executor != nil:
+		results = runExecutor(current, &config, options.Executor)
+	default:
+		results, err = runHTTP(current, &config)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+
+	return Result{
+		Config:    config,
+		StartTime: current.start,
+		Duration:  time.Since(current.start),
+		Results:   results,
+		Metrics:   metrics.CalculateMetrics(results),
+	}, nil
+}
+
+// run is the state of a single run of a Runner: the context that stops it,
+// where its messages go and the progress its callbacks are told of
+type run struct {
+	ctx      context.Context
+	options  *Options
+	start    time.Time
+	progress Progress
+}
+
+// logf writes a message of the run to the log of the options
+func (r *run) logf(format string, args ...interface{}) {
+	fmt.Fprintf(r.options.Log, format, args...)
+}
+
+// logToken tells where the OAuth2 token, if any, was fetched from
+func (r *run) logToken(config *BenchmarkConfig) {
+	if config.Client.OAuth2TokenURL != "" {
+		r.logf("Fetched an OAuth2 token from %s\n", config.Client.OAuth2TokenURL)
+	}
+}
+
+// logSchedule tells how the scheduler hands out the work of the config: the
+// adaptive concurrency, the pacing and spikes, and the warm-up. The unit names
+// the work of the mode, e.g. requests or calls.
+func (r *run) logSchedule(config *BenchmarkConfig, unit string) {
+	if config.AdaptiveMode != "" {
+		r.logf("Adapting concurrency (%s) to keep p95 at %s\n", config.AdaptiveMode, config.TargetP95)
+	}
+	if config.Rate > 0 {
+		r.logf("Pacing %s at %d %s per second\n", unit, config.Rate, unit)
+	}
+	if config.Arrival == "poisson" {
+		r.logf("Using Poisson-distributed arrivals\n")
+	}
+	if config.Burst.Enabled() {
+		r.logf("Spiking the rate %gx for %s starting at %s", config.Burst.Multiplier, config.Burst.Duration, config.Burst.At)
+		if config.Burst.Every > 0 {
+			r.logf(", repeating every %s", config.Burst.Every)
+		}
+		r.logf("\n")
+	}
+	if config.WarmupDuration > 0 || config.WarmupRequests > 0 {
+		r.logf("Warming up for %s / %d %s before measuring\n", config.WarmupDuration, config.WarmupRequests, unit)
+	}
+}
+
+// collect gathers the results until the channel is closed, passing them on to
+// the callbacks of the options as they come in
+func (r *run) collect(results <-chan metrics.RequestResult) []metrics.RequestResult {
+	var allResults []metrics.RequestResult
+
+	var tick <-chan time.Time
+	if r.options.OnProgress != nil {
+		ticker := time.NewTicker(r.options.ProgressInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case result, ok := <-results:
+			if !ok {
+				r.reportProgress()
+				return allResults
+			}
+			allResults = append(allResults, result)
+			r.count(result)
+			if r.options.OnResult != nil {
+				r.options.OnResult(result)
+			}
+		case <-tick:
+			r.reportProgress()
+		}
+	}
+}
+
+// count adds a result to the progress of the run
+func (r *run) count(result metrics.RequestResult) {
+	switch {
+	case result.Warmup:
+		r.progress.Warmup++
+	case result.TokenFetch:
+	default:
+		r.progress.Requests++
+		if metrics.ClassifyError(result) != "" {
+			r.progress.Failed++
+		}
+	}
+}
+
+// reportProgress hands a snapshot of the progress to the callback, if any
+func (r *run) reportProgress() {
+	if r.options.OnProgress == nil {
+		return
+	}
+	r.progress.Elapsed = time.Since(r.start)
+	r.options.OnProgress(r.progress)
+}
@@ -0,0 +1,177 @@
+package benchmark
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+func TestNewRunner(t *testing.T) {
+	certFile := filepath.Join(t.TempDir(), "client.pem")
+	os.WriteFile(certFile, []byte("certificate"), 0600)
+	executor := ExecutorFunc(func(ctx context.Context, work Work) metrics.RequestResult { return metrics.RequestResult{} })
+
+	tests := []struct {
+		name   string
+		modify func(*Options)
+		errMsg string
+	}{
+		{name: "valid options", modify: func(o *Options) {}},
+		{name: "several modes", modify: func(o *Options) { o.Executor, o.SSE = executor, &SSEConfig{} }, errMsg: "only one of GRPC, GraphQL, SSE, WebSocket, Replay and Executor can be set"},
+		{name: "missing URL", modify: func(o *Options) { o.Config.URL = "" }, errMsg: "URL is required"},
+		{name: "invalid distribution", modify: func(o *Options) { o.Config.Distribution = "sticky" }, errMsg: "'sticky' is not a valid distribution. Supported distributions are: round-robin, random, weighted"},
+		{name: "certificate without a key", modify: func(o *Options) { o.Config.Client.CertFile = certFile }, errMsg: "both a client certificate and its key are required for mTLS"},
+		{name: "missing TLS file", modify: func(o *Options) { o.Config.Client.CAFile = "/nonexistent/ca.pem" }, errMsg: "the TLS file does not exist: /nonexistent/ca.pem"},
+		{name: "invalid proxy", modify: func(o *Options) { o.Config.Client.Proxy = "ftp://proxy" }, errMsg: "'ftp' is not a supported proxy scheme. Supported schemes are: http, https, socks5, socks5h"},
+		{name: "adaptive without a target", modify: func(o *Options) { o.Config.AdaptiveMode = "aimd" }, errMsg: "a target p95 is required for adaptive concurrency"},
+		{name: "spikes without a rate", modify: func(o *Options) { o.Config.Burst = BurstProfile{Multiplier: 2, Duration: time.Second} }, errMsg: "a rate is required for poisson arrivals and spikes"},
+		{name: "compressed body size", modify: func(o *Options) { o.Config.BodySize, o.Config.Client.RequestEncoding = 1024, "gzip" }, errMsg: "a body size is streamed and cannot be compressed or signed, both read the whole body into memory"},
+		{name: "no concurrency", modify: func(o *Options) { o.Config.Concurrency = 0 }, errMsg: "concurrency must be at least 1"},
+		{name: "websocket", modify: func(o *Options) {
+			o.Config.URL, o.WebSocket = "ws://service", &WebSocketConfig{Messages: []string{"ping"}, ReplyTimeout: time.Second}
+		}},
+		{name: "websocket without messages", modify: func(o *Options) {
+			o.Config.URL, o.WebSocket = "ws://service", &WebSocketConfig{ReplyTimeout: time.Second}
+		}, errMsg: "at least one message or a messages file is required"},
+		{name: "websocket without a reply timeout", modify: func(o *Options) {
+			o.Config.URL, o.WebSocket = "ws://service", &WebSocketConfig{Messages: []string{"ping"}}
+		}, errMsg: "the reply timeout must be positive"},
+		{name: "sse with a body", modify: func(o *Options) { o.Config.BodySize, o.SSE = 1024, &SSEConfig{} }, errMsg: "streams are opened with GET, a method or request body does not apply"},
+		{name: "grpc over http", modify: func(o *Options) { o.GRPC = &GRPCConfig{Method: "grpc.health.v1.Health/Check"} }, errMsg: "'http://service' is not a gRPC target, use grpc://host:port or grpcs://host:port"},
+		{name: "unknown graphql operation", modify: func(o *Options) {
+			o.GraphQL = &GraphQLConfig{Query: "query Users { users { id } }", Operations: []string{"Posts"}}
+		}, errMsg: "'Posts' is not an operation of the query file. Operations are: Users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := Options{Config: BenchmarkConfig{URL: "http://service", Requests: 10, Concurrency: 1, Duration: 1}}
+			tt.modify(&options)
+			_, err := NewRunner(options)
+			if (err != nil) != (tt.errMsg != "") || (err != nil && err.Error() != tt.errMsg) {
+				t.Errorf("NewRunner() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestRunnerRun(t *testing.T) {
+	var hits int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&hits, 1)
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var onResult int
+	var progress []Progress
+	runner, err := NewRunner(Options{
+		Config:     BenchmarkConfig{URL: ts.URL, Requests: 10, Concurrency: 2, Duration: 5, WarmupRequests: 3},
+		OnResult:   func(metrics.RequestResult) { onResult++ },
+		OnProgress: func(p Progress) { progress = append(progress, p) },
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Every run starts afresh
+	for run := 1; run <= 2; run++ {
+		onResult, progress = 0, nil
+		result, err := runner.Run(context.Background())
+		if err != nil {
+			t.Fatalf("run %d: unexpected error: %v", run, err)
+		}
+
+		if got := atomic.LoadInt64(&hits); got != int64(13*run) {
+			t.Errorf("run %d: the server got %d requests in all, want %d", run, got, 13*run)
+		}
+		if len(result.Results) != 13 || onResult != 13 {
+			t.Errorf("run %d: got %d results and %d OnResult calls, want 13", run, len(result.Results), onResult)
+		}
+		warmup := 0
+		for _, r := range result.Results {
+			if r.Warmup {
+				warmup++
+			}
+		}
+		if warmup != 3 || result.Metrics.TotalRequests != 10 || result.Metrics.SuccessRate != 100 {
+			t.Errorf("run %d: got %d warm-up results and metrics of %d requests at %g%%, want 3 and 10 at 100%%", run, warmup, result.Metrics.TotalRequests, result.Metrics.SuccessRate)
+		}
+		if result.Config.Method != "GET" {
+			t.Errorf("run %d: method = %q, want the default GET", run, result.Config.Method)
+		}
+
+		// The last progress report comes at the end of the run
+		if len(progress) == 0 {
+			t.Fatalf("run %d: OnProgress was not called", run)
+		}
+		last := progress[len(progress)-1]
+		if last.Requests != 10 || last.Warmup != 3 || last.Failed != 0 || last.Elapsed <= 0 {
+			t.Errorf("run %d: last progress = %+v, want 10 requests, 3 warm-up, none failed", run, last)
+		}
+	}
+}
+
+func TestRunnerRunCancel(t *testing.T) {
+	// The first requests are answered, the rest hang until the client gives up
+	var hits int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&hits, 1) > 3 {
+			<-r.Context().Done()
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	completed := 0
+	runner, err := NewRunner(Options{
+		Config: BenchmarkConfig{URL: ts.URL, Requests: 1000, Concurrency: 1, Duration: 30},
+		OnResult: func(metrics.RequestResult) {
+			if completed++; completed == 3 {
+				cancel()
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	result, err := runner.Run(ctx)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run() took %s, want it to stop when the context is cancelled", elapsed)
+	}
+
+	// The results so far are returned, without the request cut short
+	if len(result.Results) != 3 || result.Metrics.TotalRequests != 3 || result.Metrics.SuccessRate != 100 {
+		t.Errorf("got %d results and metrics of %d requests at %g%%, want 3 at 100%%", len(result.Results), result.Metrics.TotalRequests, result.Metrics.SuccessRate)
+	}
+}
+
+func TestRunBenchmark(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	results, err := RunBenchmark(&BenchmarkConfig{URL: ts.URL, Method: "GET", Requests: 5, Concurrency: 1, Duration: 5})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(results) != 5 {
+		t.Errorf("RunBenchmark() = %d results, want 5", len(results))
+	}
+}
@@ -0,0 +1,162 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// SearchConfig describes how to search for the highest load a service sustains
+// while still meeting the service level objectives.
+type SearchConfig struct {
+	Parameter string // "concurrency" or "rate"
+	Strategy  string // "step" or "binary"
+	Min       int
+	Max       int
+	Step      int // Increment for the step strategy, resolution for the binary strategy
+
+	// Service level objectives every step is evaluated against
+	LatencyPercentile float64       // 50, 90, 95 or 99
+	MaxLatency        time.Duration // 0 disables the latency objective
+	MaxErrorRate      float64       // Percentage of failed requests, negative disables the objective
+}
+
+// SearchStep is the outcome of one benchmark run during the search
+type SearchStep struct {
+	Value   int
+	Metrics metrics.AggregateMetrics
+	Latency time.Duration // The response time percentile the objective is checked against
+	Passed  bool
+	Reason  string // Why the step failed the objectives
+}
+
+// SearchResult holds every step of the search and the knee point, i.e. the
+// highest value that still met the objectives.
+type SearchResult struct {
+	Steps     []SearchStep
+	KneeFound bool
+	Knee      SearchStep
+}
+
+// Search runs the benchmark of the options repeatedly with increasing load
+// until the objectives are no longer met. Cancelling the context stops the
+// search after the step in progress.
+func Search(ctx context.Context, options Options, search SearchConfig) (SearchResult, error) {
+	return runSearch(search, func(value int) (metrics.AggregateMetrics, error) {
+		stepOptions := options
+		if search.Parameter == "rate" {
+			stepOptions.Config.Rate = value
+		} else {
+			stepOptions.Config.Concurrency = value
+		}
+
+		if options.Log != nil {
+			fmt.Fprintf(options.Log, "Search step: %s %d\n", search.Parameter, value)
+		}
+		return runStep(ctx, stepOptions)
+	})
+}
+
+// runSearch walks the values of the search with its strategy, measuring the
+// load at each value with measure, and finds the knee point.
+func runSearch(search SearchConfig, measure func(value int) (metrics.AggregateMetrics, error)) (SearchResult, error) {
+	var result SearchResult
+	var runErr error
+
+	run := func(value int) SearchStep {
+		aggregate, err := measure(value)
+		if err != nil {
+			runErr = err
+			return SearchStep{Value: value, Reason: err.Error()}
+		}
+		step := evaluateStep(value, aggregate, search)
+		result.Steps = append(result.Steps, step)
+
+		if step.Passed && (!result.KneeFound || step.Value > result.Knee.Value) {
+			result.KneeFound = true
+			result.Knee = step
+		}
+		return step
+	}
+
+	if search.Strategy == "binary" {
+		low, high := search.Min, search.Max
+		for low <= high && runErr == nil {
+			middle := low + (high-low)/2
+			if run(middle).Passed {
+				low = middle + search.Step
+			} else {
+				high = middle - search.Step
+			}
+		}
+	} else {
+		for value := search.Min; value <= search.Max; value += search.Step {
+			if !run(value).Passed {
+				break
+			}
+		}
+	}
+
+	if runErr != nil {
+		return result, runErr
+	}
+
+	// Keep the steps in load order so they can be drawn as a curve
+	sort.Slice(result.Steps, func(i, j int) bool { return result.Steps[i].Value < result.Steps[j].Value })
+	return result, nil
+}
+
+// runStep runs the benchmark of a single step, failing if it was cut short
+func runStep(ctx context.Context, options Options) (metrics.AggregateMetrics, error) {
+	runner, err := NewRunner(options)
+	if err != nil {
+		return metrics.AggregateMetrics{}, err
+	}
+	result, err := runner.Run(ctx)
+	if err == nil {
+		err = ctx.Err()
+	}
+	return result.Metrics, err
+}
+
+// evaluateStep checks the metrics of a single step against the objectives
+func evaluateStep(value int, aggregate metrics.AggregateMetrics, search SearchConfig) SearchStep {
+	step := SearchStep{
+		Value:   value,
+		Metrics: aggregate,
+		Latency: latencyPercentile(aggregate, search.LatencyPercentile),
+		Passed:  true,
+	}
+
+	errorRate := 100 - aggregate.SuccessRate
+	switch {
+	case aggregate.TotalRequests == 0:
+		step.Passed = false
+		step.Reason = "no requests completed"
+	case search.MaxErrorRate >= 0 && errorRate > search.MaxErrorRate:
+		step.Passed = false
+		step.Reason = fmt.Sprintf("error rate %.2f%% exceeds %.2f%%", errorRate, search.MaxErrorRate)
+	case search.MaxLatency > 0 && step.Latency > search.MaxLatency:
+		step.Passed = false
+		step.Reason = fmt.Sprintf("p%g response time %s exceeds %s", search.LatencyPercentile, step.Latency, search.MaxLatency)
+	}
+
+	return step
+}
+
+// latencyPercentile picks the given response time percentile from the metrics
+func latencyPercentile(aggregate metrics.AggregateMetrics, percentile float64) time.Duration {
+	switch percentile {
+	case 50:
+		return aggregate.P50Response
+	case 90:
+		return aggregate.P90Response
+	case 95:
+		return aggregate.P95Response
+	default:
+		return aggregate.P99Response
+	}
+}
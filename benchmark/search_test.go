@@ -0,0 +1,175 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// syntheticCurve stands in for a service whose p95 is 1ms per unit of load
+// and which starts failing requests above 70
+func syntheticCurve(value int) (metrics.AggregateMetrics, error) {
+	aggregate := metrics.AggregateMetrics{
+		TotalRequests:     100,
+		SuccessRate:       100,
+		RequestsPerSecond: float64(value) * 10,
+		P95Response:       time.Duration(value) * time.Millisecond,
+	}
+	if value > 70 {
+		aggregate.SuccessRate = 90
+	}
+	return aggregate, nil
+}
+
+func TestRunSearch(t *testing.T) {
+	tests := []struct {
+		name      string
+		search    SearchConfig
+		measure   func(value int) (metrics.AggregateMetrics, error)
+		wantSteps []int
+		wantKnee  int // 0 when no knee is found
+		wantErr   bool
+	}{
+		{
+			name:      "step stops at the latency breach",
+			search:    SearchConfig{Strategy: "step", Min: 10, Max: 100, Step: 10, LatencyPercentile: 95, MaxLatency: 50 * time.Millisecond, MaxErrorRate: -1},
+			wantSteps: []int{10, 20, 30, 40, 50, 60},
+			wantKnee:  50,
+		},
+		{
+			name:      "binary converges on the latency knee",
+			search:    SearchConfig{Strategy: "binary", Min: 10, Max: 100, Step: 1, LatencyPercentile: 95, MaxLatency: 50 * time.Millisecond, MaxErrorRate: -1},
+			wantSteps: []int{32, 43, 49, 50, 51, 52, 55},
+			wantKnee:  50,
+		},
+		{
+			name:      "step stops at the error rate breach",
+			search:    SearchConfig{Strategy: "step", Min: 50, Max: 100, Step: 10, LatencyPercentile: 95, MaxErrorRate: 1},
+			wantSteps: []int{50, 60, 70, 80},
+			wantKnee:  70,
+		},
+		{
+			name:      "every step within the objectives",
+			search:    SearchConfig{Strategy: "step", Min: 10, Max: 30, Step: 10, LatencyPercentile: 95, MaxErrorRate: 1},
+			wantSteps: []int{10, 20, 30},
+			wantKnee:  30,
+		},
+		{
+			name:      "no value meets the objectives",
+			search:    SearchConfig{Strategy: "step", Min: 10, Max: 100, Step: 10, LatencyPercentile: 95, MaxLatency: 5 * time.Millisecond, MaxErrorRate: -1},
+			wantSteps: []int{10},
+		},
+		{
+			name:   "no requests completed",
+			search: SearchConfig{Strategy: "step", Min: 10, Max: 100, Step: 10, LatencyPercentile: 95, MaxErrorRate: -1},
+			measure: func(value int) (metrics.AggregateMetrics, error) {
+				return metrics.AggregateMetrics{}, nil
+			},
+			wantSteps: []int{10},
+		},
+		{
+			name:   "failed run",
+			search: SearchConfig{Strategy: "step", Min: 10, Max: 100, Step: 10, LatencyPercentile: 95, MaxErrorRate: -1},
+			measure: func(value int) (metrics.AggregateMetrics, error) {
+				if value == 30 {
+					return metrics.AggregateMetrics{}, fmt.Errorf("connection refused")
+				}
+				return syntheticCurve(value)
+			},
+			wantSteps: []int{10, 20},
+			wantKnee:  20,
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			measure := tt.measure
+			if measure == nil {
+				measure = syntheticCurve
+			}
+			result, err := runSearch(tt.search, measure)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("runSearch() gotErr = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			var steps []int
+			for _, step := range result.Steps {
+				steps = append(steps, step.Value)
+			}
+			if !reflect.DeepEqual(steps, tt.wantSteps) {
+				t.Errorf("runSearch() steps = %v, want %v", steps, tt.wantSteps)
+			}
+			if result.KneeFound != (tt.wantKnee > 0) || result.Knee.Value != tt.wantKnee {
+				t.Errorf("runSearch() knee = %v %d, want %d", result.KneeFound, result.Knee.Value, tt.wantKnee)
+			}
+		})
+	}
+}
+
+func TestEvaluateStep(t *testing.T) {
+	search := SearchConfig{LatencyPercentile: 99, MaxLatency: 100 * time.Millisecond, MaxErrorRate: 1}
+	tests := []struct {
+		name       string
+		aggregate  metrics.AggregateMetrics
+		wantPassed bool
+		wantReason string
+	}{
+		{
+			name:       "within the objectives",
+			aggregate:  metrics.AggregateMetrics{TotalRequests: 100, SuccessRate: 99.5, P99Response: 80 * time.Millisecond},
+			wantPassed: true,
+		},
+		{
+			name:       "latency objective",
+			aggregate:  metrics.AggregateMetrics{TotalRequests: 100, SuccessRate: 100, P99Response: 150 * time.Millisecond},
+			wantReason: "p99 response time 150ms exceeds 100ms",
+		},
+		{
+			name:       "error rate objective",
+			aggregate:  metrics.AggregateMetrics{TotalRequests: 100, SuccessRate: 95, P99Response: 80 * time.Millisecond},
+			wantReason: "error rate 5.00% exceeds 1.00%",
+		},
+		{
+			name:       "no requests",
+			wantReason: "no requests completed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step := evaluateStep(10, tt.aggregate, search)
+			if step.Passed != tt.wantPassed || step.Reason != tt.wantReason {
+				t.Errorf("evaluateStep() = %v %q, want %v %q", step.Passed, step.Reason, tt.wantPassed, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSearch(t *testing.T) {
+	// Every step runs on an executor that answers at once
+	options := Options{
+		Config: BenchmarkConfig{URL: "http://service", Requests: 20, Duration: 5},
+		Executor: ExecutorFunc(func(ctx context.Context, work Work) metrics.RequestResult {
+			return metrics.RequestResult{RequestID: work.ID, StatusCode: 200, StartTime: time.Now(), ResponseTime: time.Millisecond}
+		}),
+	}
+	search := SearchConfig{Parameter: "concurrency", Strategy: "step", Min: 1, Max: 3, Step: 1, LatencyPercentile: 95, MaxErrorRate: 0}
+
+	result, err := Search(context.Background(), options, search)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(result.Steps) != 3 || !result.KneeFound || result.Knee.Value != 3 {
+		t.Fatalf("Search() = %d steps, knee %v %d, want 3 steps, knee 3", len(result.Steps), result.KneeFound, result.Knee.Value)
+	}
+	for _, step := range result.Steps {
+		if step.Metrics.TotalRequests != 20 {
+			t.Errorf("step %d made %d requests, want 20", step.Value, step.Metrics.TotalRequests)
+		}
+	}
+}
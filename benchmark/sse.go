@@ -0,0 +1,169 @@
+package benchmark
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// SSEConfig describes how the virtual users of the server-sent events mode
+// hold their streams
+type SSEConfig struct {
+	ReconnectDelay time.Duration // Wait before reopening a stream, unless the server sent a retry
+	NoReconnect    bool          // Open a single stream per virtual user
+}
+
+// runSSE opens a server-sent event stream for each of the Concurrency virtual
+// users of the config and holds it open for Duration, reopening it when it
+// ends. Every stream is one result. Requests is ignored.
+func runSSE(r *run, config *BenchmarkConfig, sse SSEConfig) ([]metrics.RequestResult, error) {
+	client, err := httpclient.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticate(config, client); err != nil {
+		return nil, err
+	}
+	r.logToken(config)
+
+	r.logf("Streaming server-sent events from %s with %d virtual users for %d seconds\n", describeTargets(config), config.Concurrency, config.Duration)
+
+	results := make(chan metrics.RequestResult, config.Concurrency)
+
+	go runStreamUsers(r.ctx, config, sse, client, results)
+
+	return append(r.collect(results), tokenFetchResults(client)...), nil
+}
+
+func runStreamUsers(ctx context.Context, config *BenchmarkConfig, sse SSEConfig, client *httpclient.Client, results chan<- metrics.RequestResult) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.Duration)*time.Second)
+	defer cancel()
+
+	// The picker is shared by the virtual users
+	picker := newTargetPicker(config)
+	var pickerMu sync.Mutex
+	pick := func() string {
+		pickerMu.Lock()
+		defer pickerMu.Unlock()
+		return picker.pick()
+	}
+
+	var wg sync.WaitGroup
+	var lastID int64
+	for user := 0; user < config.Concurrency; user++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lastEventID := ""
+			for reconnect := false; ; reconnect = true {
+				url := pick()
+				result, stream := performStream(ctx, client, int(atomic.AddInt64(&lastID, 1))-1, url, lastEventID)
+				if ctx.Err() != nil && !stream.Open() {
+					// The test ended while the stream was being opened
+					return
+				}
+				if picker.multiple() {
+					result.Target = url
+				}
+				result.Reconnect = reconnect
+				result.Concurrency = config.Concurrency
+				results <- result
+
+				if sse.NoReconnect || ctx.Err() != nil {
+					return
+				}
+				lastEventID = stream.LastEventID()
+				delay := stream.Retry()
+				if delay == 0 {
+					delay = sse.ReconnectDelay
+				}
+				pause := time.NewTimer(delay)
+				select {
+				case <-pause.C:
+				case <-ctx.Done():
+					pause.Stop()
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(results)
+}
+
+// performStream opens a stream and reads it until it ends or the context is
+// done, recording when each event arrived. The stream is returned closed.
+func performStream(ctx context.Context, client *httpclient.Client, i int, url, lastEventID string) (metrics.RequestResult, *httpclient.EventStream) {
+	startTime := time.Now()
+	stream, err := client.OpenEventStream(ctx, url, nil, lastEventID)
+	connectTime := time.Since(startTime)
+	defer stream.Close()
+
+	// The stream only started once its OAuth2 token was ready
+	response := stream.Response
+	startTime = startTime.Add(response.TokenWait)
+	connectTime -= response.TokenWait
+
+	result := metrics.RequestResult{
+		RequestID:    i,
+		Response:     response.Body,
+		StatusCode:   response.StatusCode,
+		ResponseTime: connectTime,
+		StartTime:    startTime,
+		Error:        err,
+		Protocol:     response.Protocol,
+		TLSVersion:   response.TLSVersion,
+		CipherSuite:  response.CipherSuite,
+
+		ConnectionID:     response.ConnectionID,
+		ConnectionReused: response.ConnectionReused,
+		Phases:           metrics.PhaseTimings(response.Phases),
+
+		BodySize:      response.BodySize,
+		ReceivedBytes: response.BodySize,
+
+		TokenWait: response.TokenWait,
+
+		Stream: true,
+	}
+	if !stream.Open() {
+		return result, stream
+	}
+
+	connected := time.Now()
+	lastEvent := connected
+	for {
+		_, err := stream.Next()
+		now := time.Now()
+		if err != nil {
+			// Streams still open at the end of the test are cut off, not disconnected
+			if ctx.Err() == nil {
+				result.Disconnected = true
+				result.Response = "Stream closed by the server"
+				if err != io.EOF {
+					result.Response = fmt.Sprintf("Stream broken: %v", err)
+				}
+			}
+			break
+		}
+
+		result.Events++
+		if result.Events == 1 {
+			result.FirstEvent = now.Sub(startTime)
+		} else {
+			result.EventGaps = append(result.EventGaps, now.Sub(lastEvent))
+		}
+		lastEvent = now
+	}
+	result.StreamTime = time.Since(connected)
+	result.BodySize = stream.ReceivedBytes()
+	result.ReceivedBytes = result.BodySize
+	return result, stream
+}
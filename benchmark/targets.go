@@ -0,0 +1,140 @@
+package benchmark
+
+import (
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported values of BenchmarkConfig.Distribution
+const (
+	DistributionRoundRobin = "round-robin" // Targets take turns
+	DistributionRandom     = "random"      // Every request picks a target uniformly at random
+	DistributionWeighted   = "weighted"    // Targets get requests in proportion to their weight
+)
+
+// Target is one of several URLs sharing the load of a benchmark
+type Target struct {
+	URL    string
+	Weight int // Share of the requests with the weighted distribution
+}
+
+// ParseTarget parses a target given as URL or URL;weight. The part after the
+// last semicolon is only a weight when it is a number, so URLs with commas or
+// semicolons of their own, e.g. in the query, are kept whole.
+func ParseTarget(value string) (Target, error) {
+	target := Target{URL: value, Weight: 1}
+	if i := strings.LastIndex(value, ";"); i >= 0 && isNumber(value[i+1:]) {
+		weight, err := strconv.Atoi(value[i+1:])
+		if err != nil || weight <= 0 {
+			return Target{}, fmt.Errorf("'%s' is not a valid target weight. Weights must be positive integers", value[i+1:])
+		}
+		target = Target{URL: value[:i], Weight: weight}
+	}
+	if target.URL == "" {
+		return Target{}, fmt.Errorf("the target URL cannot be empty")
+	}
+	return target, nil
+}
+
+// isNumber reports whether the value is made of digits only
+func isNumber(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// ValidateDistribution checks the distribution of the requests among the targets.
+func ValidateDistribution(distribution string) error {
+	switch distribution {
+	case "", DistributionRoundRobin, DistributionRandom, DistributionWeighted:
+		return nil
+	}
+	return fmt.Errorf("'%s' is not a valid distribution. Supported distributions are: round-robin, random, weighted", distribution)
+}
+
+// targets returns the targets of the config, or the URL as the only target
+func targets(config *BenchmarkConfig) []Target {
+	if len(config.Targets) > 0 {
+		return config.Targets
+	}
+	return []Target{{URL: config.URL, Weight: 1}}
+}
+
+// targetPicker chooses the target of each request. It is only used from the
+// dispatch loop and is not safe for concurrent use.
+type targetPicker struct {
+	targets      []Target
+	distribution string
+	next         int
+	random       *rand.Rand
+	totalWeight  int
+	current      []int // Smooth weighted round-robin state
+}
+
+func newTargetPicker(config *BenchmarkConfig) *targetPicker {
+	picker := &targetPicker{
+		targets:      append([]Target(nil), targets(config)...),
+		distribution: config.Distribution,
+		random:       rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	picker.current = make([]int, len(picker.targets))
+	for i, target := range picker.targets {
+		if target.Weight < 1 {
+			picker.targets[i].Weight = 1
+		}
+		picker.totalWeight += picker.targets[i].Weight
+	}
+	return picker
+}
+
+// multiple reports whether there is more than one target to tell apart in the results
+func (p *targetPicker) multiple() bool {
+	return len(p.targets) > 1
+}
+
+// pick returns the URL of the next target
+func (p *targetPicker) pick() string {
+	switch p.distribution {
+	case DistributionRandom:
+		return p.targets[p.random.Intn(len(p.targets))].URL
+
+	case DistributionWeighted:
+		// Smooth weighted round-robin interleaves the targets instead of
+		// sending runs of requests to the heaviest one
+		best := 0
+		for i, target := range p.targets {
+			p.current[i] += target.Weight
+			if p.current[i] > p.current[best] {
+				best = i
+			}
+		}
+		p.current[best] -= p.totalWeight
+		return p.targets[best].URL
+
+	default:
+		target := p.targets[p.next%len(p.targets)]
+		p.next++
+		return target.URL
+	}
+}
+
+// describeTargets names the target of the run for the console output
+func describeTargets(config *BenchmarkConfig) string {
+	if len(config.Targets) == 0 {
+		return config.URL
+	}
+	distribution := config.Distribution
+	if distribution == "" {
+		distribution = DistributionRoundRobin
+	}
+	return fmt.Sprintf("%d targets (%s)", len(config.Targets), distribution)
+}
@@ -0,0 +1,98 @@
+package benchmark
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestParseTarget(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Target
+		wantErr bool
+	}{
+		{name: "URL", value: "http://pod-1:8080", want: Target{URL: "http://pod-1:8080", Weight: 1}},
+		{name: "weighted", value: "http://pod-1:8080/api;3", want: Target{URL: "http://pod-1:8080/api", Weight: 3}},
+		{name: "commas in the query", value: "http://h/a?ids=1,2", want: Target{URL: "http://h/a?ids=1,2", Weight: 1}},
+		{name: "commas in the query and a weight", value: "http://h/a?ids=1,2;4", want: Target{URL: "http://h/a?ids=1,2", Weight: 4}},
+		{name: "semicolon parameter", value: "http://h/a;v=2", want: Target{URL: "http://h/a;v=2", Weight: 1}},
+		{name: "zero weight", value: "http://h/a;0", wantErr: true},
+		{name: "missing URL", value: ";2", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTarget(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseTarget() gotErr = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("ParseTarget() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTargetPickerRoundRobin(t *testing.T) {
+	picker := newTargetPicker(&BenchmarkConfig{Targets: []Target{{URL: "a"}, {URL: "b"}, {URL: "c"}}})
+	var got []string
+	for i := 0; i < 6; i++ {
+		got = append(got, picker.pick())
+	}
+	if want := []string{"a", "b", "c", "a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("pick() = %v, want %v", got, want)
+	}
+}
+
+func TestTargetPickerWeighted(t *testing.T) {
+	config := &BenchmarkConfig{
+		Targets:      []Target{{URL: "a", Weight: 5}, {URL: "b", Weight: 1}, {URL: "c", Weight: 1}},
+		Distribution: DistributionWeighted,
+	}
+	picker := newTargetPicker(config)
+
+	// Every cycle of the total weight matches the weights exactly, without
+	// a run of requests longer than needed to the heaviest target
+	var cycle []string
+	for i := 0; i < 7; i++ {
+		cycle = append(cycle, picker.pick())
+	}
+	if want := []string{"a", "a", "b", "a", "c", "a", "a"}; !reflect.DeepEqual(cycle, want) {
+		t.Errorf("pick() = %v, want %v", cycle, want)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 700; i++ {
+		counts[picker.pick()]++
+	}
+	if want := map[string]int{"a": 500, "b": 100, "c": 100}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("pick() counts = %v, want %v", counts, want)
+	}
+}
+
+func TestTargetPickerRandom(t *testing.T) {
+	config := &BenchmarkConfig{
+		Targets:      []Target{{URL: "a"}, {URL: "b"}, {URL: "c"}},
+		Distribution: DistributionRandom,
+	}
+	picker := newTargetPicker(config)
+	picker.random = rand.New(rand.NewSource(1))
+
+	// Weights do not matter, every target gets about a third of the requests
+	const picks = 30000
+	counts := map[string]int{}
+	for i := 0; i < picks; i++ {
+		counts[picker.pick()]++
+	}
+	if len(counts) != 3 {
+		t.Fatalf("pick() chose %v, want every target", counts)
+	}
+	for url, count := range counts {
+		if count < picks/3*95/100 || count > picks/3*105/100 {
+			t.Errorf("pick() chose %s %d times out of %d, want about a third", url, count, picks)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package benchmark
+
+import (
+	"crypto/rand"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// templatePlaceholder matches the placeholders of message templates:
+//
+//	{{id}}        number of the message in the run, unique across connections
+//	{{user}}      number of the virtual user or connection sending it
+//	{{seq}}       number of the message on its connection
+//	{{timestamp}} Unix time in milliseconds
+//	{{uuid}}      random UUID
+var templatePlaceholder = regexp.MustCompile(`\{\{\s*([a-z]*)\s*\}\}`)
+
+// templateValues are the values a template is rendered with
+type templateValues struct {
+	id, user, seq int64
+}
+
+// ValidateTemplate checks that a template only uses known placeholders.
+func ValidateTemplate(template string) error {
+	return validatePlaceholders(template, "id", "user", "seq", "timestamp", "uuid")
+}
+
+// validatePlaceholders checks that a template only uses the supported placeholders
+func validatePlaceholders(template string, supported ...string) error {
+	for _, match := range templatePlaceholder.FindAllStringSubmatch(template, -1) {
+		known := false
+		for _, name := range supported {
+			known = known || match[1] == name
+		}
+		if !known {
+			names := make([]string, len(supported))
+			for i, name := range supported {
+				names[i] = "{{" + name + "}}"
+			}
+			return fmt.Errorf("'%s' is not a valid placeholder. Supported placeholders are: %s", match[0], strings.Join(names, ", "))
+		}
+	}
+	return nil
+}
+
+// renderTemplate replaces the placeholders of the template
+func renderTemplate(template string, values templateValues) string {
+	return templatePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		switch templatePlaceholder.FindStringSubmatch(placeholder)[1] {
+		case "id":
+			return strconv.FormatInt(values.id, 10)
+		case "user":
+			return strconv.FormatInt(values.user, 10)
+		case "seq":
+			return strconv.FormatInt(values.seq, 10)
+		case "timestamp":
+			return strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10)
+		case "uuid":
+			return newUUID()
+		}
+		return placeholder
+	})
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,317 @@
+package benchmark
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/komuvill/api_benchmarker/grpcclient"
+	"github.com/komuvill/api_benchmarker/httpclient"
+)
+
+// ValidateConfig checks the config as a whole: the target, the client settings,
+// the traffic pattern, the adaptive concurrency and the request body. The
+// command line checks its flags with it and NewRunner checks the options of
+// library callers, so both reject the same configs.
+func ValidateConfig(config *BenchmarkConfig) error {
+	// Validate URL
+	if config.URL == "" && len(config.Targets) == 0 {
+		return fmt.Errorf("URL is required")
+	}
+	if config.URL != "" && len(config.Targets) > 0 {
+		return fmt.Errorf("use either a URL or targets, not both")
+	}
+	if err := ValidateDistribution(config.Distribution); err != nil {
+		return err
+	}
+
+	// Validate Method
+	validMethods := map[string]bool{
+		"GET":    true,
+		"POST":   true,
+		"PUT":    true,
+		"DELETE": true,
+	}
+
+	if _, valid := validMethods[config.Method]; !valid {
+		return fmt.Errorf("'%s' is not a valid HTTP method. Supported methods are: GET, POST, PUT, DELETE", config.Method)
+	}
+
+	if config.Rate < 0 {
+		return fmt.Errorf("rate cannot be negative")
+	}
+
+	// Validate TLS settings
+	for _, file := range []string{config.Client.CAFile, config.Client.CertFile, config.Client.KeyFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); os.IsNotExist(err) {
+			return fmt.Errorf("the TLS file does not exist: %s", file)
+		}
+	}
+	if err := httpclient.ValidateTLSOptions(config.Client); err != nil {
+		return err
+	}
+
+	// Validate protocol settings
+	if err := httpclient.ValidateProtocol(config.Client); err != nil {
+		return err
+	}
+	if config.Client.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("idle connections cannot be negative")
+	}
+	if config.Client.MaxConcurrentStreams > 0 && config.Client.Connections == 0 {
+		return fmt.Errorf("max streams requires the number of connections to be set")
+	}
+	if config.Client.Protocol == httpclient.ProtocolH2C && strings.HasPrefix(config.URL, "https://") {
+		return fmt.Errorf("h2c is cleartext HTTP/2 and cannot be used with an https URL")
+	}
+
+	// Validate proxy settings
+	if err := httpclient.ValidateProxy(config.Client); err != nil {
+		return err
+	}
+
+	// Validate connection targets
+	if config.Client.UnixSocket != "" {
+		if _, err := os.Stat(config.Client.UnixSocket); os.IsNotExist(err) {
+			return fmt.Errorf("the Unix socket does not exist: %s", config.Client.UnixSocket)
+		}
+	}
+	if err := httpclient.ValidateDialOptions(config.Client); err != nil {
+		return err
+	}
+
+	// Validate response body handling
+	if err := httpclient.ValidateBodyOptions(config.Client); err != nil {
+		return err
+	}
+	if err := httpclient.ValidateEncodings(config.Client); err != nil {
+		return err
+	}
+
+	// Validate redirect handling
+	if err := httpclient.ValidateRedirects(config.Client); err != nil {
+		return err
+	}
+	if config.RedirectHops && config.Client.NoRedirects {
+		return fmt.Errorf("redirect hops require redirects to be followed")
+	}
+
+	// Validate authentication and signing
+	if err := httpclient.ValidateAuth(config.Client); err != nil {
+		return err
+	}
+	if err := httpclient.ValidateSigning(config.Client); err != nil {
+		return err
+	}
+
+	// Validate traffic pattern
+	if config.Arrival != "" && config.Arrival != "uniform" && config.Arrival != "poisson" {
+		return fmt.Errorf("'%s' is not a valid arrival distribution. Supported distributions are: uniform, poisson", config.Arrival)
+	}
+	if config.Burst.Multiplier < 0 {
+		return fmt.Errorf("spike multiplier cannot be negative")
+	}
+	if config.Burst.Duration < 0 || config.Burst.At < 0 || config.Burst.Every < 0 {
+		return fmt.Errorf("spike timings cannot be negative")
+	}
+	if config.Burst.Every > 0 && config.Burst.Every < config.Burst.Duration {
+		return fmt.Errorf("spikes cannot repeat more often than they last")
+	}
+	if (config.Arrival == "poisson" || config.Burst.Enabled()) && config.Rate == 0 {
+		return fmt.Errorf("a rate is required for poisson arrivals and spikes")
+	}
+
+	// Validate adaptive concurrency
+	if config.AdaptiveMode != "" {
+		if config.AdaptiveMode != "aimd" && config.AdaptiveMode != "pid" {
+			return fmt.Errorf("'%s' is not a valid adaptive mode. Supported modes are: aimd, pid", config.AdaptiveMode)
+		}
+		if config.TargetP95 <= 0 {
+			return fmt.Errorf("a target p95 is required for adaptive concurrency")
+		}
+		if config.AdjustInterval <= 0 {
+			return fmt.Errorf("the adjust interval must be positive")
+		}
+		if config.MaxConcurrency < config.Concurrency {
+			return fmt.Errorf("max concurrency cannot be lower than the starting concurrency")
+		}
+	}
+
+	// Validate Body, the endpoints of a workload bring their own
+	bodies := 0
+	for _, set := range []bool{config.Body != "", len(config.Form) > 0, len(config.Multipart) > 0} {
+		if set {
+			bodies++
+		}
+	}
+	if bodies > 1 {
+		return fmt.Errorf("use only one of a body, a form and a multipart form")
+	}
+	if err := httpclient.ValidateBodyPattern(config.BodyPattern); err != nil {
+		return err
+	}
+	if config.BodySize > 0 {
+		if bodies > 0 {
+			return fmt.Errorf("a body size generates the body and cannot be combined with a body, a form or a multipart form")
+		}
+		if config.Client.RequestEncoding != "" || config.Client.Signing != "" {
+			return fmt.Errorf("a body size is streamed and cannot be compressed or signed, both read the whole body into memory")
+		}
+		bodies++
+	}
+	if config.ContentType != "" && config.Body == "" && config.BodySize == 0 {
+		return fmt.Errorf("the content type only applies to a body")
+	}
+	for _, field := range config.Multipart {
+		if field.Path == "" {
+			continue
+		}
+		if _, err := os.Stat(field.Path); os.IsNotExist(err) {
+			return fmt.Errorf("the file specified for the form field '%s' does not exist: %s", field.Name, field.Path)
+		}
+	}
+	if len(config.Workload) == 0 && (config.Method == "POST" || config.Method == "PUT" || config.Method == "PATCH") {
+		if bodies == 0 {
+			return fmt.Errorf("a request body is required for the %s method", config.Method)
+		}
+		if strings.HasPrefix(config.Body, "@") {
+			filePath := strings.TrimPrefix(config.Body, "@")
+			if _, err := os.Stat(filePath); os.IsNotExist(err) {
+				return fmt.Errorf("the file specified for the request body does not exist: %s", filePath)
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateWebSocket checks the config and messages of the WebSocket mode
+func ValidateWebSocket(config *BenchmarkConfig, ws *WebSocketConfig) error {
+	for _, rawURL := range targetURLs(config) {
+		if parsed, err := url.Parse(rawURL); err != nil || (parsed.Scheme != "ws" && parsed.Scheme != "wss") {
+			return fmt.Errorf("'%s' is not a WebSocket URL, use ws:// or wss://", rawURL)
+		}
+	}
+
+	if len(ws.Messages) == 0 {
+		return fmt.Errorf("at least one message or a messages file is required")
+	}
+	for _, message := range ws.Messages {
+		if err := ValidateMessage(message, ws.CorrelationField); err != nil {
+			return err
+		}
+	}
+	if ws.MessageRate < 0 {
+		return fmt.Errorf("the message rate cannot be negative")
+	}
+	if ws.ReplyTimeout <= 0 {
+		return fmt.Errorf("the reply timeout must be positive")
+	}
+	if config.Method != "GET" || config.Body != "" || config.BodySize > 0 || len(config.Form) > 0 || len(config.Multipart) > 0 {
+		return fmt.Errorf("connections are opened with GET and messages set with --message, a method or request body does not apply")
+	}
+	if len(config.Workload) > 0 || config.Rate > 0 || config.AdaptiveMode != "" || config.WarmupDuration > 0 || config.WarmupRequests > 0 || config.RedirectHops {
+		return fmt.Errorf("the websocket command does not support workloads, rates, adaptive concurrency, warm-up or redirect hops")
+	}
+	return nil
+}
+
+// ValidateSSE checks the config and reconnects of the server-sent events mode
+func ValidateSSE(config *BenchmarkConfig, sse *SSEConfig) error {
+	if sse.ReconnectDelay < 0 {
+		return fmt.Errorf("the reconnect delay cannot be negative")
+	}
+	if config.Method != "GET" || config.Body != "" || config.BodySize > 0 || len(config.Form) > 0 || len(config.Multipart) > 0 {
+		return fmt.Errorf("streams are opened with GET, a method or request body does not apply")
+	}
+	if len(config.Workload) > 0 || config.Rate > 0 || config.AdaptiveMode != "" || config.WarmupDuration > 0 || config.WarmupRequests > 0 || config.RedirectHops {
+		return fmt.Errorf("the sse command does not support workloads, rates, adaptive concurrency, warm-up or redirect hops")
+	}
+	return nil
+}
+
+// ValidateGRPC checks the config, targets and call of the gRPC mode
+func ValidateGRPC(config *BenchmarkConfig, call *GRPCConfig) error {
+	for _, target := range targetURLs(config) {
+		if err := grpcclient.ValidateTarget(target); err != nil {
+			return err
+		}
+	}
+	if err := grpcclient.ValidateMethod(call.Method); err != nil {
+		return err
+	}
+
+	if call.Message != "" && !json.Valid([]byte(call.Message)) {
+		return fmt.Errorf("the request message is not valid JSON")
+	}
+	if config.Method != "GET" || len(config.Form) > 0 || len(config.Multipart) > 0 || config.BodySize > 0 || config.ContentType != "" {
+		return fmt.Errorf("the request message is given as JSON with --body, an HTTP method, form, generated body or content type does not apply")
+	}
+	if len(config.Workload) > 0 || config.RedirectHops {
+		return fmt.Errorf("the grpc command does not support workloads or redirect hops")
+	}
+	client := config.Client
+	if client.BasicAuth != "" || client.BearerToken != "" || client.APIKey != "" || client.OAuth2TokenURL != "" || client.Signing != "" || client.RequestEncoding != "" || len(client.AcceptEncoding) > 0 {
+		return fmt.Errorf("HTTP authentication, signing and compression do not apply to gRPC calls, send credentials with --metadata")
+	}
+	return nil
+}
+
+// ValidateGraphQL checks the config and operations of the GraphQL mode. When
+// no operations are named, it sets them to every operation of the document.
+func ValidateGraphQL(config *BenchmarkConfig, graphQL *GraphQLConfig) error {
+	operations, err := httpclient.GraphQLOperations(graphQL.Query)
+	if err != nil {
+		return err
+	}
+	for _, operation := range graphQL.Operations {
+		found := false
+		for _, name := range operations {
+			found = found || (name == operation && name != "")
+		}
+		if !found {
+			return fmt.Errorf("'%s' is not an operation of the query file. Operations are: %s", operation, describeOperationNames(operations))
+		}
+	}
+	if len(graphQL.Operations) == 0 {
+		graphQL.Operations = operations
+	}
+
+	if graphQL.Variables != "" {
+		if err := ValidateVariables(graphQL.Variables); err != nil {
+			return err
+		}
+	}
+	if config.Method != "GET" || config.Body != "" || config.BodySize > 0 || len(config.Form) > 0 || len(config.Multipart) > 0 || config.ContentType != "" {
+		return fmt.Errorf("operations are posted as JSON with --query-file and --variables, a method, request body or content type does not apply")
+	}
+	if len(config.Workload) > 0 {
+		return fmt.Errorf("the graphql command does not support workloads, run several operations with --operation")
+	}
+	return nil
+}
+
+// targetURLs returns the URL of the config, or the URLs of its targets
+func targetURLs(config *BenchmarkConfig) []string {
+	if len(config.Targets) == 0 {
+		return []string{config.URL}
+	}
+	urls := make([]string, 0, len(config.Targets))
+	for _, target := range config.Targets {
+		urls = append(urls, target.URL)
+	}
+	return urls
+}
+
+// describeOperationNames lists the operations of a document for an error message
+func describeOperationNames(operations []string) string {
+	if len(operations) == 1 && operations[0] == "" {
+		return "a single anonymous operation, which is run without --operation"
+	}
+	return strings.Join(operations, ", ")
+}
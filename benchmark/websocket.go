@@ -0,0 +1,365 @@
+package benchmark
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/metrics"
+)
+
+// WebSocketConfig describes the messages the connections of the WebSocket mode send
+type WebSocketConfig struct {
+	Messages     []string      // Message templates, sent in turn
+	MessageRate  float64       // Messages per second per connection, 0 sends a message once the previous one is answered
+	ReplyTimeout time.Duration // Messages without a reply by then are unanswered
+
+	// JSON field, dotted for nested objects, that pairs replies with the
+	// messages they answer. A message without the field gets it set to its
+	// {{id}}. When empty, replies are paired with the messages in order.
+	CorrelationField string
+}
+
+// runWebSocket opens a WebSocket connection for each of the Concurrency
+// virtual users of the config and sends messages on it for Duration. Every
+// connection is one result. A connection that ends early is not reopened.
+// Requests is ignored.
+func runWebSocket(r *run, config *BenchmarkConfig, ws WebSocketConfig) ([]metrics.RequestResult, error) {
+	client, err := httpclient.NewClient(config.Client)
+	if err != nil {
+		return nil, err
+	}
+	if err := authenticate(config, client); err != nil {
+		return nil, err
+	}
+	r.logToken(config)
+
+	r.logf("Opening %d WebSocket connections to %s for %d seconds", config.Concurrency, describeTargets(config), config.Duration)
+	if ws.MessageRate > 0 {
+		r.logf(", sending %g messages per second on each\n", ws.MessageRate)
+	} else {
+		r.logf(", sending each message once the previous one is answered\n")
+	}
+
+	results := make(chan metrics.RequestResult, config.Concurrency)
+
+	go runWebSocketUsers(r.ctx, config, ws, client, results)
+
+	return append(r.collect(results), tokenFetchResults(client)...), nil
+}
+
+// ValidateMessage checks that a message template only uses known
+// placeholders and, when replies are correlated by a field, that it renders
+// to a JSON object the field can be read from or set in.
+func ValidateMessage(message, correlationField string) error {
+	if err := ValidateTemplate(message); err != nil {
+		return err
+	}
+	if correlationField == "" {
+		return nil
+	}
+	rendered := renderTemplate(message, templateValues{id: 1, user: 0, seq: 1})
+	if _, _, err := withCorrelationID(rendered, strings.Split(correlationField, "."), 1); err != nil {
+		return fmt.Errorf("the message %s cannot be correlated by '%s': %v", message, correlationField, err)
+	}
+	return nil
+}
+
+func runWebSocketUsers(ctx context.Context, config *BenchmarkConfig, ws WebSocketConfig, client *httpclient.Client, results chan<- metrics.RequestResult) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.Duration)*time.Second)
+	defer cancel()
+
+	picker := newTargetPicker(config)
+	var wg sync.WaitGroup
+	var lastID int64
+	for user := 0; user < config.Concurrency; user++ {
+		url := picker.pick()
+		wg.Add(1)
+		go func(user int, url string) {
+			defer wg.Done()
+			result, ok := performWebSocket(ctx, client, ws, user, url, &lastID)
+			if !ok {
+				// The test ended while the connection was being opened
+				return
+			}
+			if picker.multiple() {
+				result.Target = url
+			}
+			result.Concurrency = config.Concurrency
+			results <- result
+		}(user, url)
+	}
+
+	wg.Wait()
+	close(results)
+}
+
+// performWebSocket opens a connection and sends messages on it until the
+// context is done or the connection ends, timing the replies. It reports
+// false if the context ended before the connection was opened.
+func performWebSocket(ctx context.Context, client *httpclient.Client, ws WebSocketConfig, user int, url string, lastID *int64) (metrics.RequestResult, bool) {
+	startTime := time.Now()
+	socket, err := client.DialWebSocket(ctx, url, nil)
+	connectTime := time.Since(startTime)
+	if ctx.Err() != nil && !socket.Open() {
+		return metrics.RequestResult{}, false
+	}
+
+	// The connection only started once its OAuth2 token was ready
+	response := socket.Response
+	startTime = startTime.Add(response.TokenWait)
+	connectTime -= response.TokenWait
+
+	result := metrics.RequestResult{
+		RequestID:    user,
+		Response:     response.Body,
+		StatusCode:   response.StatusCode,
+		ResponseTime: connectTime,
+		StartTime:    startTime,
+		Error:        err,
+		Protocol:     response.Protocol,
+		TLSVersion:   response.TLSVersion,
+		CipherSuite:  response.CipherSuite,
+
+		ConnectionID: response.ConnectionID,
+		Phases:       metrics.PhaseTimings(response.Phases),
+
+		BodySize:      response.BodySize,
+		ReceivedBytes: response.BodySize,
+
+		TokenWait: response.TokenWait,
+
+		WebSocket: true,
+	}
+	if !socket.Open() {
+		return result, true
+	}
+	defer socket.Release()
+	connected := time.Now()
+
+	var path []string
+	if ws.CorrelationField != "" {
+		path = strings.Split(ws.CorrelationField, ".")
+	}
+	pending := &pendingMessages{}
+	answered := make(chan struct{}, 1)
+
+	// The reader pairs the replies with the pending messages until the connection ends
+	var roundTrips []time.Duration
+	var received int
+	var receivedBytes int64
+	readDone := make(chan error, 1)
+	go func() {
+		for {
+			message, err := socket.Receive()
+			if err != nil {
+				readDone <- err
+				return
+			}
+			received++
+			receivedBytes += int64(len(message))
+
+			key := ""
+			if path != nil {
+				var ok bool
+				if key, ok = correlationID(message, path); !ok {
+					continue
+				}
+			}
+			if roundTrip, ok := pending.answer(key, time.Now()); ok {
+				roundTrips = append(roundTrips, roundTrip)
+				select {
+				case answered <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	var readErr error
+	readEnded := false
+	nextSend := time.Now()
+send:
+	for seq := int64(1); ; seq++ {
+		id := atomic.AddInt64(lastID, 1)
+		message := renderTemplate(ws.Messages[(seq-1)%int64(len(ws.Messages))], templateValues{id: id, user: int64(user), seq: seq})
+		key := ""
+		if path != nil {
+			var err error
+			if message, key, err = withCorrelationID(message, path, id); err != nil {
+				result.Response = fmt.Sprintf("Message %d cannot be correlated: %v", seq, err)
+				break send
+			}
+		}
+
+		// Only a reply to this message lets the next one go in closed loop mode
+		select {
+		case <-answered:
+		default:
+		}
+		pending.add(key, time.Now())
+		if err := socket.Send(message); err != nil {
+			break send
+		}
+		result.MessagesSent++
+		result.SentBytes += int64(len(message))
+
+		// At a fixed rate a reply does not move the next message up
+		wait, reply := ws.ReplyTimeout, (<-chan struct{})(answered)
+		if ws.MessageRate > 0 {
+			nextSend = nextSend.Add(time.Duration(float64(time.Second) / ws.MessageRate))
+			wait, reply = time.Until(nextSend), nil
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-reply:
+		case <-ctx.Done():
+			timer.Stop()
+			break send
+		case readErr = <-readDone:
+			timer.Stop()
+			readEnded = true
+			break send
+		}
+		timer.Stop()
+		pending.expire(time.Now(), ws.ReplyTimeout)
+	}
+
+	if !readEnded {
+		// Close the connection normally and let the reader see the server's close
+		socket.Close()
+		readErr = <-readDone
+		if ctx.Err() == nil {
+			readEnded = true
+		}
+	}
+	if readEnded {
+		// The connection ended before the test did
+		result.CloseCode = httpclient.CloseCode(readErr)
+		result.AbnormalClose = result.CloseCode != httpclient.CloseNormal
+		if result.Response == "" {
+			result.Response = fmt.Sprintf("Connection closed: %v", readErr)
+		}
+	}
+
+	result.StreamTime = time.Since(connected)
+	result.MessagesReceived = received
+	result.RoundTrips = roundTrips
+	result.Unanswered = pending.unanswered
+	result.BodySize = receivedBytes
+	result.ReceivedBytes = receivedBytes
+	result.RequestBodySize = result.SentBytes
+	return result, true
+}
+
+// pendingMessages holds the messages waiting for a reply in the order they were sent
+type pendingMessages struct {
+	mu         sync.Mutex
+	messages   []pendingMessage
+	unanswered int
+}
+
+type pendingMessage struct {
+	key  string // Correlation ID, empty when replies are paired in order
+	sent time.Time
+}
+
+func (p *pendingMessages) add(key string, sent time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, pendingMessage{key: key, sent: sent})
+}
+
+// answer removes the message the reply with the key answers and returns its
+// round trip. Without correlation the oldest message is answered.
+func (p *pendingMessages) answer(key string, at time.Time) (time.Duration, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, message := range p.messages {
+		if message.key == key {
+			p.messages = append(p.messages[:i], p.messages[i+1:]...)
+			return at.Sub(message.sent), true
+		}
+	}
+	return 0, false
+}
+
+// expire counts the messages sent more than timeout ago as unanswered
+func (p *pendingMessages) expire(now time.Time, timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	expired := 0
+	for expired < len(p.messages) && now.Sub(p.messages[expired].sent) >= timeout {
+		expired++
+	}
+	p.unanswered += expired
+	p.messages = p.messages[expired:]
+}
+
+// correlationID returns the JSON encoding of the field at the path of a JSON
+// object message, so that 17 and "17" stay different IDs
+func correlationID(message string, path []string) (string, bool) {
+	var object map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&object); err != nil {
+		return "", false
+	}
+	for i, name := range path {
+		value, ok := object[name]
+		if !ok {
+			return "", false
+		}
+		if i == len(path)-1 {
+			encoded, err := json.Marshal(value)
+			return string(encoded), err == nil
+		}
+		if object, ok = value.(map[string]interface{}); !ok {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+// withCorrelationID returns the message with the field at the path set to
+// the ID unless it has one already, along with its correlation ID
+func withCorrelationID(message string, path []string, id int64) (string, string, error) {
+	if key, ok := correlationID(message, path); ok {
+		return message, key, nil
+	}
+
+	var root map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(message))
+	decoder.UseNumber()
+	if err := decoder.Decode(&root); err != nil {
+		return "", "", fmt.Errorf("the message is not a JSON object")
+	}
+	object := root
+	for _, name := range path[:len(path)-1] {
+		child, ok := object[name]
+		if !ok {
+			child = map[string]interface{}{}
+			object[name] = child
+		}
+		if object, ok = child.(map[string]interface{}); !ok {
+			return "", "", fmt.Errorf("the field '%s' is not an object", name)
+		}
+	}
+	object[path[len(path)-1]] = json.Number(strconv.FormatInt(id, 10))
+
+	var encoded bytes.Buffer
+	encoder := json.NewEncoder(&encoded)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(root); err != nil {
+		return "", "", err
+	}
+	return strings.TrimSuffix(encoded.String(), "\n"), strconv.FormatInt(id, 10), nil
+}
@@ -0,0 +1,69 @@
+package benchmark
+
+import (
+	"math/rand"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/workload"
+)
+
+// endpointSampler picks the endpoint of each request at random in proportion
+// to the endpoint weights. It is only used from the dispatch loop and is not
+// safe for concurrent use.
+type endpointSampler struct {
+	cumulative []int // Running total of the weights
+	random     *rand.Rand
+}
+
+// newEndpointSampler returns a sampler for the workload of the config, or nil if there is none
+func newEndpointSampler(config *BenchmarkConfig) *endpointSampler {
+	if len(config.Workload) == 0 {
+		return nil
+	}
+
+	sampler := &endpointSampler{
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+	total := 0
+	for _, endpoint := range config.Workload {
+		weight := endpoint.Weight
+		if weight < 1 {
+			weight = 1
+		}
+		total += weight
+		sampler.cumulative = append(sampler.cumulative, total)
+	}
+	return sampler
+}
+
+// endpointHeaders returns the headers of every endpoint of the workload
+func endpointHeaders(endpoints []workload.Endpoint) []http.Header {
+	headers := make([]http.Header, len(endpoints))
+	for i, endpoint := range endpoints {
+		headers[i] = http.Header{}
+		for name, value := range endpoint.Headers {
+			headers[i].Set(name, value)
+		}
+	}
+	return headers
+}
+
+// pick returns the index of the next endpoint
+func (s *endpointSampler) pick() int {
+	n := s.random.Intn(s.cumulative[len(s.cumulative)-1])
+	return sort.SearchInts(s.cumulative, n+1)
+}
+
+// endpointBody returns the body of the endpoint, or nil if there is none
+func endpointBody(endpoint workload.Endpoint) bodyFunc {
+	if endpoint.Body == "" {
+		return nil
+	}
+	return func() (httpclient.Body, func(), error) {
+		reader, cleanup, err := httpclient.GetRequestBody(endpoint.Body)
+		return httpclient.Body{Reader: reader}, cleanup, err
+	}
+}
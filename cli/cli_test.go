@@ -1,9 +1,15 @@
 package cli
 
 import (
+	"os"
+	"reflect"
 	"testing"
+	"time"
 
 	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/komuvill/api_benchmarker/workload"
+	"google.golang.org/grpc/metadata"
 )
 
 // TestValidateFlags tests the validation logic for command-line flags.
@@ -52,6 +58,436 @@ func TestValidateFlags(t *testing.T) {
 			wantErr: true,
 			errMsg:  "a request body is required for the POST method",
 		},
+		{
+			name: "invalid TLS version",
+			config: benchmark.BenchmarkConfig{
+				URL:    "https://example.com",
+				Method: "GET",
+				Client: httpclient.Options{MinTLSVersion: "1.4"},
+			},
+			wantErr: true,
+			errMsg:  "'1.4' is not a valid TLS version. Supported versions are: 1.0, 1.1, 1.2, 1.3",
+		},
+		{
+			name: "client certificate without key",
+			config: benchmark.BenchmarkConfig{
+				URL:    "https://example.com",
+				Method: "GET",
+				Client: httpclient.Options{CertFile: "cli_test.go"},
+			},
+			wantErr: true,
+			errMsg:  "both a client certificate and its key are required for mTLS",
+		},
+		{
+			name: "unknown cipher suite",
+			config: benchmark.BenchmarkConfig{
+				URL:    "https://example.com",
+				Method: "GET",
+				Client: httpclient.Options{CipherSuites: []string{"TLS_ROT13"}},
+			},
+			wantErr: true,
+			errMsg:  "'TLS_ROT13' is not a known cipher suite",
+		},
+		{
+			name: "invalid protocol",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Protocol: "spdy"},
+			},
+			wantErr: true,
+			errMsg:  "'spdy' is not a valid protocol. Supported protocols are: http1, http2, h2c, auto",
+		},
+		{
+			name: "h2c with https",
+			config: benchmark.BenchmarkConfig{
+				URL:    "https://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Protocol: "h2c"},
+			},
+			wantErr: true,
+			errMsg:  "h2c is cleartext HTTP/2 and cannot be used with an https URL",
+		},
+		{
+			name: "unsupported proxy scheme",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Proxy: "ftp://proxy:21"},
+			},
+			wantErr: true,
+			errMsg:  "'ftp' is not a supported proxy scheme. Supported schemes are: http, https, socks5, socks5h",
+		},
+		{
+			name: "proxy with h2c",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Proxy: "socks5://proxy:1080", Protocol: "h2c"},
+			},
+			wantErr: true,
+			errMsg:  "proxies are not supported with h2c",
+		},
+		{
+			name: "POST from a workload without a body flag",
+			config: benchmark.BenchmarkConfig{
+				URL:      "http://example.com",
+				Method:   "POST",
+				Workload: []workload.Endpoint{{Name: "create", Method: "POST", Path: "/items", Body: "{}", Weight: 1}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "URL and targets",
+			config: benchmark.BenchmarkConfig{
+				URL:     "http://example.com",
+				Targets: []benchmark.Target{{URL: "http://pod-1:8080", Weight: 1}},
+				Method:  "GET",
+			},
+			wantErr: true,
+			errMsg:  "use either a URL or targets, not both",
+		},
+		{
+			name: "invalid distribution",
+			config: benchmark.BenchmarkConfig{
+				Targets:      []benchmark.Target{{URL: "http://pod-1:8080", Weight: 1}},
+				Distribution: "least-loaded",
+				Method:       "GET",
+			},
+			wantErr: true,
+			errMsg:  "'least-loaded' is not a valid distribution. Supported distributions are: round-robin, random, weighted",
+		},
+		{
+			name: "valid weighted targets",
+			config: benchmark.BenchmarkConfig{
+				Targets:      []benchmark.Target{{URL: "http://pod-1:8080", Weight: 1}, {URL: "http://pod-2:8080", Weight: 3}},
+				Distribution: "weighted",
+				Method:       "GET",
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid body mode",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{BodyMode: "truncate"},
+			},
+			wantErr: true,
+			errMsg:  "'truncate' is not a valid body mode. Supported modes are: keep, discard, hash, failed, sample",
+		},
+		{
+			name: "body sample rate above 1",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{BodyMode: "sample", BodySampleRate: 5},
+			},
+			wantErr: true,
+			errMsg:  "the body sample rate must be between 0 and 1",
+		},
+		{
+			name: "unsupported accept encoding",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{AcceptEncoding: []string{"gzip", "lzma"}},
+			},
+			wantErr: true,
+			errMsg:  "'lzma' is not a supported encoding. Supported encodings are: gzip, br, zstd",
+		},
+		{
+			name: "valid compression",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "POST",
+				Body:   `{"name": "x"}`,
+				Client: httpclient.Options{AcceptEncoding: []string{"br", "zstd"}, RequestEncoding: "gzip"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "negative max redirects",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{MaxRedirects: -1},
+			},
+			wantErr: true,
+			errMsg:  "max redirects cannot be negative",
+		},
+		{
+			name: "redirect hops without redirects",
+			config: benchmark.BenchmarkConfig{
+				URL:          "http://example.com",
+				Method:       "GET",
+				Client:       httpclient.Options{NoRedirects: true},
+				RedirectHops: true,
+			},
+			wantErr: true,
+			errMsg:  "redirect hops require redirects to be followed",
+		},
+		{
+			name: "two kinds of authentication",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{BasicAuth: "user:pass", BearerToken: "token"},
+			},
+			wantErr: true,
+			errMsg:  "use only one of basic auth, a bearer token, an API key and OAuth2",
+		},
+		{
+			name: "invalid API key",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{APIKey: "secret"},
+			},
+			wantErr: true,
+			errMsg:  "'secret' is not a valid API key, use name=value",
+		},
+		{
+			name: "OAuth2 without client ID",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{OAuth2TokenURL: "https://auth.example.com/token"},
+			},
+			wantErr: true,
+			errMsg:  "OAuth2 requires a client ID",
+		},
+		{
+			name: "valid OAuth2",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{OAuth2TokenURL: "https://auth.example.com/token", OAuth2ClientID: "bench", OAuth2ClientSecret: "secret", OAuth2Scopes: []string{"read"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid signing method",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Signing: "rsa"},
+			},
+			wantErr: true,
+			errMsg:  "'rsa' is not a valid signing method. Supported methods are: hmac, aws-sigv4",
+		},
+		{
+			name: "invalid HMAC placeholder",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Signing: "hmac", HMACSecret: "s", HMACCanonical: "{method}\\n{date}"},
+			},
+			wantErr: true,
+			errMsg:  "'{date}' is not a valid placeholder. Supported placeholders are: {method}, {path}, {query}, {host}, {timestamp}, {body_sha256}, {header:Name}",
+		},
+		{
+			name: "AWS signing without region",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Signing: "aws-sigv4", AWSService: "execute-api", AWSAccessKeyID: "id", AWSSecretAccessKey: "secret"},
+			},
+			wantErr: true,
+			errMsg:  "AWS signing requires a region and a service",
+		},
+		{
+			name: "valid AWS signing",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Signing: "aws-sigv4", AWSRegion: "eu-west-1", AWSService: "execute-api", AWSAccessKeyID: "id", AWSSecretAccessKey: "secret"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "body and form",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "POST",
+				Body:   `{"name": "x"}`,
+				Form:   []httpclient.FormField{{Name: "name", Value: "x"}},
+			},
+			wantErr: true,
+			errMsg:  "use only one of a body, a form and a multipart form",
+		},
+		{
+			name: "missing multipart file",
+			config: benchmark.BenchmarkConfig{
+				URL:       "http://example.com",
+				Method:    "POST",
+				Multipart: []httpclient.FormField{{Name: "upload", Path: "/nonexistent/file.png"}},
+			},
+			wantErr: true,
+			errMsg:  "the file specified for the form field 'upload' does not exist: /nonexistent/file.png",
+		},
+		{
+			name: "content type without body",
+			config: benchmark.BenchmarkConfig{
+				URL:         "http://example.com",
+				Method:      "POST",
+				ContentType: "text/plain",
+				Form:        []httpclient.FormField{{Name: "name", Value: "x"}},
+			},
+			wantErr: true,
+			errMsg:  "the content type only applies to a body",
+		},
+		{
+			name: "body size with a body",
+			config: benchmark.BenchmarkConfig{
+				URL:      "http://example.com",
+				Method:   "POST",
+				Body:     "{}",
+				BodySize: 1 << 20,
+			},
+			wantErr: true,
+			errMsg:  "a body size generates the body and cannot be combined with a body, a form or a multipart form",
+		},
+		{
+			name: "body size with request compression",
+			config: benchmark.BenchmarkConfig{
+				URL:      "http://example.com",
+				Method:   "POST",
+				BodySize: 1 << 20,
+				Client:   httpclient.Options{RequestEncoding: "gzip"},
+			},
+			wantErr: true,
+			errMsg:  "a body size is streamed and cannot be compressed or signed, both read the whole body into memory",
+		},
+		{
+			name: "invalid body pattern",
+			config: benchmark.BenchmarkConfig{
+				URL:         "http://example.com",
+				Method:      "POST",
+				BodySize:    1 << 20,
+				BodyPattern: "ones",
+			},
+			wantErr: true,
+			errMsg:  "'ones' is not a valid body pattern. Supported patterns are: zero, random",
+		},
+		{
+			name: "valid body size",
+			config: benchmark.BenchmarkConfig{
+				URL:         "http://example.com",
+				Method:      "PUT",
+				BodySize:    500 << 20,
+				BodyPattern: "random",
+				ContentType: "video/mp4",
+				Client:      httpclient.Options{Chunked: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid form",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "POST",
+				Form:   []httpclient.FormField{{Name: "name", Value: "x"}},
+			},
+			wantErr: false,
+		},
+		{
+			name: "valid redirect hops",
+			config: benchmark.BenchmarkConfig{
+				URL:          "http://example.com",
+				Method:       "GET",
+				Client:       httpclient.Options{MaxRedirects: 3},
+				RedirectHops: true,
+			},
+			wantErr: false,
+		},
+		{
+			name: "missing unix socket",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://localhost/health",
+				Method: "GET",
+				Client: httpclient.Options{UnixSocket: "/nonexistent/app.sock"},
+			},
+			wantErr: true,
+			errMsg:  "the Unix socket does not exist: /nonexistent/app.sock",
+		},
+		{
+			name: "invalid resolve entry",
+			config: benchmark.BenchmarkConfig{
+				URL:    "http://example.com",
+				Method: "GET",
+				Client: httpclient.Options{Resolve: []string{"example.com:80"}},
+			},
+			wantErr: true,
+			errMsg:  "'example.com:80' is not a valid resolve entry. The format is host:port:address",
+		},
+		{
+			name: "valid resolve and connect-to",
+			config: benchmark.BenchmarkConfig{
+				URL:    "https://example.com",
+				Method: "GET",
+				Client: httpclient.Options{
+					Resolve:   []string{"example.com:443:[::1]"},
+					ConnectTo: []string{"example.com:443:backend-2:8443"},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name: "spikes without a rate",
+			config: benchmark.BenchmarkConfig{
+				URL:     "http://example.com",
+				Method:  "GET",
+				Arrival: "uniform",
+				Burst:   benchmark.BurstProfile{Multiplier: 5, Duration: 10 * time.Second, Every: time.Minute},
+			},
+			wantErr: true,
+			errMsg:  "a rate is required for poisson arrivals and spikes",
+		},
+		{
+			name: "valid poisson spikes",
+			config: benchmark.BenchmarkConfig{
+				URL:     "http://example.com",
+				Method:  "GET",
+				Rate:    100,
+				Arrival: "poisson",
+				Burst:   benchmark.BurstProfile{Multiplier: 5, Duration: 10 * time.Second, At: 30 * time.Second},
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid adaptive mode",
+			config: benchmark.BenchmarkConfig{
+				URL:          "http://example.com",
+				Method:       "GET",
+				AdaptiveMode: "fast",
+			},
+			wantErr: true,
+			errMsg:  "'fast' is not a valid adaptive mode. Supported modes are: aimd, pid",
+		},
+		{
+			name: "adaptive mode without target",
+			config: benchmark.BenchmarkConfig{
+				URL:          "http://example.com",
+				Method:       "GET",
+				AdaptiveMode: "aimd",
+			},
+			wantErr: true,
+			errMsg:  "a target p95 is required for adaptive concurrency",
+		},
+		{
+			name: "valid adaptive configuration",
+			config: benchmark.BenchmarkConfig{
+				URL:            "http://example.com",
+				Method:         "GET",
+				Concurrency:    10,
+				AdaptiveMode:   "pid",
+				TargetP95:      250 * time.Millisecond,
+				MaxConcurrency: 100,
+				AdjustInterval: time.Second,
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -67,3 +503,368 @@ func TestValidateFlags(t *testing.T) {
 		})
 	}
 }
+
+// TestParseWarmup tests parsing the warm-up phase from the --warmup flag.
+func TestParseWarmup(t *testing.T) {
+	tests := []struct {
+		name         string
+		warmup       string
+		wantDuration time.Duration
+		wantRequests int
+		wantErr      bool
+	}{
+		{name: "no warm-up", warmup: ""},
+		{name: "duration", warmup: "15s", wantDuration: 15 * time.Second},
+		{name: "request count", warmup: "500", wantRequests: 500},
+		{name: "negative count", warmup: "-5", wantErr: true},
+		{name: "invalid value", warmup: "soon", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config benchmark.BenchmarkConfig
+			err := parseWarmup(tt.warmup, &config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseWarmup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if config.WarmupDuration != tt.wantDuration || config.WarmupRequests != tt.wantRequests {
+				t.Errorf("parseWarmup() = %s / %d requests, want %s / %d requests", config.WarmupDuration, config.WarmupRequests, tt.wantDuration, tt.wantRequests)
+			}
+		})
+	}
+}
+
+// TestParseSize tests parsing the --body-size flag.
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "", want: 0},
+		{value: "1024", want: 1024},
+		{value: "100B", want: 100},
+		{value: "512KB", want: 512 << 10},
+		{value: "500MB", want: 500 << 20},
+		{value: "2gb", want: 2 << 30},
+		{value: "0", wantErr: true},
+		{value: "-1MB", wantErr: true},
+		{value: "1.5GB", wantErr: true},
+		{value: "big", wantErr: true},
+		{value: "9223372036854775807GB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := parseSize(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestParseTargets tests parsing the targets from the --target flags.
+func TestParseTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets []string
+		want    []benchmark.Target
+		wantErr bool
+	}{
+		{name: "no targets", targets: nil},
+		{
+			name:    "unweighted and weighted",
+			targets: []string{"http://pod-1:8080", "http://pod-2:8080/api;3"},
+			want:    []benchmark.Target{{URL: "http://pod-1:8080", Weight: 1}, {URL: "http://pod-2:8080/api", Weight: 3}},
+		},
+		{name: "zero weight", targets: []string{"http://pod-1:8080;0"}, wantErr: true},
+		{name: "weight too large", targets: []string{"http://pod-1:8080;99999999999999999999"}, wantErr: true},
+		{name: "missing URL", targets: []string{";2"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var config benchmark.BenchmarkConfig
+			err := parseTargets(tt.targets, &config)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTargets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(config.Targets, tt.want) {
+				t.Errorf("parseTargets() = %v, want %v", config.Targets, tt.want)
+			}
+		})
+	}
+}
+
+// TestLoadWorkload tests reading the endpoints from the --workload file.
+func TestLoadWorkload(t *testing.T) {
+	path := t.TempDir() + "/workload.json"
+	os.WriteFile(path, []byte(`{"endpoints": [{"path": "/items", "weight": 7}, {"method": "DELETE", "path": "/items/1"}]}`), 0600)
+
+	var config benchmark.BenchmarkConfig
+	if err := loadWorkload(path, &config); err != nil {
+		t.Fatalf("loadWorkload() error = %v", err)
+	}
+	if len(config.Workload) != 2 || config.Workload[1].Name != "DELETE /items/1" {
+		t.Errorf("loadWorkload() = %+v", config.Workload)
+	}
+
+	if err := loadWorkload("/nonexistent/workload.json", &config); err == nil || err.Error() != "the workload file does not exist: /nonexistent/workload.json" {
+		t.Errorf("loadWorkload() error = %v", err)
+	}
+}
+
+// TestValidateSearchFlags tests the validation of the search command flags.
+func TestValidateSearchFlags(t *testing.T) {
+	valid := benchmark.SearchConfig{
+		Parameter:         "concurrency",
+		Strategy:          "step",
+		Min:               10,
+		Max:               100,
+		Step:              10,
+		LatencyPercentile: 99,
+		MaxLatency:        250 * time.Millisecond,
+		MaxErrorRate:      1,
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*benchmark.SearchConfig)
+		errMsg string
+	}{
+		{name: "valid configuration", modify: func(s *benchmark.SearchConfig) {}},
+		{name: "invalid parameter", modify: func(s *benchmark.SearchConfig) { s.Parameter = "users" }, errMsg: "'users' is not a valid search parameter. Supported parameters are: concurrency, rate"},
+		{name: "invalid strategy", modify: func(s *benchmark.SearchConfig) { s.Strategy = "random" }, errMsg: "'random' is not a valid search strategy. Supported strategies are: step, binary"},
+		{name: "inverted range", modify: func(s *benchmark.SearchConfig) { s.Min = 200 }, errMsg: "the search range must satisfy 1 <= min <= max"},
+		{name: "unsupported percentile", modify: func(s *benchmark.SearchConfig) { s.LatencyPercentile = 75 }, errMsg: "'75' is not a supported percentile. Supported percentiles are: 50, 90, 95, 99"},
+		{name: "no objectives", modify: func(s *benchmark.SearchConfig) { s.MaxLatency = 0; s.MaxErrorRate = -1 }, errMsg: "at least one objective is required, set --slo-latency or --slo-error-rate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			search := valid
+			tt.modify(&search)
+			err := validateSearchFlags(&search)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("validateSearchFlags() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("validateSearchFlags() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+// TestValidateReplayFlags tests the validation of the replay command flags.
+func TestValidateReplayFlags(t *testing.T) {
+	logFile, err := os.CreateTemp("", "access.log")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	logFile.Close()
+	defer os.Remove(logFile.Name())
+
+	tests := []struct {
+		name    string
+		options replayOptions
+		errMsg  string
+	}{
+		{name: "valid options", options: replayOptions{LogFile: logFile.Name(), Format: "combined", Speed: 2, IncludePath: "^/api"}},
+		{name: "missing log file", options: replayOptions{Format: "combined", Speed: 1}, errMsg: "a log file is required"},
+		{name: "invalid format", options: replayOptions{LogFile: logFile.Name(), Format: "xml", Speed: 1}, errMsg: "'xml' is not a valid log format. Supported formats are: common, combined, jsonl"},
+		{name: "zero speed", options: replayOptions{LogFile: logFile.Name(), Format: "jsonl"}, errMsg: "replay speed must be positive"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateReplayFlags(&tt.options)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("validateReplayFlags() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("validateReplayFlags() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+// TestValidateSSEFlags tests the validation of the sse command flags.
+func TestValidateSSEFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		config benchmark.BenchmarkConfig
+		sse    benchmark.SSEConfig
+		errMsg string
+	}{
+		{name: "valid configuration", config: benchmark.BenchmarkConfig{Method: "GET"}, sse: benchmark.SSEConfig{ReconnectDelay: time.Second}},
+		{name: "negative reconnect delay", config: benchmark.BenchmarkConfig{Method: "GET"}, sse: benchmark.SSEConfig{ReconnectDelay: -time.Second}, errMsg: "the reconnect delay cannot be negative"},
+		{name: "request body", config: benchmark.BenchmarkConfig{Method: "POST", Body: "{}"}, errMsg: "streams are opened with GET, a method or request body does not apply"},
+		{name: "rate", config: benchmark.BenchmarkConfig{Method: "GET", Rate: 10}, errMsg: "the sse command does not support workloads, rates, adaptive concurrency, warm-up or redirect hops"},
+		{name: "warm-up", config: benchmark.BenchmarkConfig{Method: "GET", WarmupRequests: 10}, errMsg: "the sse command does not support workloads, rates, adaptive concurrency, warm-up or redirect hops"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := benchmark.ValidateSSE(&tt.config, &tt.sse)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("ValidateSSE() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("ValidateSSE() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateWebSocketFlags(t *testing.T) {
+	valid := benchmark.WebSocketConfig{Messages: []string{`{"op":"ping","n":{{seq}}}`}, ReplyTimeout: time.Second}
+	tests := []struct {
+		name   string
+		config benchmark.BenchmarkConfig
+		ws     benchmark.WebSocketConfig
+		errMsg string
+	}{
+		{name: "valid configuration", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: valid},
+		{name: "valid targets", config: benchmark.BenchmarkConfig{Targets: []benchmark.Target{{URL: "wss://a.example/ws"}, {URL: "ws://b.example/ws"}}, Method: "GET"}, ws: valid},
+		{name: "correlated by a nested field", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{Messages: valid.Messages, CorrelationField: "meta.id", ReplyTimeout: time.Second}},
+		{name: "HTTP URL", config: benchmark.BenchmarkConfig{URL: "http://localhost:8080/ws", Method: "GET"}, ws: valid, errMsg: "'http://localhost:8080/ws' is not a WebSocket URL, use ws:// or wss://"},
+		{name: "HTTP target", config: benchmark.BenchmarkConfig{Targets: []benchmark.Target{{URL: "ws://a.example/ws"}, {URL: "https://b.example/ws"}}, Method: "GET"}, ws: valid, errMsg: "'https://b.example/ws' is not a WebSocket URL, use ws:// or wss://"},
+		{name: "no messages", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{ReplyTimeout: time.Second}, errMsg: "at least one message or a messages file is required"},
+		{name: "unknown placeholder", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{Messages: []string{"hello {{name}}"}, ReplyTimeout: time.Second}, errMsg: "'{{name}}' is not a valid placeholder. Supported placeholders are: {{id}}, {{user}}, {{seq}}, {{timestamp}}, {{uuid}}"},
+		{name: "correlated text message", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{Messages: []string{"ping"}, CorrelationField: "id", ReplyTimeout: time.Second}, errMsg: "the message ping cannot be correlated by 'id': the message is not a JSON object"},
+		{name: "negative message rate", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{Messages: valid.Messages, MessageRate: -1, ReplyTimeout: time.Second}, errMsg: "the message rate cannot be negative"},
+		{name: "zero reply timeout", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET"}, ws: benchmark.WebSocketConfig{Messages: valid.Messages}, errMsg: "the reply timeout must be positive"},
+		{name: "request body", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "POST", Body: "{}"}, ws: valid, errMsg: "connections are opened with GET and messages set with --message, a method or request body does not apply"},
+		{name: "adaptive concurrency", config: benchmark.BenchmarkConfig{URL: "ws://localhost:8080/ws", Method: "GET", AdaptiveMode: "latency"}, ws: valid, errMsg: "the websocket command does not support workloads, rates, adaptive concurrency, warm-up or redirect hops"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := benchmark.ValidateWebSocket(&tt.config, &tt.ws)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("ValidateWebSocket() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("ValidateWebSocket() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestValidateGRPCFlags(t *testing.T) {
+	tests := []struct {
+		name   string
+		config benchmark.BenchmarkConfig
+		call   benchmark.GRPCConfig
+		errMsg string
+	}{
+		{name: "valid configuration", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "GET"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello", Message: `{"name": "world"}`}},
+		{name: "valid TLS targets", config: benchmark.BenchmarkConfig{Targets: []benchmark.Target{{URL: "grpcs://a.example"}, {URL: "grpcs://b.example:8443"}}, Method: "GET"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter.SayHello"}},
+		{name: "HTTP URL", config: benchmark.BenchmarkConfig{URL: "http://localhost:50051", Method: "GET"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello"}, errMsg: "'http://localhost:50051' is not a gRPC target, use grpc://host:port or grpcs://host:port"},
+		{name: "address without scheme", config: benchmark.BenchmarkConfig{URL: "localhost:50051", Method: "GET"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello"}, errMsg: "'localhost:50051' is not a gRPC target, use grpc://host:port or grpcs://host:port"},
+		{name: "method without service", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "GET"}, call: benchmark.GRPCConfig{Method: "SayHello"}, errMsg: "'SayHello' is not a valid method name, use package.Service/Method"},
+		{name: "invalid JSON message", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "GET"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello", Message: `{"name": }`}, errMsg: "the request message is not valid JSON"},
+		{name: "HTTP method", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "POST"}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello"}, errMsg: "the request message is given as JSON with --body, an HTTP method, form, generated body or content type does not apply"},
+		{name: "redirect hops", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "GET", RedirectHops: true}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello"}, errMsg: "the grpc command does not support workloads or redirect hops"},
+		{name: "bearer token", config: benchmark.BenchmarkConfig{URL: "grpc://localhost:50051", Method: "GET", Client: httpclient.Options{BearerToken: "secret"}}, call: benchmark.GRPCConfig{Method: "helloworld.Greeter/SayHello"}, errMsg: "HTTP authentication, signing and compression do not apply to gRPC calls, send credentials with --metadata"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := benchmark.ValidateGRPC(&tt.config, &tt.call)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("ValidateGRPC() unexpected error = %v", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("ValidateGRPC() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		name    string
+		pairs   []string
+		want    metadata.MD
+		wantErr bool
+	}{
+		{name: "no metadata", want: metadata.MD{}},
+		{name: "repeated names", pairs: []string{"Authorization=Bearer abc", "x-tenant=a", "x-tenant=b=c"}, want: metadata.MD{"authorization": {"Bearer abc"}, "x-tenant": {"a", "b=c"}}},
+		{name: "missing value", pairs: []string{"authorization"}, wantErr: true},
+		{name: "missing name", pairs: []string{"=value"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseMetadata(tt.pairs)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseMetadata() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseMetadata() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateGraphQLFlags(t *testing.T) {
+	const document = `query GetUser($id: ID!) { user(id: $id) { name } } mutation CreateOrder { createOrder { id } }`
+	config := benchmark.BenchmarkConfig{URL: "http://localhost:8080/graphql", Method: "GET"}
+
+	tests := []struct {
+		name           string
+		config         benchmark.BenchmarkConfig
+		graphQL        benchmark.GraphQLConfig
+		wantOperations []string
+		errMsg         string
+	}{
+		{name: "every operation by default", config: config, graphQL: benchmark.GraphQLConfig{Query: document, Variables: `{"id": "{{id}}"}`}, wantOperations: []string{"GetUser", "CreateOrder"}},
+		{name: "chosen operation", config: config, graphQL: benchmark.GraphQLConfig{Query: document, Operations: []string{"CreateOrder"}}, wantOperations: []string{"CreateOrder"}},
+		{name: "anonymous operation", config: config, graphQL: benchmark.GraphQLConfig{Query: `{ me { id } }`}, wantOperations: []string{""}},
+		{name: "unknown operation", config: config, graphQL: benchmark.GraphQLConfig{Query: document, Operations: []string{"DeleteUser"}}, errMsg: "'DeleteUser' is not an operation of the query file. Operations are: GetUser, CreateOrder"},
+		{name: "operation of an anonymous document", config: config, graphQL: benchmark.GraphQLConfig{Query: `{ me { id } }`, Operations: []string{"Me"}}, errMsg: "'Me' is not an operation of the query file. Operations are: a single anonymous operation, which is run without --operation"},
+		{name: "document without operations", config: config, graphQL: benchmark.GraphQLConfig{Query: `fragment F on User { id }`}, errMsg: "the GraphQL document has no operation"},
+		{name: "variables not an object", config: config, graphQL: benchmark.GraphQLConfig{Query: document, Variables: `[1, 2]`}, errMsg: "the variables are not a JSON object"},
+		{name: "invalid placeholder", config: config, graphQL: benchmark.GraphQLConfig{Query: document, Variables: `{"user": {{user}}}`}, errMsg: "'{{user}}' is not a valid placeholder. Supported placeholders are: {{id}}, {{timestamp}}, {{uuid}}"},
+		{name: "request body", config: benchmark.BenchmarkConfig{URL: config.URL, Method: "GET", Body: `{"query": "{ me { id } }"}`}, graphQL: benchmark.GraphQLConfig{Query: document}, errMsg: "operations are posted as JSON with --query-file and --variables, a method, request body or content type does not apply"},
+		{name: "workload", config: benchmark.BenchmarkConfig{URL: config.URL, Method: "GET", Workload: []workload.Endpoint{{Name: "users", Method: "GET", Path: "/users", Weight: 1}}}, graphQL: benchmark.GraphQLConfig{Query: document}, errMsg: "the graphql command does not support workloads, run several operations with --operation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := benchmark.ValidateGraphQL(&tt.config, &tt.graphQL)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("ValidateGraphQL() unexpected error = %v", err)
+				}
+				if !reflect.DeepEqual(tt.graphQL.Operations, tt.wantOperations) {
+					t.Errorf("ValidateGraphQL() operations = %q, want %q", tt.graphQL.Operations, tt.wantOperations)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.errMsg {
+				t.Errorf("ValidateGraphQL() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/spf13/cobra"
+)
+
+func newGraphQLCmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var graphQL benchmark.GraphQLConfig
+	var queryFile, variables string
+
+	graphQLCmd := &cobra.Command{
+		Use:   "graphql",
+		Short: "Benchmark GraphQL operations",
+		Long: "Posts the operations of the GraphQL document in --query-file to --url, or spread over the --target URLs, in turn, " +
+			"with the --variables rendered for every request, where {{id}} is the number of the request. Responses with errors in " +
+			"their errors array count as failures whatever their HTTP status code, and the metrics are also broken down by operation. " +
+			"The requests, concurrency, duration, rate, adaptive concurrency, warm-up, authentication and transport flags apply as to HTTP requests.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			if queryFile == "" {
+				return fmt.Errorf("a query file is required")
+			}
+			query, err := os.ReadFile(queryFile)
+			if err != nil {
+				return fmt.Errorf("error reading query file: %v", err)
+			}
+			graphQL.Query = string(query)
+			if graphQL.Variables, err = loadMessage(variables); err != nil {
+				return err
+			}
+			return benchmark.ValidateGraphQL(config, &graphQL)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeGraphQL(config, graphQL)
+		},
+	}
+
+	graphQLCmd.Flags().StringVar(&queryFile, "query-file", "", "A file with the GraphQL document of the operations to run.")
+	graphQLCmd.Flags().StringVar(&variables, "variables", "", "The variables as a JSON object, or @file to read them from a file. {{id}}, {{timestamp}} and {{uuid}} are replaced in every request.")
+	graphQLCmd.Flags().StringArrayVar(&graphQL.Operations, "operation", nil, "An operation of the document to run, can be repeated to run the operations in turn. By default every operation of the document is run.")
+
+	return graphQLCmd
+}
+
+func executeGraphQL(config *benchmark.BenchmarkConfig, graphQL benchmark.GraphQLConfig) {
+	runBenchmark(benchmark.Options{Config: *config, GraphQL: &graphQL}, "Error running the GraphQL operations")
+}
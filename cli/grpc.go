@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/komuvill/api_benchmarker/httpclient"
+	"github.com/spf13/cobra"
+	"google.golang.org/grpc/metadata"
+)
+
+func newGRPCCmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var call benchmark.GRPCConfig
+	var metadataPairs []string
+
+	grpcCmd := &cobra.Command{
+		Use:   "grpc <package.Service/Method>",
+		Short: "Benchmark a gRPC method",
+		Long: "Calls a unary or server-streaming gRPC method on a grpc:// or grpcs:// --url, or spread over the --target URLs, " +
+			"with the JSON request message given by --body. The method is described by --descriptor-set, or by the server reflection service. " +
+			"The requests, concurrency, duration, rate, adaptive concurrency and warm-up flags apply as to HTTP requests, and calls count as " +
+			"successful with status code 0 (OK). The TLS flags apply to grpcs:// targets and --connections to the connections per target.",
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			call.Method = args[0]
+			var err error
+			if call.Metadata, err = parseMetadata(metadataPairs); err != nil {
+				return err
+			}
+			if call.Message, err = loadMessage(config.Body); err != nil {
+				return err
+			}
+			return benchmark.ValidateGRPC(config, &call)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeGRPC(config, call)
+		},
+	}
+
+	grpcCmd.Flags().StringArrayVar(&metadataPairs, "metadata", nil, "Metadata sent with every call, as name=value. Can be repeated.")
+	grpcCmd.Flags().StringVar(&call.DescriptorSet, "descriptor-set", "", "A FileDescriptorSet describing the method, e.g. from protoc --descriptor_set_out --include_imports. By default the server reflection service is asked.")
+
+	return grpcCmd
+}
+
+// parseMetadata parses name=value pairs into call metadata
+func parseMetadata(pairs []string) (metadata.MD, error) {
+	md := metadata.MD{}
+	for _, pair := range pairs {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(name) == "" {
+			return nil, fmt.Errorf("'%s' is not valid metadata, use name=value", pair)
+		}
+		md.Append(strings.TrimSpace(name), value)
+	}
+	return md, nil
+}
+
+// loadMessage returns the request message of the body flag, read from the file it points to with @
+func loadMessage(body string) (string, error) {
+	reader, cleanup, err := httpclient.GetRequestBody(body)
+	if err != nil {
+		return "", err
+	}
+	defer cleanup()
+	message, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("error reading the request message: %v", err)
+	}
+	return string(message), nil
+}
+
+func executeGRPC(config *benchmark.BenchmarkConfig, call benchmark.GRPCConfig) {
+	runBenchmark(benchmark.Options{Config: *config, GRPC: &call}, "Error calling the gRPC method")
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/komuvill/api_benchmarker/replay"
+	"github.com/spf13/cobra"
+)
+
+// replayOptions holds the flags of the replay command
+type replayOptions struct {
+	LogFile     string
+	Format      string
+	Speed       float64
+	IncludePath string
+	ExcludePath string
+}
+
+func newReplayCmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var options replayOptions
+
+	replayCmd := &cobra.Command{
+		Use:   "replay",
+		Short: "Replay the requests of an access log against the API",
+		Long: "Reads timestamped requests from an access log and reissues them against the base URL given with --url, or spread over the --target base URLs, " +
+			"preserving their relative timing. The requests and duration flags are ignored.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			return validateReplayFlags(&options)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeReplay(config, options)
+		},
+	}
+
+	replayCmd.Flags().StringVar(&options.LogFile, "log", "", "The access log to replay.")
+	replayCmd.Flags().StringVar(&options.Format, "format", "combined", "The format of the log. Accepted formats: common, combined, jsonl")
+	replayCmd.Flags().Float64Var(&options.Speed, "speed", 1, "Time-scale factor of the replay, e.g. 2 replays the log at twice the speed.")
+	replayCmd.Flags().StringVar(&options.IncludePath, "include-path", "", "Only replay requests whose path matches this regular expression.")
+	replayCmd.Flags().StringVar(&options.ExcludePath, "exclude-path", "", "Skip requests whose path matches this regular expression.")
+
+	return replayCmd
+}
+
+func validateReplayFlags(options *replayOptions) error {
+	if options.LogFile == "" {
+		return fmt.Errorf("a log file is required")
+	}
+	if _, err := os.Stat(options.LogFile); os.IsNotExist(err) {
+		return fmt.Errorf("the log file does not exist: %s", options.LogFile)
+	}
+	if options.Format != "common" && options.Format != "combined" && options.Format != "jsonl" {
+		return fmt.Errorf("'%s' is not a valid log format. Supported formats are: common, combined, jsonl", options.Format)
+	}
+	if options.Speed <= 0 {
+		return fmt.Errorf("replay speed must be positive")
+	}
+	if _, err := regexp.Compile(options.IncludePath); err != nil {
+		return fmt.Errorf("invalid include path pattern: %v", err)
+	}
+	if _, err := regexp.Compile(options.ExcludePath); err != nil {
+		return fmt.Errorf("invalid exclude path pattern: %v", err)
+	}
+	return nil
+}
+
+func executeReplay(config *benchmark.BenchmarkConfig, options replayOptions) {
+	entries, skipped, err := replay.ReadLogFile(options.LogFile, options.Format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading log: %v\n", err)
+		os.Exit(1)
+	}
+	if skipped > 0 {
+		fmt.Printf("Skipped log lines that are not requests in the %s format: %d\n", options.Format, skipped)
+	}
+	if len(entries) == 0 {
+		fmt.Fprintf(os.Stderr, "The log has no requests in the %s format\n", options.Format)
+		os.Exit(1)
+	}
+
+	var include, exclude *regexp.Regexp
+	if options.IncludePath != "" {
+		include = regexp.MustCompile(options.IncludePath)
+	}
+	if options.ExcludePath != "" {
+		exclude = regexp.MustCompile(options.ExcludePath)
+	}
+	entries = replay.Filter(entries, include, exclude)
+	if len(entries) == 0 {
+		fmt.Fprintln(os.Stderr, "No requests left to replay after filtering")
+		os.Exit(1)
+	}
+
+	runBenchmark(benchmark.Options{
+		Config: *config,
+		Replay: &benchmark.ReplayConfig{Entries: entries, Speed: options.Speed},
+	}, "Error replaying log")
+}
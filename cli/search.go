@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/komuvill/api_benchmarker/report"
+	"github.com/komuvill/api_benchmarker/storage"
+	"github.com/spf13/cobra"
+)
+
+func newSearchCmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var search benchmark.SearchConfig
+
+	searchCmd := &cobra.Command{
+		Use:   "search",
+		Short: "Search for the highest load the API sustains within the given objectives",
+		Long: "Runs the benchmark repeatedly, stepping or binary-searching the concurrency or request rate, " +
+			"and reports the knee point where the objectives are no longer met.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			return validateSearchFlags(&search)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeSearch(config, search)
+		},
+	}
+
+	searchCmd.Flags().StringVar(&search.Parameter, "parameter", "concurrency", "The load parameter to search. Accepted values: concurrency, rate")
+	searchCmd.Flags().StringVar(&search.Strategy, "strategy", "step", "How to search the parameter. Accepted values: step, binary")
+	searchCmd.Flags().IntVar(&search.Min, "min", 10, "The lowest value of the parameter to test.")
+	searchCmd.Flags().IntVar(&search.Max, "max", 1000, "The highest value of the parameter to test.")
+	searchCmd.Flags().IntVar(&search.Step, "step", 10, "The increment of the step strategy or the resolution of the binary strategy.")
+	searchCmd.Flags().Float64Var(&search.LatencyPercentile, "slo-percentile", 99, "The response time percentile checked against the latency objective. Accepted values: 50, 90, 95, 99")
+	searchCmd.Flags().DurationVar(&search.MaxLatency, "slo-latency", 0, "The highest acceptable response time percentile, e.g. 250ms. 0 disables the objective.")
+	searchCmd.Flags().Float64Var(&search.MaxErrorRate, "slo-error-rate", -1, "The highest acceptable percentage of failed requests. Negative disables the objective.")
+
+	return searchCmd
+}
+
+func validateSearchFlags(search *benchmark.SearchConfig) error {
+	if search.Parameter != "concurrency" && search.Parameter != "rate" {
+		return fmt.Errorf("'%s' is not a valid search parameter. Supported parameters are: concurrency, rate", search.Parameter)
+	}
+	if search.Strategy != "step" && search.Strategy != "binary" {
+		return fmt.Errorf("'%s' is not a valid search strategy. Supported strategies are: step, binary", search.Strategy)
+	}
+	if search.Min < 1 || search.Max < search.Min {
+		return fmt.Errorf("the search range must satisfy 1 <= min <= max")
+	}
+	if search.Step < 1 {
+		return fmt.Errorf("the search step must be at least 1")
+	}
+
+	switch search.LatencyPercentile {
+	case 50, 90, 95, 99:
+	default:
+		return fmt.Errorf("'%g' is not a supported percentile. Supported percentiles are: 50, 90, 95, 99", search.LatencyPercentile)
+	}
+
+	if search.MaxLatency <= 0 && search.MaxErrorRate < 0 {
+		return fmt.Errorf("at least one objective is required, set --slo-latency or --slo-error-rate")
+	}
+
+	return nil
+}
+
+func executeSearch(config *benchmark.BenchmarkConfig, search benchmark.SearchConfig) {
+	startTime := time.Now()
+	result, err := benchmark.Search(context.Background(), benchmark.Options{Config: *config, Log: os.Stdout}, search)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error running search: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	for _, step := range result.Steps {
+		status := "PASS"
+		if !step.Passed {
+			status = "FAIL: " + step.Reason
+		}
+		fmt.Printf("%s %d: %.2f requests/s, p%g %s, success rate %.2f%% - %s\n", search.Parameter, step.Value, step.Metrics.RequestsPerSecond, search.LatencyPercentile, step.Latency, step.Metrics.SuccessRate, status)
+	}
+	if result.KneeFound {
+		fmt.Printf("Knee point: %s %d sustaining %.2f requests/s\n", search.Parameter, result.Knee.Value, result.Knee.Metrics.RequestsPerSecond)
+	} else {
+		fmt.Println("No tested value met the objectives")
+	}
+
+	outputDir := "./output"
+	os.MkdirAll(outputDir, os.ModePerm)
+	storage.SaveSearchResult(result, outputDir)
+
+	err = report.GenerateSearchReport(*config, search, result, startTime, outputDir)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error generating HTML report: %v\n", err)
+		os.Exit(1)
+	}
+}
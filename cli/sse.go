@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"time"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/spf13/cobra"
+)
+
+func newSSECmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var sse benchmark.SSEConfig
+
+	sseCmd := &cobra.Command{
+		Use:   "sse",
+		Short: "Benchmark a server-sent events stream",
+		Long: "Opens a server-sent event stream from --url, or spread over the --target URLs, for each of the --concurrency virtual users " +
+			"and holds it open for --duration, reconnecting when the server closes it. Records the time to connect, the time to the first event, " +
+			"the latency between events, the events per second and the disconnects and reconnects. The requests flag is ignored.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			return benchmark.ValidateSSE(config, &sse)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeSSE(config, sse)
+		},
+	}
+
+	sseCmd.Flags().DurationVar(&sse.ReconnectDelay, "reconnect-delay", time.Second, "How long a virtual user waits before reopening a stream that ended, unless the server sent a retry.")
+	sseCmd.Flags().BoolVar(&sse.NoReconnect, "no-reconnect", false, "Open a single stream per virtual user and do not reopen it.")
+
+	return sseCmd
+}
+
+func executeSSE(config *benchmark.BenchmarkConfig, sse benchmark.SSEConfig) {
+	runBenchmark(benchmark.Options{Config: *config, SSE: &sse}, "Error streaming events")
+}
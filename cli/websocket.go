@@ -0,0 +1,78 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+	"github.com/spf13/cobra"
+)
+
+func newWebSocketCmd(config *benchmark.BenchmarkConfig, validate func() error) *cobra.Command {
+	var ws benchmark.WebSocketConfig
+	var messagesFile string
+
+	wsCmd := &cobra.Command{
+		Use:   "websocket",
+		Short: "Benchmark a WebSocket endpoint",
+		Long: "Opens a WebSocket connection to --url, or spread over the --target URLs, for each of the --concurrency virtual users " +
+			"and sends the messages on it in turn for --duration, either at --message-rate or each once the previous one is answered. " +
+			"Records the time to connect, the round trip of each message to its reply, the messages per second and the abnormal closes. " +
+			"Messages are templates, {{id}}, {{user}}, {{seq}}, {{timestamp}} and {{uuid}} are replaced in each message sent. " +
+			"The requests flag is ignored.",
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			if err := validate(); err != nil {
+				return err
+			}
+			if err := loadMessages(messagesFile, &ws); err != nil {
+				return err
+			}
+			return benchmark.ValidateWebSocket(config, &ws)
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			executeWebSocket(config, ws)
+		},
+	}
+
+	wsCmd.Flags().StringArrayVar(&ws.Messages, "message", nil, "A message to send, can be repeated to send the messages in turn.")
+	wsCmd.Flags().StringVar(&messagesFile, "messages-file", "", "A file of messages to send in turn, one per line.")
+	wsCmd.Flags().Float64Var(&ws.MessageRate, "message-rate", 0, "Messages per second sent on each connection. By default the next message is sent once the previous one is answered.")
+	wsCmd.Flags().StringVar(&ws.CorrelationField, "correlation-field", "", "A JSON field, e.g. id or meta.requestId, pairing replies with the messages they answer. Messages without it get it set to their {{id}}. By default replies are paired in order.")
+	wsCmd.Flags().DurationVar(&ws.ReplyTimeout, "reply-timeout", 5*time.Second, "How long to wait for the reply to a message before counting it as unanswered.")
+
+	return wsCmd
+}
+
+// loadMessages adds the non-blank lines of the messages file, if any, to the messages
+func loadMessages(messagesFile string, ws *benchmark.WebSocketConfig) error {
+	if messagesFile == "" {
+		return nil
+	}
+	if len(ws.Messages) > 0 {
+		return fmt.Errorf("use either messages or a messages file, not both")
+	}
+	file, err := os.Open(messagesFile)
+	if err != nil {
+		return fmt.Errorf("error reading messages file: %v", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			ws.Messages = append(ws.Messages, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading messages file: %v", err)
+	}
+	return nil
+}
+
+func executeWebSocket(config *benchmark.BenchmarkConfig, ws benchmark.WebSocketConfig) {
+	runBenchmark(benchmark.Options{Config: *config, WebSocket: &ws}, "Error sending WebSocket messages")
+}
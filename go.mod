@@ -2,9 +2,21 @@ module github.com/komuvill/api_benchmarker
 
 go 1.18
 
-require github.com/spf13/cobra v1.8.0
+require (
+	github.com/andybalholm/brotli v1.1.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/klauspost/compress v1.17.2
+	github.com/spf13/cobra v1.8.0
+	golang.org/x/net v0.28.0
+	google.golang.org/grpc v1.57.2
+	google.golang.org/protobuf v1.33.0
+)
 
 require (
+	github.com/golang/protobuf v1.5.3 // indirect
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/spf13/pflag v1.0.5 // indirect
+	golang.org/x/sys v0.23.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20230525234030-28d5490b6b19 // indirect
 )
@@ -0,0 +1,328 @@
+package grpcclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// Options configures the gRPC client
+type Options struct {
+	// FileDescriptorSet with the services, as written by protoc
+	// --descriptor_set_out --include_imports. Server reflection is used when empty.
+	DescriptorSet string
+	TLSConfig     *tls.Config   // For grpcs:// targets
+	Connections   int           // Connections per target to spread the calls over, 1 when 0
+	Timeout       time.Duration // Time allowed for each call, 0 for no limit
+}
+
+// Call describes a gRPC call. Unary and server-streaming methods are supported.
+type Call struct {
+	Target   string // grpc://host:port, or grpcs://host:port for TLS
+	Method   string // Full method name, e.g. helloworld.Greeter/SayHello
+	Message  string // Request message as JSON, an empty message when empty
+	Metadata metadata.MD
+}
+
+// Response holds what came back from a call
+type Response struct {
+	Code      codes.Code // OK when the call succeeded
+	Body      string     // Response messages as JSON, one per line, or the status message when the call failed
+	Streaming bool       // The method is server-streaming
+	Messages  int        // Response messages received
+
+	// From the start of the call to the first response message, 0 without messages
+	FirstMessage time.Duration
+
+	SentBytes     int64 // Request message bytes in the protobuf encoding
+	ReceivedBytes int64 // Response message bytes in the protobuf encoding
+}
+
+// Client calls gRPC methods whose messages it only knows from descriptors.
+// It is safe for concurrent use and keeps its connections between calls.
+type Client struct {
+	files       *protoregistry.Files // From the descriptor set, nil to use server reflection
+	tlsConfig   *tls.Config
+	connections int
+	timeout     time.Duration
+
+	connMu sync.Mutex
+	conns  map[string][]*grpc.ClientConn // By target
+	next   uint64                        // Round-robin counter over the connections of a target
+
+	methodMu sync.Mutex
+	methods  map[string]*methodLookup // By method name
+}
+
+// methodLookup is the descriptor of a method, or why it could not be found,
+// looked up once for all the calls of the method
+type methodLookup struct {
+	once   sync.Once
+	method protoreflect.MethodDescriptor
+	err    error
+}
+
+// NewClient creates a client, loading the descriptor set if the options name one
+func NewClient(options Options) (*Client, error) {
+	client := &Client{
+		tlsConfig:   options.TLSConfig,
+		connections: options.Connections,
+		timeout:     options.Timeout,
+		conns:       map[string][]*grpc.ClientConn{},
+		methods:     map[string]*methodLookup{},
+	}
+	if client.connections < 1 {
+		client.connections = 1
+	}
+	if options.DescriptorSet != "" {
+		files, err := LoadDescriptorSet(options.DescriptorSet)
+		if err != nil {
+			return nil, err
+		}
+		client.files = files
+	}
+	return client, nil
+}
+
+// ValidateTarget checks that the target is a grpc:// or grpcs:// URL with a host
+func ValidateTarget(target string) error {
+	_, _, err := parseTarget(target)
+	return err
+}
+
+// parseTarget returns the address to dial for the target and whether it uses TLS
+func parseTarget(target string) (string, bool, error) {
+	parsed, err := url.Parse(target)
+	if err != nil || (parsed.Scheme != "grpc" && parsed.Scheme != "grpcs") || parsed.Host == "" {
+		return "", false, fmt.Errorf("'%s' is not a gRPC target, use grpc://host:port or grpcs://host:port", target)
+	}
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address += ":443"
+		if parsed.Scheme == "grpc" {
+			address = parsed.Host + ":80"
+		}
+	}
+	return address, parsed.Scheme == "grpcs", nil
+}
+
+// conn returns the next connection to the target, dialing the connections of
+// the target on first use. Dialing does not wait for the connection, a target
+// that cannot be reached fails the calls with Unavailable.
+func (c *Client) conn(target string) (*grpc.ClientConn, error) {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	conns, ok := c.conns[target]
+	if !ok {
+		address, secure, err := parseTarget(target)
+		if err != nil {
+			return nil, err
+		}
+		creds := insecure.NewCredentials()
+		if secure {
+			tlsConfig := &tls.Config{}
+			if c.tlsConfig != nil {
+				tlsConfig = c.tlsConfig.Clone()
+			}
+			creds = credentials.NewTLS(tlsConfig)
+		}
+		for i := 0; i < c.connections; i++ {
+			conn, err := grpc.Dial(address, grpc.WithTransportCredentials(creds))
+			if err != nil {
+				return nil, fmt.Errorf("error connecting to %s: %v", target, err)
+			}
+			conns = append(conns, conn)
+		}
+		c.conns[target] = conns
+	}
+	return conns[atomic.AddUint64(&c.next, 1)%uint64(len(conns))], nil
+}
+
+// Close closes the connections of the client
+func (c *Client) Close() {
+	c.connMu.Lock()
+	defer c.connMu.Unlock()
+	for target, conns := range c.conns {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		delete(c.conns, target)
+	}
+}
+
+// method returns the descriptor of the method. It is looked up by the first
+// call of the method, and the other calls wait for it without holding up the
+// calls of other methods. A failed lookup fails every call of the method
+// rather than asking a failing reflection service again and again.
+func (c *Client) method(ctx context.Context, conn *grpc.ClientConn, name string) (protoreflect.MethodDescriptor, error) {
+	c.methodMu.Lock()
+	lookup, ok := c.methods[name]
+	if !ok {
+		lookup = &methodLookup{}
+		c.methods[name] = lookup
+	}
+	c.methodMu.Unlock()
+
+	lookup.once.Do(func() {
+		lookup.method, lookup.err = c.findMethod(ctx, conn, name)
+	})
+	return lookup.method, lookup.err
+}
+
+// findMethod finds the descriptor of the method, in the descriptor set or
+// from the reflection service of the server behind the connection
+func (c *Client) findMethod(ctx context.Context, conn *grpc.ClientConn, name string) (protoreflect.MethodDescriptor, error) {
+	service, methodName, err := splitMethod(name)
+	if err != nil {
+		return nil, err
+	}
+	files := c.files
+	if files == nil {
+		if files, err = reflectFiles(ctx, conn, service); err != nil {
+			return nil, err
+		}
+	}
+	descriptor, err := files.FindDescriptorByName(protoreflect.FullName(service))
+	if err != nil {
+		return nil, fmt.Errorf("the service %s is not known: %v", service, err)
+	}
+	serviceDescriptor, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, fmt.Errorf("%s is not a service", service)
+	}
+	method := serviceDescriptor.Methods().ByName(protoreflect.Name(methodName))
+	if method == nil {
+		return nil, fmt.Errorf("the service %s has no method %s", service, methodName)
+	}
+	if method.IsStreamingClient() {
+		return nil, fmt.Errorf("the method %s is client-streaming, only unary and server-streaming methods are supported", name)
+	}
+	return method, nil
+}
+
+// ValidateMethod checks that the method name has a service and a method
+func ValidateMethod(name string) error {
+	_, _, err := splitMethod(name)
+	return err
+}
+
+// splitMethod splits package.Service/Method, package.Service.Method or
+// /package.Service/Method into the service and the method name
+func splitMethod(name string) (string, string, error) {
+	trimmed := strings.TrimPrefix(name, "/")
+	separator := strings.LastIndex(trimmed, "/")
+	if separator < 0 {
+		separator = strings.LastIndex(trimmed, ".")
+	}
+	if separator <= 0 || separator == len(trimmed)-1 {
+		return "", "", fmt.Errorf("'%s' is not a valid method name, use package.Service/Method", name)
+	}
+	return trimmed[:separator], trimmed[separator+1:], nil
+}
+
+// Invoke makes the call and waits for all of its response messages. A call
+// that ends with a status other than OK is not an error, its code and
+// message are in the response. Errors are problems on the client side, such
+// as an unknown method or a request message that does not match it.
+func (c *Client) Invoke(ctx context.Context, call Call) (Response, error) {
+	conn, err := c.conn(call.Target)
+	if err != nil {
+		return Response{}, err
+	}
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	method, err := c.method(ctx, conn, call.Method)
+	if err != nil {
+		return Response{}, err
+	}
+	request := dynamicpb.NewMessage(method.Input())
+	if call.Message != "" {
+		if err := protojson.Unmarshal([]byte(call.Message), request); err != nil {
+			return Response{}, fmt.Errorf("the request message does not match %s: %v", method.Input().FullName(), err)
+		}
+	}
+	if len(call.Metadata) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, call.Metadata)
+	}
+	fullMethod := fmt.Sprintf("/%s/%s", method.Parent().FullName(), method.Name())
+
+	response := Response{
+		Streaming: method.IsStreamingServer(),
+		SentBytes: int64(proto.Size(request)),
+	}
+	var messages []string
+	received := func(message proto.Message, startTime time.Time) {
+		if response.Messages == 0 {
+			response.FirstMessage = time.Since(startTime)
+		}
+		response.Messages++
+		response.ReceivedBytes += int64(proto.Size(message))
+		encoded, _ := protojson.Marshal(message)
+		messages = append(messages, string(encoded))
+	}
+
+	startTime := time.Now()
+	if !response.Streaming {
+		reply := dynamicpb.NewMessage(method.Output())
+		err = conn.Invoke(ctx, fullMethod, request, reply)
+		if err == nil {
+			received(reply, startTime)
+		}
+	} else {
+		err = c.receiveStream(ctx, conn, fullMethod, request, func(reply proto.Message) { received(reply, startTime) }, method.Output())
+	}
+
+	response.Code = status.Code(err)
+	response.Body = strings.Join(messages, "\n")
+	if err != nil {
+		response.Body = status.Convert(err).Message()
+	}
+	return response, nil
+}
+
+// receiveStream sends the request of a server-streaming call and hands every
+// response message to received until the server ends the stream
+func (c *Client) receiveStream(ctx context.Context, conn *grpc.ClientConn, fullMethod string, request proto.Message, received func(proto.Message), output protoreflect.MessageDescriptor) error {
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, fullMethod)
+	if err != nil {
+		return err
+	}
+	if err := stream.SendMsg(request); err != nil && err != io.EOF {
+		return err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return err
+	}
+	for {
+		reply := dynamicpb.NewMessage(output)
+		if err := stream.RecvMsg(reply); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+		received(reply)
+	}
+}
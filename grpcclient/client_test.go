@@ -0,0 +1,349 @@
+package grpcclient
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// healthServer answers Check for the empty service only and sends three
+// statuses on Watch before ending the stream
+type healthServer struct {
+	healthpb.UnimplementedHealthServer
+}
+
+func (healthServer) Check(ctx context.Context, request *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	if request.Service != "" {
+		return nil, status.Errorf(codes.NotFound, "unknown service %s", request.Service)
+	}
+	return &healthpb.HealthCheckResponse{Status: healthpb.HealthCheckResponse_SERVING}, nil
+}
+
+func (healthServer) Watch(request *healthpb.HealthCheckRequest, stream healthpb.Health_WatchServer) error {
+	for _, serving := range []healthpb.HealthCheckResponse_ServingStatus{
+		healthpb.HealthCheckResponse_NOT_SERVING, healthpb.HealthCheckResponse_SERVING, healthpb.HealthCheckResponse_SERVING,
+	} {
+		if err := stream.Send(&healthpb.HealthCheckResponse{Status: serving}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// requireToken rejects the calls to the health service without the x-token metadata
+func requireToken(ctx context.Context, method string) error {
+	if !strings.HasPrefix(method, "/grpc.health.v1.Health/") {
+		return nil
+	}
+	if md, _ := metadata.FromIncomingContext(ctx); len(md.Get("x-token")) == 0 || md.Get("x-token")[0] != "secret" {
+		return status.Error(codes.Unauthenticated, "missing token")
+	}
+	return nil
+}
+
+func startHealthServer(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if err := requireToken(ctx, info.FullMethod); err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if err := requireToken(stream.Context(), info.FullMethod); err != nil {
+				return err
+			}
+			return handler(srv, stream)
+		}),
+	)
+	healthpb.RegisterHealthServer(server, healthServer{})
+	reflection.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return "grpc://" + listener.Addr().String()
+}
+
+func writeHealthDescriptorSet(t *testing.T) string {
+	set := &descriptorpb.FileDescriptorSet{File: []*descriptorpb.FileDescriptorProto{protodesc.ToFileDescriptorProto(healthpb.File_grpc_health_v1_health_proto)}}
+	data, err := proto.Marshal(set)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "health.protoset")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return path
+}
+
+func TestClientInvoke(t *testing.T) {
+	target := startHealthServer(t)
+	token := metadata.Pairs("x-token", "secret")
+
+	// The health service is described once by reflection and once by a descriptor set
+	descriptorSet := writeHealthDescriptorSet(t)
+
+	tests := []struct {
+		name          string
+		descriptorSet string
+		call          Call
+		wantCode      codes.Code
+		wantStreaming bool
+		wantMessages  int
+		wantBody      string
+	}{
+		{
+			name:         "unary call by reflection",
+			call:         Call{Method: "grpc.health.v1.Health/Check", Message: `{"service": ""}`, Metadata: token},
+			wantCode:     codes.OK,
+			wantMessages: 1,
+			wantBody:     "SERVING",
+		},
+		{
+			name:          "unary call by descriptor set",
+			descriptorSet: descriptorSet,
+			call:          Call{Method: "grpc.health.v1.Health.Check", Metadata: token},
+			wantCode:      codes.OK,
+			wantMessages:  1,
+			wantBody:      "SERVING",
+		},
+		{
+			name:     "status other than OK",
+			call:     Call{Method: "grpc.health.v1.Health/Check", Message: `{"service": "orders"}`, Metadata: token},
+			wantCode: codes.NotFound,
+			wantBody: "unknown service orders",
+		},
+		{
+			name:     "missing metadata",
+			call:     Call{Method: "grpc.health.v1.Health/Check"},
+			wantCode: codes.Unauthenticated,
+			wantBody: "missing token",
+		},
+		{
+			name:          "server-streaming call",
+			call:          Call{Method: "/grpc.health.v1.Health/Watch", Metadata: token},
+			wantCode:      codes.OK,
+			wantStreaming: true,
+			wantMessages:  3,
+			wantBody:      "NOT_SERVING",
+		},
+		{
+			name:          "rejected server-streaming call",
+			descriptorSet: descriptorSet,
+			call:          Call{Method: "grpc.health.v1.Health/Watch"},
+			wantCode:      codes.Unauthenticated,
+			wantStreaming: true,
+			wantBody:      "missing token",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(Options{DescriptorSet: tt.descriptorSet})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer client.Close()
+
+			tt.call.Target = target
+			response, err := client.Invoke(context.Background(), tt.call)
+			if err != nil {
+				t.Fatalf("Invoke() unexpected error: %v", err)
+			}
+			if response.Code != tt.wantCode {
+				t.Errorf("Invoke() code = %v, want %v", response.Code, tt.wantCode)
+			}
+			if response.Streaming != tt.wantStreaming {
+				t.Errorf("Invoke() streaming = %v, want %v", response.Streaming, tt.wantStreaming)
+			}
+			if response.Messages != tt.wantMessages {
+				t.Errorf("Invoke() messages = %d, want %d", response.Messages, tt.wantMessages)
+			}
+			if !strings.Contains(response.Body, tt.wantBody) {
+				t.Errorf("Invoke() body = %q, want it to contain %q", response.Body, tt.wantBody)
+			}
+			if tt.wantMessages > 0 && (response.ReceivedBytes == 0 || response.FirstMessage <= 0) {
+				t.Errorf("Invoke() received bytes = %d, first message = %s, want both measured", response.ReceivedBytes, response.FirstMessage)
+			}
+		})
+	}
+}
+
+func TestClientInvokeErrors(t *testing.T) {
+	target := startHealthServer(t)
+
+	tests := []struct {
+		name   string
+		call   Call
+		errMsg string
+	}{
+		{name: "unknown service", call: Call{Target: target, Method: "orders.Orders/Get"}, errMsg: "the reflection service failed"},
+		{name: "unknown method", call: Call{Target: target, Method: "grpc.health.v1.Health/List"}, errMsg: "the service grpc.health.v1.Health has no method List"},
+		{name: "request message of another type", call: Call{Target: target, Method: "grpc.health.v1.Health/Check", Message: `{"id": 1}`}, errMsg: "the request message does not match grpc.health.v1.HealthCheckRequest"},
+		{name: "invalid target", call: Call{Target: "localhost:50051", Method: "grpc.health.v1.Health/Check"}, errMsg: "'localhost:50051' is not a gRPC target, use grpc://host:port or grpcs://host:port"},
+	}
+
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.Invoke(context.Background(), tt.call)
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Invoke() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+		})
+	}
+
+	// A target nobody listens on fails the call with Unavailable, unless its
+	// reflection service was needed to make the call
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	closed := "grpc://" + listener.Addr().String()
+	listener.Close()
+	if _, err := client.Invoke(context.Background(), Call{Target: closed, Method: "grpc.health.v1.Health/Watch"}); err == nil {
+		t.Errorf("Invoke() expected an error reaching the reflection service of %s", closed)
+	}
+	offline, err := NewClient(Options{DescriptorSet: writeHealthDescriptorSet(t)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer offline.Close()
+	response, err := offline.Invoke(context.Background(), Call{Target: closed, Method: "grpc.health.v1.Health/Check"})
+	if err != nil || response.Code != codes.Unavailable {
+		t.Errorf("Invoke() code = %v, error = %v, want %v without an error", response.Code, err, codes.Unavailable)
+	}
+}
+
+func TestSplitMethod(t *testing.T) {
+	tests := []struct {
+		name        string
+		wantService string
+		wantMethod  string
+		wantErr     bool
+	}{
+		{name: "helloworld.Greeter/SayHello", wantService: "helloworld.Greeter", wantMethod: "SayHello"},
+		{name: "/helloworld.Greeter/SayHello", wantService: "helloworld.Greeter", wantMethod: "SayHello"},
+		{name: "helloworld.Greeter.SayHello", wantService: "helloworld.Greeter", wantMethod: "SayHello"},
+		{name: "SayHello", wantErr: true},
+		{name: "helloworld.Greeter/", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, method, err := splitMethod(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitMethod() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if service != tt.wantService || method != tt.wantMethod {
+				t.Errorf("splitMethod() = %s, %s, want %s, %s", service, method, tt.wantService, tt.wantMethod)
+			}
+		})
+	}
+}
+
+// slowHealthServer answers Check after a delay
+type slowHealthServer struct {
+	healthServer
+	delay time.Duration
+}
+
+func (s slowHealthServer) Check(ctx context.Context, request *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	time.Sleep(s.delay)
+	return s.healthServer.Check(ctx, request)
+}
+
+// startSlowServer starts a health server answering after the delay that
+// counts the calls to its reflection service
+func startSlowServer(t *testing.T, delay time.Duration, reflectionCalls *int64) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	server := grpc.NewServer(grpc.StreamInterceptor(func(srv interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.Contains(info.FullMethod, "ServerReflection") {
+			atomic.AddInt64(reflectionCalls, 1)
+		}
+		return handler(srv, stream)
+	}))
+	healthpb.RegisterHealthServer(server, slowHealthServer{delay: delay})
+	reflection.Register(server)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return "grpc://" + listener.Addr().String()
+}
+
+func TestClientTimeout(t *testing.T) {
+	var reflectionCalls int64
+	target := startSlowServer(t, 100*time.Millisecond, &reflectionCalls)
+	call := Call{Target: target, Method: "grpc.health.v1.Health/Check"}
+
+	client, err := NewClient(Options{Timeout: 20 * time.Millisecond, DescriptorSet: writeHealthDescriptorSet(t)})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+	response, err := client.Invoke(context.Background(), call)
+	if err != nil || response.Code != codes.DeadlineExceeded {
+		t.Errorf("Invoke() code = %v, error = %v, want %v", response.Code, err, codes.DeadlineExceeded)
+	}
+
+	// Without a timeout the call takes as long as it needs
+	unlimited, _ := NewClient(Options{DescriptorSet: writeHealthDescriptorSet(t)})
+	defer unlimited.Close()
+	response, err = unlimited.Invoke(context.Background(), call)
+	if err != nil || response.Code != codes.OK {
+		t.Errorf("Invoke() code = %v, error = %v, want %v", response.Code, err, codes.OK)
+	}
+}
+
+func TestClientMethodLookup(t *testing.T) {
+	var reflectionCalls int64
+	target := startSlowServer(t, 0, &reflectionCalls)
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer client.Close()
+
+	// Concurrent calls of a method share a single lookup, also when it fails
+	for _, method := range []string{"grpc.health.v1.Health/Check", "orders.Orders/Get"} {
+		atomic.StoreInt64(&reflectionCalls, 0)
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.Invoke(context.Background(), Call{Target: target, Method: method})
+			}()
+		}
+		wg.Wait()
+		if calls := atomic.LoadInt64(&reflectionCalls); calls != 1 {
+			t.Errorf("%s: the reflection service was called %d times, want 1", method, calls)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package grpcclient
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"google.golang.org/grpc"
+	rpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// LoadDescriptorSet reads a FileDescriptorSet, which must include the imports
+// of its files
+func LoadDescriptorSet(path string) (*protoregistry.Files, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading descriptor set: %v", err)
+	}
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error parsing descriptor set: %v", err)
+	}
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing descriptor set, was it built with --include_imports? %v", err)
+	}
+	return files, nil
+}
+
+// reflectFiles asks the reflection service of the server for the file that
+// defines the service and the files it imports
+func reflectFiles(ctx context.Context, conn *grpc.ClientConn, service string) (*protoregistry.Files, error) {
+	stream, err := rpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error reaching the reflection service: %v", err)
+	}
+	defer stream.CloseSend()
+
+	fetched := map[string]*descriptorpb.FileDescriptorProto{}
+	var order []string
+	fetch := func(request *rpb.ServerReflectionRequest) error {
+		if err := stream.Send(request); err != nil {
+			return fmt.Errorf("error reaching the reflection service: %v", err)
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			return fmt.Errorf("error reaching the reflection service: %v", err)
+		}
+		if failure := response.GetErrorResponse(); failure != nil {
+			return fmt.Errorf("the reflection service failed: %s", failure.GetErrorMessage())
+		}
+		// The server may send the imports along with the file
+		for _, data := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+			var file descriptorpb.FileDescriptorProto
+			if err := proto.Unmarshal(data, &file); err != nil {
+				return fmt.Errorf("error parsing a descriptor from the reflection service: %v", err)
+			}
+			if _, ok := fetched[file.GetName()]; !ok {
+				fetched[file.GetName()] = &file
+				order = append(order, file.GetName())
+			}
+		}
+		return nil
+	}
+
+	if err := fetch(&rpb.ServerReflectionRequest{
+		MessageRequest: &rpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: service},
+	}); err != nil {
+		return nil, err
+	}
+	// Fetch the imports the server did not send, falling back to the well-known
+	// types compiled into the client
+	for i := 0; i < len(order); i++ {
+		for _, dependency := range fetched[order[i]].GetDependency() {
+			if _, ok := fetched[dependency]; ok {
+				continue
+			}
+			if known, err := protoregistry.GlobalFiles.FindFileByPath(dependency); err == nil {
+				fetched[dependency] = protodesc.ToFileDescriptorProto(known)
+				order = append(order, dependency)
+				continue
+			}
+			if err := fetch(&rpb.ServerReflectionRequest{
+				MessageRequest: &rpb.ServerReflectionRequest_FileByFilename{FileByFilename: dependency},
+			}); err != nil {
+				return nil, err
+			}
+			if _, ok := fetched[dependency]; !ok {
+				return nil, fmt.Errorf("the reflection service did not send %s", dependency)
+			}
+		}
+	}
+
+	set := &descriptorpb.FileDescriptorSet{}
+	for _, name := range order {
+		set.File = append(set.File, fetched[name])
+	}
+	files, err := protodesc.NewFiles(set)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the descriptors from the reflection service: %v", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,275 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supported values of Options.APIKeyIn
+const (
+	APIKeyInHeader = "header"
+	APIKeyInQuery  = "query"
+)
+
+// tokenRefreshAt is the fraction of the token lifetime after which it is refreshed
+const tokenRefreshAt = 0.9
+
+// TokenFetch records a request to the OAuth2 token endpoint
+type TokenFetch struct {
+	StartTime    time.Time
+	ResponseTime time.Duration
+	StatusCode   int
+	Error        error
+}
+
+// ValidateAuth checks that at most one kind of authentication is set and that it is complete.
+func ValidateAuth(options Options) error {
+	kinds := 0
+	for _, set := range []bool{options.BasicAuth != "", options.BearerToken != "", options.APIKey != "", options.OAuth2TokenURL != ""} {
+		if set {
+			kinds++
+		}
+	}
+	if kinds > 1 {
+		return fmt.Errorf("use only one of basic auth, a bearer token, an API key and OAuth2")
+	}
+
+	if options.BasicAuth != "" && !strings.Contains(options.BasicAuth, ":") {
+		return fmt.Errorf("'%s' is not a valid basic auth, use user:password", options.BasicAuth)
+	}
+	if options.APIKey != "" {
+		if name, _, ok := strings.Cut(options.APIKey, "="); !ok || name == "" {
+			return fmt.Errorf("'%s' is not a valid API key, use name=value", options.APIKey)
+		}
+	}
+	switch options.APIKeyIn {
+	case "", APIKeyInHeader, APIKeyInQuery:
+	default:
+		return fmt.Errorf("'%s' is not a valid API key location. Supported locations are: header, query", options.APIKeyIn)
+	}
+	if options.OAuth2TokenURL != "" {
+		tokenURL, err := url.Parse(options.OAuth2TokenURL)
+		if err != nil || (tokenURL.Scheme != "http" && tokenURL.Scheme != "https") || tokenURL.Host == "" {
+			return fmt.Errorf("'%s' is not a valid OAuth2 token URL", options.OAuth2TokenURL)
+		}
+		if options.OAuth2ClientID == "" {
+			return fmt.Errorf("OAuth2 requires a client ID")
+		}
+	} else if options.OAuth2ClientID != "" || options.OAuth2ClientSecret != "" || len(options.OAuth2Scopes) > 0 {
+		return fmt.Errorf("the OAuth2 client settings require a token URL")
+	}
+	return nil
+}
+
+// authenticator adds credentials to the requests
+type authenticator interface {
+	// authenticate adds the credentials to the request and returns the time
+	// spent waiting for them
+	authenticate(req *http.Request) (time.Duration, error)
+}
+
+// newAuthenticator returns the authenticator of the options, or nil if no authentication is set
+func newAuthenticator(options Options, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) (authenticator, error) {
+	switch {
+	case options.BasicAuth != "":
+		user, password, _ := strings.Cut(options.BasicAuth, ":")
+		return basicAuth{user: user, password: password}, nil
+	case options.BearerToken != "":
+		return bearerToken(options.BearerToken), nil
+	case options.APIKey != "":
+		name, value, _ := strings.Cut(options.APIKey, "=")
+		return apiKey{name: name, value: value, inQuery: options.APIKeyIn == APIKeyInQuery}, nil
+	case options.OAuth2TokenURL != "":
+		httpClient, err := newTokenClient(tlsConfig, proxy)
+		if err != nil {
+			return nil, err
+		}
+		return &tokenSource{
+			httpClient:   httpClient,
+			tokenURL:     options.OAuth2TokenURL,
+			clientID:     options.OAuth2ClientID,
+			clientSecret: options.OAuth2ClientSecret,
+			scopes:       options.OAuth2Scopes,
+		}, nil
+	}
+	return nil, nil
+}
+
+// newTokenClient returns the client of the OAuth2 token endpoint. It shares the
+// TLS and proxy settings of the benchmark, but not its connection target or pool.
+func newTokenClient(tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) (*http.Client, error) {
+	tlsConfig = tlsConfig.Clone()
+	tlsConfig.ServerName = ""
+	dial, err := newDialFunc(Options{})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{
+		Transport: newBaseTransport(Options{}, tlsConfig, proxy, dial),
+		Timeout:   time.Second * 30,
+	}, nil
+}
+
+type basicAuth struct {
+	user, password string
+}
+
+func (a basicAuth) authenticate(req *http.Request) (time.Duration, error) {
+	req.SetBasicAuth(a.user, a.password)
+	return 0, nil
+}
+
+type bearerToken string
+
+func (t bearerToken) authenticate(req *http.Request) (time.Duration, error) {
+	req.Header.Set("Authorization", "Bearer "+string(t))
+	return 0, nil
+}
+
+type apiKey struct {
+	name, value string
+	inQuery     bool
+}
+
+func (k apiKey) authenticate(req *http.Request) (time.Duration, error) {
+	if !k.inQuery {
+		req.Header.Set(k.name, k.value)
+		return 0, nil
+	}
+
+	// Append the key rather than re-encoding the query so the rest of it is sent as given
+	param := url.QueryEscape(k.name) + "=" + url.QueryEscape(k.value)
+	if req.URL.RawQuery == "" {
+		req.URL.RawQuery = param
+	} else {
+		req.URL.RawQuery += "&" + param
+	}
+	return 0, nil
+}
+
+// tokenSource holds an OAuth2 access token from the client credentials grant.
+// The token is fetched on first use and again once most of its lifetime has
+// passed. Requests that need a token while it is fetched wait for it.
+type tokenSource struct {
+	httpClient   *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu          sync.Mutex
+	accessToken string
+	refreshAt   time.Time // Zero if the token does not expire
+	fetches     []TokenFetch
+}
+
+func (s *tokenSource) authenticate(req *http.Request) (time.Duration, error) {
+	start := time.Now()
+	token, err := s.token()
+	if err != nil {
+		return time.Since(start), fmt.Errorf("error fetching OAuth2 token: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return time.Since(start), nil
+}
+
+// token returns the current access token, fetching a new one if needed
+func (s *tokenSource) token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.accessToken != "" && (s.refreshAt.IsZero() || time.Now().Before(s.refreshAt)) {
+		return s.accessToken, nil
+	}
+
+	fetch := TokenFetch{StartTime: time.Now()}
+	token, expiresIn, err := s.fetch(&fetch)
+	fetch.ResponseTime = time.Since(fetch.StartTime)
+	fetch.Error = err
+	s.fetches = append(s.fetches, fetch)
+	if err != nil {
+		return "", err
+	}
+
+	s.accessToken = token
+	s.refreshAt = time.Time{}
+	if expiresIn > 0 {
+		s.refreshAt = fetch.StartTime.Add(time.Duration(float64(expiresIn) * tokenRefreshAt))
+	}
+	return token, nil
+}
+
+// fetch requests a token from the token endpoint
+func (s *tokenSource) fetch(record *TokenFetch) (string, time.Duration, error) {
+	form := url.Values{"grant_type": {"client_credentials"}}
+	if len(s.scopes) > 0 {
+		form.Set("scope", strings.Join(s.scopes, " "))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+	record.StatusCode = resp.StatusCode
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", 0, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return "", 0, fmt.Errorf("token endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.Unmarshal(body, &token); err != nil {
+		return "", 0, fmt.Errorf("invalid token response: %v", err)
+	}
+	if token.AccessToken == "" {
+		return "", 0, fmt.Errorf("the token response has no access token")
+	}
+	return token.AccessToken, time.Duration(token.ExpiresIn) * time.Second, nil
+}
+
+// Authenticate fetches the OAuth2 token up front so the first requests do
+// not have to. It does nothing for the other kinds of authentication.
+func (c *Client) Authenticate() error {
+	source, ok := c.auth.(*tokenSource)
+	if !ok {
+		return nil
+	}
+	_, err := source.token()
+	return err
+}
+
+// TokenFetches returns the requests made to the OAuth2 token endpoint so far
+func (c *Client) TokenFetches() []TokenFetch {
+	source, ok := c.auth.(*tokenSource)
+	if !ok {
+		return nil
+	}
+	source.mu.Lock()
+	defer source.mu.Unlock()
+	return append([]TokenFetch(nil), source.fetches...)
+}
@@ -0,0 +1,116 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestClientAuth(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s|%s|%s", r.Header.Get("Authorization"), r.Header.Get("X-API-Key"), r.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		options  Options
+		query    string
+		wantBody string
+	}{
+		{name: "none", options: Options{}, wantBody: "||"},
+		{name: "basic", options: Options{BasicAuth: "user:p:ss"}, wantBody: "Basic dXNlcjpwOnNz||"},
+		{name: "bearer", options: Options{BearerToken: "abc"}, wantBody: "Bearer abc||"},
+		{name: "API key header", options: Options{APIKey: "X-API-Key=secret"}, wantBody: "|secret|"},
+		{name: "API key query", options: Options{APIKey: "api_key=a b", APIKeyIn: APIKeyInQuery}, query: "?b=2&a=1", wantBody: "||b=2&a=1&api_key=a+b"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do("GET", ts.URL+"/"+tt.query, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != tt.wantBody {
+				t.Errorf("Do() body = %q, want %q", resp.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestClientOAuth2(t *testing.T) {
+	var issued int64
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "bench" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" || r.FormValue("scope") != "read write" {
+			http.Error(w, `{"error": "invalid_client"}`, http.StatusUnauthorized)
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "bearer", "expires_in": 3600}`, atomic.AddInt64(&issued, 1))
+	}))
+	defer tokenServer.Close()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer api.Close()
+
+	options := Options{OAuth2TokenURL: tokenServer.URL, OAuth2ClientID: "bench", OAuth2ClientSecret: "s3cret", OAuth2Scopes: []string{"read", "write"}}
+	client, err := NewClient(options)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// The first request waits for the token, the next one reuses it
+	resp, err := client.Do("GET", api.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Body != "Bearer token-1" || resp.TokenWait < 20*time.Millisecond {
+		t.Errorf("first request got %q after waiting %s, want token-1 after the fetch", resp.Body, resp.TokenWait)
+	}
+	resp, _ = client.Do("GET", api.URL, nil)
+	if resp.Body != "Bearer token-1" || resp.TokenWait >= 20*time.Millisecond {
+		t.Errorf("second request got %q after waiting %s, want the cached token-1", resp.Body, resp.TokenWait)
+	}
+
+	// Once the token is due for a refresh a new one is fetched
+	source := client.auth.(*tokenSource)
+	source.mu.Lock()
+	if until := time.Until(source.refreshAt); until < 53*time.Minute || until > 54*time.Minute {
+		t.Errorf("token refreshed in %s, want after 90%% of its hour", until)
+	}
+	source.refreshAt = time.Now().Add(-time.Second)
+	source.mu.Unlock()
+	resp, _ = client.Do("GET", api.URL, nil)
+	if resp.Body != "Bearer token-2" {
+		t.Errorf("request after expiry got %q, want token-2", resp.Body)
+	}
+
+	fetches := client.TokenFetches()
+	if len(fetches) != 2 || fetches[0].StatusCode != http.StatusOK || fetches[0].ResponseTime < 20*time.Millisecond {
+		t.Errorf("TokenFetches() = %+v, want two successful fetches", fetches)
+	}
+
+	t.Run("invalid client", func(t *testing.T) {
+		options.OAuth2ClientSecret = "wrong"
+		client, err := NewClient(options)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := client.Authenticate(); err == nil || !strings.Contains(err.Error(), "401") {
+			t.Errorf("Authenticate() error = %v, want the 401 of the token endpoint", err)
+		}
+		if _, err := client.Do("GET", api.URL, nil); err == nil {
+			t.Errorf("expected the request to fail without a token")
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"math/rand"
+)
+
+// Supported values of Options.BodyMode
+const (
+	BodyKeep    = "keep"    // Keep every response body
+	BodyDiscard = "discard" // Only count the bytes of the body
+	BodyHash    = "hash"    // Keep the SHA-256 hash and length of the body
+	BodyFailed  = "failed"  // Keep the bodies of non-2xx responses only
+	BodySample  = "sample"  // Keep a random sample of the bodies
+)
+
+// ValidateBodyOptions checks the response body settings.
+func ValidateBodyOptions(options Options) error {
+	switch options.BodyMode {
+	case "", BodyKeep, BodyDiscard, BodyHash, BodyFailed, BodySample:
+	default:
+		return fmt.Errorf("'%s' is not a valid body mode. Supported modes are: keep, discard, hash, failed, sample", options.BodyMode)
+	}
+	if options.BodySampleRate < 0 || options.BodySampleRate > 1 {
+		return fmt.Errorf("the body sample rate must be between 0 and 1")
+	}
+	if options.MaxBodySize < 0 {
+		return fmt.Errorf("the max body size cannot be negative")
+	}
+	return nil
+}
+
+// keepBody decides whether the body of a response is kept
+func (c *Client) keepBody(statusCode int) bool {
+	switch c.bodyMode {
+	case BodyDiscard, BodyHash:
+		return false
+	case BodyFailed:
+		return statusCode < 200 || statusCode >= 300
+	case BodySample:
+		return rand.Float64() < c.bodySampleRate
+	default:
+		return true
+	}
+}
+
+// readBody reads the response body as the body mode asks. At most the max
+// body size is read; a longer body is flagged as oversized and the rest is
+// left unread, which closes the connection. The body of a GraphQL response is
+// read whole to count its errors, and in failed mode kept when it has any.
+func (c *Client) readBody(body io.Reader, statusCode int, graphQL bool, response *Response) error {
+	if c.maxBodySize > 0 {
+		// Read one byte past the limit to tell a body of exactly the limit from a longer one
+		body = io.LimitReader(body, c.maxBodySize+1)
+	}
+
+	var kept bytes.Buffer
+	var writers []io.Writer
+	keep := c.keepBody(statusCode)
+	if keep || graphQL {
+		writers = append(writers, &kept)
+	}
+	var hasher hash.Hash
+	if c.bodyMode == BodyHash {
+		hasher = sha256.New()
+		writers = append(writers, hasher)
+	}
+
+	size, err := io.Copy(io.MultiWriter(append(writers, io.Discard)...), body)
+	if c.maxBodySize > 0 && size > c.maxBodySize {
+		size = c.maxBodySize
+		response.Oversized = true
+		if int64(kept.Len()) > c.maxBodySize {
+			kept.Truncate(int(c.maxBodySize))
+		}
+	}
+	response.BodySize = size
+	if graphQL && err == nil {
+		response.GraphQLErrors, response.GraphQLError = graphQLErrors(kept.Bytes(), statusCode, response.Oversized)
+		keep = keep || (c.bodyMode == BodyFailed && response.GraphQLErrors > 0)
+	}
+	if keep {
+		response.Body = kept.String()
+	}
+	if hasher != nil && !response.Oversized {
+		response.BodyHash = hex.EncodeToString(hasher.Sum(nil))
+	}
+	return err
+}
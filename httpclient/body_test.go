@@ -0,0 +1,64 @@
+package httpclient
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestClientBodyModes(t *testing.T) {
+	payload := strings.Repeat("a", 1000)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+		w.Write([]byte(payload))
+	}))
+	defer ts.Close()
+
+	sum := sha256.Sum256([]byte(payload))
+	payloadHash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name          string
+		options       Options
+		path          string
+		wantBody      string
+		wantSize      int64
+		wantHash      string
+		wantOversized bool
+	}{
+		{name: "keep", options: Options{}, wantBody: payload, wantSize: 1000},
+		{name: "discard", options: Options{BodyMode: BodyDiscard}, wantSize: 1000},
+		{name: "hash", options: Options{BodyMode: BodyHash}, wantSize: 1000, wantHash: payloadHash},
+		{name: "failed mode on success", options: Options{BodyMode: BodyFailed}, wantSize: 1000},
+		{name: "failed mode on failure", options: Options{BodyMode: BodyFailed}, path: "/fail", wantBody: payload, wantSize: 1000},
+		{name: "sample all", options: Options{BodyMode: BodySample, BodySampleRate: 1}, wantBody: payload, wantSize: 1000},
+		{name: "sample none", options: Options{BodyMode: BodySample, BodySampleRate: 0}, wantSize: 1000},
+		{name: "body at the limit", options: Options{MaxBodySize: 1000}, wantBody: payload, wantSize: 1000},
+		{name: "oversized", options: Options{MaxBodySize: 100}, wantBody: payload[:100], wantSize: 100, wantOversized: true},
+		{name: "oversized discarded", options: Options{BodyMode: BodyDiscard, MaxBodySize: 100}, wantSize: 100, wantOversized: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do("GET", ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != tt.wantBody {
+				t.Errorf("expected a body of %d bytes, got %d", len(tt.wantBody), len(resp.Body))
+			}
+			if resp.BodySize != tt.wantSize || resp.BodyHash != tt.wantHash || resp.Oversized != tt.wantOversized {
+				t.Errorf("got size %d, hash %q, oversized %v, want %d, %q, %v", resp.BodySize, resp.BodyHash, resp.Oversized, tt.wantSize, tt.wantHash, tt.wantOversized)
+			}
+		})
+	}
+}
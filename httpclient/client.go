@@ -1,52 +1,492 @@
 package httpclient
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	"crypto/tls"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"net/http/httptrace"
+	"net/url"
 	"os"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Options configures the transport of the HTTP client
+type Options struct {
+	// TLS settings
+	CAFile        string   // PEM bundle of trusted CAs, in addition to the system pool
+	CertFile      string   // Client certificate for mTLS
+	KeyFile       string   // Private key of the client certificate
+	Insecure      bool     // Skip verification of the server certificate
+	MinTLSVersion string   // "1.0", "1.1", "1.2" or "1.3"
+	MaxTLSVersion string   // "1.0", "1.1", "1.2" or "1.3"
+	CipherSuites  []string // Names of the TLS 1.0-1.2 cipher suites to offer
+	ServerName    string   // Overrides the SNI and the name the certificate is verified against
+
+	// Protocol settings
+	Protocol             string // "http1", "http2", "h2c" or "auto"
+	Connections          int    // Number of connections to spread requests over, 0 for the default pool
+	MaxConcurrentStreams int    // Requests in flight per connection when Connections is set, 0 for no limit
+	MaxIdleConnsPerHost  int    // Idle connections kept for reuse, 0 for the net/http default of 2
+
+	// Proxy settings
+	Proxy   string // http://, https://, socks5:// or socks5h:// proxy URL, credentials as user info
+	NoProxy bool   // Ignore HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+
+	// Connection target settings
+	UnixSocket string   // Connect to this Unix domain socket instead of the URL host
+	Resolve    []string // host:port:address entries that skip DNS for the host
+	ConnectTo  []string // host:port:connect-host:connect-port entries that redirect connections
+
+	// Response body settings
+	BodyMode       string  // "keep", "discard", "hash", "failed" or "sample"
+	BodySampleRate float64 // Fraction of the bodies kept in sample mode
+	MaxBodySize    int64   // Bytes read per response body, 0 for no limit
+
+	// Compression settings
+	AcceptEncoding  []string // Codings offered in Accept-Encoding: "gzip", "br" or "zstd"
+	RequestEncoding string   // Compress request bodies with this coding
+
+	// Send request bodies with chunked transfer encoding rather than a content length
+	Chunked bool
+
+	// Time allowed for each request, from sending it to reading the response, 0 for no limit
+	Timeout time.Duration
+
+	// Redirect settings
+	NoRedirects  bool // Return redirect responses instead of following them
+	MaxRedirects int  // Redirects followed per request, 0 to fail on any redirect
+
+	// Authentication settings, at most one kind. The secrets are left out of JSON.
+	BasicAuth          string   `json:"-"` // user:password
+	BearerToken        string   `json:"-"` // Static token sent as Authorization: Bearer
+	APIKey             string   `json:"-"` // name=value sent as a header or query parameter
+	APIKeyIn           string   // "header" or "query"
+	OAuth2TokenURL     string   // Token endpoint of the OAuth2 client credentials grant
+	OAuth2ClientID     string   // Sent to the token endpoint with basic auth
+	OAuth2ClientSecret string   `json:"-"` // Sent to the token endpoint with basic auth
+	OAuth2Scopes       []string // Scopes requested with the token
+
+	// Request signing settings, computed for every request
+	Signing             string // "hmac" or "aws-sigv4"
+	HMACSecret          string `json:"-"`
+	HMACCanonical       string // Template of the signed string, e.g. {method}\n{path}\n{timestamp}\n{body_sha256}
+	HMACSignatureHeader string // Header the hex signature is sent in, X-Signature by default
+	HMACTimestampHeader string // Header the Unix timestamp is sent in, X-Timestamp by default
+	AWSRegion           string
+	AWSService          string // e.g. execute-api or s3
+	AWSAccessKeyID      string // Taken from AWS_ACCESS_KEY_ID when empty
+	AWSSecretAccessKey  string `json:"-"`
+	AWSSessionToken     string `json:"-"`
+}
+
+// Client sends the benchmark requests. It is safe for concurrent use and
+// shares its connection pool between the requests.
+type Client struct {
+	httpClient *http.Client
+	timeout    time.Duration
+	protocol   string
+	proxy      func(*http.Request) (*url.URL, error)
+	tlsConfig  *tls.Config // Also used by WebSocket connections, which bypass the transport
+	dial       dialFunc
+
+	bodyMode       string
+	bodySampleRate float64
+	maxBodySize    int64
+
+	acceptEncoding  string
+	requestEncoding string
+	chunked         bool
+
+	auth   authenticator
+	signer signer
+}
+
+// Response holds what is recorded about a single response
+type Response struct {
+	Body        string
+	StatusCode  int
+	Protocol    string // Negotiated protocol, e.g. HTTP/1.1 or HTTP/2.0
+	TLSVersion  string // Negotiated TLS version, empty for plain HTTP
+	CipherSuite string // Negotiated cipher suite, empty for plain HTTP
+
+	ConnectionID     int  // Identifies the connection the request was sent on
+	ConnectionReused bool // The connection had served earlier requests
+	Phases           Phases
+
+	BodySize  int64  // Bytes of the body read, whether kept or not
+	BodyHash  string // SHA-256 of the body in hash mode
+	Oversized bool   // The body was longer than the max body size
+
+	ContentEncoding string        // Coding of the response body, e.g. gzip
+	ReceivedBytes   int64         // Bytes of the body as received, before decompression
+	RequestBodySize int64         // Bytes of the request body before compression
+	SentBytes       int64         // Bytes of the request body as sent
+	UploadTime      time.Duration // From the request headers being written to the end of the body
+
+	Hops             []RedirectHop // Redirects followed before the final response
+	FinalHopStart    time.Time     // When the request of the final response was sent
+	RedirectReturned bool          // The response is a redirect returned because redirects are off
+
+	TokenWait time.Duration // Time spent waiting for an OAuth2 token before the request was sent
+
+	GraphQLErrors int    // Errors in the errors array of a GraphQL response
+	GraphQLError  string // Message of the first of them
+}
+
+// Request describes a request to send
+type Request struct {
+	Method        string
+	URL           string
+	Header        http.Header
+	Body          io.Reader
+	ContentType   string // application/json when empty
+	ContentLength int64  // Length of a body reader that does not tell it, 0 if unknown
+	GraphQL       bool   // Count the errors of the GraphQL response
+}
+
+// defaultClient uses the default transport of the standard library
+var defaultClient = &Client{
+	httpClient: &http.Client{
+		Timeout: time.Second * 30,
+	},
+	timeout: time.Second * 30,
+	proxy:   http.ProxyFromEnvironment,
+}
+
+// NewClient creates a client with a transport configured by the options.
+func NewClient(options Options) (*Client, error) {
+	if err := ValidateBodyOptions(options); err != nil {
+		return nil, err
+	}
+	if err := ValidateEncodings(options); err != nil {
+		return nil, err
+	}
+	if err := ValidateRedirects(options); err != nil {
+		return nil, err
+	}
+	if err := ValidateAuth(options); err != nil {
+		return nil, err
+	}
+	if err := ValidateSigning(options); err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := newTLSConfig(options)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy, err := newProxyFunc(options)
+	if err != nil {
+		return nil, err
+	}
+
+	dial, err := newDialFunc(options)
+	if err != nil {
+		return nil, err
+	}
+	dial = numberConnections(dial)
+
+	transport, err := newTransport(options, tlsConfig, proxy, dial)
+	if err != nil {
+		return nil, err
+	}
+
+	auth, err := newAuthenticator(options, tlsConfig, proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		httpClient: &http.Client{
+			Transport:     transport,
+			Timeout:       options.Timeout,
+			CheckRedirect: checkRedirect(options),
+		},
+		timeout:   options.Timeout,
+		protocol:  options.Protocol,
+		proxy:     proxy,
+		tlsConfig: tlsConfig,
+		dial:      dial,
+
+		bodyMode:       options.BodyMode,
+		bodySampleRate: options.BodySampleRate,
+		maxBodySize:    options.MaxBodySize,
+
+		acceptEncoding:  strings.Join(options.AcceptEncoding, ", "),
+		requestEncoding: options.RequestEncoding,
+		chunked:         options.Chunked,
+
+		auth:   auth,
+		signer: newSigner(options),
+	}, nil
+}
+
 // Sends an HTTP request and returns the response body as a string, the status code, and an error if any.
 func HttpRequest(method, url string, body io.Reader) (string, int, error) {
-	// Create a context with a timeout to allow for request cancellation
-	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-	defer cancel()
+	resp, err := defaultClient.Do(method, url, body)
+	return resp.Body, resp.StatusCode, err
+}
+
+// Do sends an HTTP request and returns the recorded response.
+func (c *Client) Do(method, url string, body io.Reader) (Response, error) {
+	return c.DoRequest(Request{Method: method, URL: url, Body: body})
+}
+
+// DoRequest sends an HTTP request with headers and returns the recorded response.
+func (c *Client) DoRequest(request Request) (Response, error) {
+	return c.DoRequestContext(context.Background(), request)
+}
+
+// DoRequestContext sends the request, giving up when the context is done.
+func (c *Client) DoRequestContext(ctx context.Context, request Request) (Response, error) {
+	// Give up on the request when its time is up, if it is limited
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
+	}
+
+	// Compress the request body up front so it is sent with a content length
+	body := request.Body
+	requestBodySize := int64(-1)
+	if body != nil && c.requestEncoding != "" {
+		encoded, size, err := encodeBody(body, c.requestEncoding)
+		if err != nil {
+			return Response{}, fmt.Errorf("error compressing request body: %v", err)
+		}
+		body, requestBodySize = encoded, size
+	}
 
 	// Create a new HTTP request with the context
-	req, err := http.NewRequestWithContext(ctx, method, url, body)
+	req, err := http.NewRequestWithContext(ctx, request.Method, request.URL, body)
 	if err != nil {
-		return "", 0, fmt.Errorf("error creating request: %v", err)
+		return Response{}, fmt.Errorf("error creating request: %v", err)
+	}
+	if req.ContentLength == 0 && request.ContentLength > 0 {
+		req.ContentLength = request.ContentLength
 	}
 
-	// Set the Content-Type header if there is a body.
-	// For the purpose of the project, assume the API to be tested expects JSON requests.
-	if body != nil {
-		req.Header.Set("Content-Type", "application/json")
+	// Add the credentials, waiting for a token if one is being fetched
+	var tokenWait time.Duration
+	if c.auth != nil {
+		if tokenWait, err = c.auth.authenticate(req); err != nil {
+			return Response{TokenWait: tokenWait}, err
+		}
 	}
 
-	// Create an HTTP client with timeout settings
-	client := &http.Client{
-		Timeout: time.Second * 30, // Optionally set a timeout for the client
+	// Trace the connection and the phases of the request, and record the redirects followed
+	trace := newTracer(c.usesProxy(req))
+	redirects := &redirectRecorder{hopStart: time.Now()}
+	ctx = withRedirectRecorder(ctx, redirects)
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()))
+
+	// Set the Content-Type header if there is a body. Unless another content
+	// type is given, assume the API to be tested expects JSON requests.
+	if request.Body != nil {
+		contentType := request.ContentType
+		if contentType == "" {
+			contentType = ContentTypeJSON
+		}
+		req.Header.Set("Content-Type", contentType)
+		if c.requestEncoding != "" {
+			req.Header.Set("Content-Encoding", c.requestEncoding)
+		}
+	}
+	if c.acceptEncoding != "" {
+		// With Accept-Encoding set, the transport leaves decompression to us
+		req.Header.Set("Accept-Encoding", c.acceptEncoding)
+	}
+	setHeaders(req, request.Header)
+
+	// Sign the request once its headers are final
+	if c.signer != nil {
+		if err := c.signRequest(req); err != nil {
+			return Response{TokenWait: tokenWait}, fmt.Errorf("error signing request: %v", err)
+		}
+	}
+
+	// Count the request body bytes as they are sent
+	var sent *countingReader
+	if req.Body != nil && req.Body != http.NoBody {
+		if c.chunked {
+			// An unknown length makes HTTP/1.1 send the body chunked
+			req.ContentLength = -1
+		}
+		sent = &countingReader{reader: req.Body}
+		req.Body = struct {
+			io.Reader
+			io.Closer
+		}{sent, req.Body}
 	}
 
 	// Make the HTTP request
-	resp, err := client.Do(req)
+	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return "", 0, fmt.Errorf("error making request: %v", err)
+		response := trace.snapshot()
+		response.Hops, response.FinalHopStart = redirects.hops, redirects.hopStart
+		response.TokenWait = tokenWait
+		return response, fmt.Errorf("error making request: %v", err)
 	}
 	defer resp.Body.Close()
+	response := trace.snapshot()
+	response.Hops, response.FinalHopStart = redirects.hops, redirects.hopStart
+	response.RedirectReturned = redirects.returned
+	response.TokenWait = tokenWait
+
+	response.StatusCode = resp.StatusCode
+	response.Protocol = resp.Proto
+	if c.protocol == ProtocolHTTP2 && resp.ProtoMajor != 2 {
+		return response, fmt.Errorf("server did not negotiate HTTP/2, got %s", resp.Proto)
+	}
+	if resp.TLS != nil {
+		response.TLSVersion = tlsVersionName(resp.TLS.Version)
+		response.CipherSuite = tls.CipherSuiteName(resp.TLS.CipherSuite)
+	}
+
+	// Record the request body sizes and how long the upload took
+	if sent == nil {
+		response.UploadTime = 0
+	} else {
+		response.SentBytes = sent.bytes()
+		response.RequestBodySize = response.SentBytes
+		if requestBodySize >= 0 {
+			response.RequestBodySize = requestBodySize
+		}
+	}
+
+	// Read the response body, decompressing it if it is in a coding we asked
+	// for, unless the transport already has
+	received := &countingReader{reader: resp.Body}
+	response.ContentEncoding = resp.Header.Get("Content-Encoding")
+	if resp.Uncompressed {
+		response.ContentEncoding = EncodingGzip
+	}
+	var responseBody io.Reader = received
+	if coding := responseCoding(req.Header.Get("Accept-Encoding"), resp.Header.Get("Content-Encoding")); coding != "" {
+		buffered := bufio.NewReader(received)
+		responseBody = buffered
+		// Empty bodies, e.g. of 204 and HEAD responses, have nothing to decompress
+		if _, err := buffered.Peek(1); err == nil {
+			decoded, err := decodeBody(buffered, coding)
+			if err != nil {
+				return response, fmt.Errorf("error decompressing response body: %v", err)
+			}
+			defer decoded.Close()
+			responseBody = decoded
+		}
+	}
 
-	// Read the response body
-	respBody, err := io.ReadAll(resp.Body)
+	err = c.readBody(responseBody, resp.StatusCode, request.GraphQL, &response)
+	response.ReceivedBytes = received.bytes()
 	if err != nil {
-		return "", resp.StatusCode, fmt.Errorf("error reading response body: %v", err)
+		return response, fmt.Errorf("error reading response body: %v", err)
 	}
 
-	return string(respBody), resp.StatusCode, nil
+	return response, nil
+}
+
+// setHeaders sets the given headers on the request, replacing the ones set by the client
+func setHeaders(req *http.Request, header http.Header) {
+	for name, values := range header {
+		req.Header.Del(name)
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if host := req.Header.Get("Host"); host != "" {
+		// net/http takes the Host header from the request, not the header map
+		req.Host = host
+	}
+}
+
+// signRequest signs the request, reading its body into memory first so the
+// signature can cover it
+func (c *Client) signRequest(req *http.Request) error {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+		req.ContentLength = int64(len(body))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	return c.signer.sign(req, body)
+}
+
+// usesProxy reports whether the request is sent through a proxy
+func (c *Client) usesProxy(req *http.Request) bool {
+	if c.proxy == nil {
+		return false
+	}
+	proxyURL, err := c.proxy(req)
+	return err == nil && proxyURL != nil
+}
+
+// numberedConn is a connection numbered in the order the client opened it.
+// The number lives on the connection, so the client keeps no state for the
+// connections it has opened.
+type numberedConn struct {
+	net.Conn
+	id int
+}
+
+// numberConnections numbers the connections the dial function opens
+func numberConnections(dial dialFunc) dialFunc {
+	var lastID int64
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &numberedConn{Conn: conn, id: int(atomic.AddInt64(&lastID, 1))}, nil
+	}
+}
+
+// connectionID returns the number of the connection a request was sent on,
+// looking through the TLS and SOCKS layers the transport wraps it in. It is 0
+// for connections not opened by the client, e.g. those of the default client.
+func connectionID(conn net.Conn) int {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *numberedConn:
+			return c.id
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			conn = embeddedConn(conn)
+		}
+	}
+	return 0
+}
+
+// embeddedConn returns the connection a wrapper embeds, as the SOCKS
+// connections of net/http do, or nil if it embeds none
+func embeddedConn(conn net.Conn) net.Conn {
+	wrapper := reflect.ValueOf(conn)
+	if wrapper.Kind() != reflect.Ptr || wrapper.Elem().Kind() != reflect.Struct {
+		return nil
+	}
+	field := wrapper.Elem().FieldByName("Conn")
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+	inner, _ := field.Interface().(net.Conn)
+	return inner
 }
 
 // getRequestBody handles the retrieval of the request body.
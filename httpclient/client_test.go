@@ -1,13 +1,16 @@
 package httpclient
 
 import (
+	"crypto/tls"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGetRequestBody(t *testing.T) {
@@ -87,3 +90,137 @@ func TestHttpRequest(t *testing.T) {
 		}
 	})
 }
+
+func TestClientConnectionReuse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Close the connection after every request to a /close path
+		if r.URL.Path == "/close" {
+			w.Header().Set("Connection", "close")
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var responses []Response
+	for _, path := range []string{"/", "/", "/close", "/"} {
+		resp, err := client.Do("GET", ts.URL+path, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		responses = append(responses, resp)
+	}
+
+	wantIDs := []int{1, 1, 1, 2}
+	wantReused := []bool{false, true, true, false}
+	for i, resp := range responses {
+		if resp.ConnectionID != wantIDs[i] || resp.ConnectionReused != wantReused[i] {
+			t.Errorf("request %d: got connection %d (reused %v), want %d (reused %v)", i, resp.ConnectionID, resp.ConnectionReused, wantIDs[i], wantReused[i])
+		}
+	}
+}
+
+func TestClientConnectionChurn(t *testing.T) {
+	// Every response closes its connection, over TLS to look through the TLS layer
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Connection", "close")
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(Options{Insecure: true, Protocol: ProtocolHTTP1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i := 1; i <= 5; i++ {
+		resp, err := client.Do("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.ConnectionID != i || resp.ConnectionReused {
+			t.Errorf("request %d: got connection %d (reused %v), want %d (reused false)", i, resp.ConnectionID, resp.ConnectionReused, i)
+		}
+	}
+}
+
+// socksConn wraps a connection the way the SOCKS dialer of net/http does
+type socksConn struct {
+	net.Conn
+	boundAddr net.Addr
+}
+
+func TestConnectionID(t *testing.T) {
+	plain, _ := net.Pipe()
+	numbered := &numberedConn{Conn: plain, id: 7}
+
+	tests := []struct {
+		name string
+		conn net.Conn
+		want int
+	}{
+		{name: "numbered", conn: numbered, want: 7},
+		{name: "TLS", conn: tls.Client(numbered, &tls.Config{}), want: 7},
+		{name: "TLS over SOCKS", conn: tls.Client(&socksConn{Conn: numbered}, &tls.Config{}), want: 7},
+		{name: "not opened by the client", conn: plain, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := connectionID(tt.conn); got != tt.want {
+				t.Errorf("connectionID() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientRequestHeaders(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Host + " " + r.Header.Get("Content-Type") + " " + r.Header.Get("X-Tenant")))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.DoRequest(Request{
+		Method: "POST",
+		URL:    ts.URL,
+		Header: http.Header{"Content-Type": {"text/plain"}, "X-Tenant": {"acme"}, "Host": {"api.example.com"}},
+		Body:   strings.NewReader("hello"),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "api.example.com text/plain acme"; resp.Body != want {
+		t.Errorf("expected %q, got %q", want, resp.Body)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(100 * time.Millisecond)
+		w.Write([]byte("slow"))
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(Options{Timeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := client.Do("GET", ts.URL, nil); err == nil {
+		t.Error("expected the request to time out")
+	}
+
+	// Without a timeout the request takes as long as it needs
+	client, _ = NewClient(Options{})
+	resp, err := client.Do("GET", ts.URL, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Body != "slow" {
+		t.Errorf("expected %q, got %q", "slow", resp.Body)
+	}
+}
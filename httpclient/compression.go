@@ -0,0 +1,129 @@
+package httpclient
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Supported content codings for Options.AcceptEncoding and Options.RequestEncoding
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+	EncodingZstd   = "zstd"
+)
+
+// ValidateEncodings checks the response and request codings.
+func ValidateEncodings(options Options) error {
+	for _, encoding := range options.AcceptEncoding {
+		if !supportedEncoding(encoding) {
+			return fmt.Errorf("'%s' is not a supported encoding. Supported encodings are: gzip, br, zstd", encoding)
+		}
+	}
+	if options.RequestEncoding != "" && !supportedEncoding(options.RequestEncoding) {
+		return fmt.Errorf("'%s' is not a supported request encoding. Supported encodings are: gzip, br, zstd", options.RequestEncoding)
+	}
+	return nil
+}
+
+func supportedEncoding(encoding string) bool {
+	switch encoding {
+	case EncodingGzip, EncodingBrotli, EncodingZstd:
+		return true
+	}
+	return false
+}
+
+// encodeBody compresses the whole request body so it is sent with a content
+// length. It returns the compressed body and the size before compression.
+func encodeBody(body io.Reader, encoding string) (*bytes.Reader, int64, error) {
+	var compressed bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case EncodingGzip:
+		writer = gzip.NewWriter(&compressed)
+	case EncodingBrotli:
+		writer = brotli.NewWriter(&compressed)
+	case EncodingZstd:
+		encoder, err := zstd.NewWriter(&compressed, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, 0, err
+		}
+		writer = encoder
+	default:
+		return nil, 0, fmt.Errorf("unsupported request encoding: %s", encoding)
+	}
+
+	size, err := io.Copy(writer, body)
+	if err != nil {
+		writer.Close()
+		return nil, 0, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, 0, err
+	}
+	return bytes.NewReader(compressed.Bytes()), size, nil
+}
+
+// responseCoding returns the coding to decompress a response body with, or ""
+// to read it as it came. Only a single coding the request offered in its
+// Accept-Encoding and the client supports is decompressed; a response in any
+// other coding, or in several, is counted as received and not failed.
+func responseCoding(acceptEncoding, contentEncoding string) string {
+	coding := strings.ToLower(strings.TrimSpace(contentEncoding))
+	if coding == "x-gzip" {
+		coding = EncodingGzip
+	}
+	if !supportedEncoding(coding) {
+		return ""
+	}
+	for _, offered := range strings.Split(acceptEncoding, ",") {
+		offered = strings.ToLower(strings.TrimSpace(strings.Split(offered, ";")[0]))
+		if offered == coding || (offered == "x-gzip" && coding == EncodingGzip) {
+			return coding
+		}
+	}
+	return ""
+}
+
+// decodeBody returns a reader of the decompressed response body
+func decodeBody(body io.Reader, contentEncoding string) (io.ReadCloser, error) {
+	switch strings.ToLower(strings.TrimSpace(contentEncoding)) {
+	case "", "identity":
+		return io.NopCloser(body), nil
+	case EncodingGzip, "x-gzip":
+		return gzip.NewReader(body)
+	case EncodingBrotli:
+		return io.NopCloser(brotli.NewReader(body)), nil
+	case EncodingZstd:
+		decoder, err := zstd.NewReader(body, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder.IOReadCloser(), nil
+	}
+	return nil, fmt.Errorf("unsupported content encoding: %s", contentEncoding)
+}
+
+// countingReader counts the bytes read through it. The transport may still
+// be writing a request body while the response is read, so the count is atomic.
+type countingReader struct {
+	reader io.Reader
+	count  int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	atomic.AddInt64(&r.count, int64(n))
+	return n, err
+}
+
+func (r *countingReader) bytes() int64 {
+	return atomic.LoadInt64(&r.count)
+}
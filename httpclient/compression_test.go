@@ -0,0 +1,160 @@
+package httpclient
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// compressingHandler echoes the decompressed request body, or a fixed payload
+// for requests without one, compressed with the first accepted coding
+func compressingHandler(payload string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := decodeBody(r.Body, r.Header.Get("Content-Encoding"))
+		if err != nil {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		content, _ := io.ReadAll(body)
+		if len(content) == 0 {
+			content = []byte(payload)
+		}
+
+		encoding := strings.TrimSpace(strings.Split(r.Header.Get("Accept-Encoding"), ",")[0])
+		if !supportedEncoding(encoding) {
+			w.Write(content)
+			return
+		}
+		encoded, _, _ := encodeBody(bytes.NewReader(content), encoding)
+		w.Header().Set("Content-Encoding", encoding)
+		io.Copy(w, encoded)
+	}
+}
+
+func TestClientCompression(t *testing.T) {
+	payload := strings.Repeat(`{"id": 1, "name": "item"}`, 200)
+	ts := httptest.NewServer(compressingHandler(payload))
+	defer ts.Close()
+
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli, EncodingZstd} {
+		t.Run("accept "+encoding, func(t *testing.T) {
+			client, err := NewClient(Options{AcceptEncoding: []string{encoding}})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do("GET", ts.URL, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != payload || resp.ContentEncoding != encoding {
+				t.Errorf("expected the decompressed payload with %s, got %d bytes with %q", encoding, len(resp.Body), resp.ContentEncoding)
+			}
+			if resp.BodySize != int64(len(payload)) || resp.ReceivedBytes <= 0 || resp.ReceivedBytes >= resp.BodySize {
+				t.Errorf("expected fewer bytes received than decompressed, got %d received and %d decompressed", resp.ReceivedBytes, resp.BodySize)
+			}
+		})
+
+		t.Run("request "+encoding, func(t *testing.T) {
+			client, err := NewClient(Options{RequestEncoding: encoding})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do("POST", ts.URL, strings.NewReader(payload))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != http.StatusOK || resp.Body != payload {
+				t.Errorf("expected the server to decompress the request body, got status %d and %d bytes", resp.StatusCode, len(resp.Body))
+			}
+			if resp.RequestBodySize != int64(len(payload)) || resp.SentBytes <= 0 || resp.SentBytes >= resp.RequestBodySize {
+				t.Errorf("expected fewer bytes sent than the body size, got %d sent for %d bytes", resp.SentBytes, resp.RequestBodySize)
+			}
+		})
+	}
+
+	t.Run("transparent gzip", func(t *testing.T) {
+		// Without Accept-Encoding the transport asks for gzip and decompresses
+		// on its own, so the size as received is not known
+		client, _ := NewClient(Options{})
+		resp, err := client.Do("POST", ts.URL, strings.NewReader("plain"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.ContentEncoding != EncodingGzip || resp.ReceivedBytes != 5 || resp.SentBytes != 5 || resp.RequestBodySize != 5 {
+			t.Errorf("unexpected sizes %+v", resp)
+		}
+	})
+}
+
+func TestClientUndecodedResponses(t *testing.T) {
+	// Responses in codings the client does not decompress are counted as they came
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/deflate":
+			w.Header().Set("Content-Encoding", "deflate")
+			w.Write([]byte("raw deflate"))
+		case "/stacked":
+			w.Header().Set("Content-Encoding", "gzip, br")
+			w.Write([]byte("raw stacked"))
+		case "/empty":
+			w.Header().Set("Content-Encoding", EncodingGzip)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		options  Options
+		method   string
+		path     string
+		wantBody string
+	}{
+		{name: "coding not asked for", options: Options{}, method: "GET", path: "/deflate", wantBody: "raw deflate"},
+		{name: "stacked codings", options: Options{AcceptEncoding: []string{EncodingGzip}}, method: "GET", path: "/stacked", wantBody: "raw stacked"},
+		{name: "empty body", options: Options{AcceptEncoding: []string{EncodingGzip}}, method: "GET", path: "/empty"},
+		{name: "HEAD response", options: Options{AcceptEncoding: []string{EncodingGzip}}, method: "HEAD", path: "/deflate"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do(tt.method, ts.URL+tt.path, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != tt.wantBody || resp.ReceivedBytes != int64(len(tt.wantBody)) {
+				t.Errorf("expected %q received as is, got %q after %d bytes", tt.wantBody, resp.Body, resp.ReceivedBytes)
+			}
+		})
+	}
+}
+
+func TestResponseCoding(t *testing.T) {
+	tests := []struct {
+		acceptEncoding  string
+		contentEncoding string
+		want            string
+	}{
+		{acceptEncoding: "gzip, br", contentEncoding: "br", want: EncodingBrotli},
+		{acceptEncoding: "gzip;q=1.0, zstd;q=0.5", contentEncoding: "ZSTD", want: EncodingZstd},
+		{acceptEncoding: "gzip", contentEncoding: "x-gzip", want: EncodingGzip},
+		{acceptEncoding: "", contentEncoding: "gzip"},
+		{acceptEncoding: "br", contentEncoding: "gzip"},
+		{acceptEncoding: "deflate", contentEncoding: "deflate"},
+		{acceptEncoding: "gzip, br", contentEncoding: "gzip, br"},
+		{acceptEncoding: "gzip", contentEncoding: ""},
+	}
+
+	for _, tt := range tests {
+		if got := responseCoding(tt.acceptEncoding, tt.contentEncoding); got != tt.want {
+			t.Errorf("responseCoding(%q, %q) = %q, want %q", tt.acceptEncoding, tt.contentEncoding, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// dialFunc opens the connections of a transport
+type dialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// connectTo redirects connections for HOST:PORT to another host and port.
+// Empty fields match any host or port on the left and keep the original on the right.
+type connectTo struct {
+	host, port     string
+	toHost, toPort string
+}
+
+// dialer decides where connections go. The URL of the request is not changed,
+// so the Host header and the TLS server name still come from the URL.
+type dialer struct {
+	dialer     *net.Dialer
+	unixSocket string
+	resolve    map[string]string // host:port to the address to connect to
+	connectTo  []connectTo
+	proxies    map[string]bool // host:port of the proxies, which the rules leave alone
+}
+
+// newDialFunc returns the dial function of the transport.
+func newDialFunc(options Options) (dialFunc, error) {
+	d := &dialer{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		unixSocket: options.UnixSocket,
+		resolve:    map[string]string{},
+		proxies:    proxyAddresses(options),
+	}
+
+	for _, entry := range options.Resolve {
+		fields, err := splitAddressFields(entry)
+		if err != nil || len(fields) != 3 || fields[0] == "" || fields[1] == "" || fields[2] == "" {
+			return nil, fmt.Errorf("'%s' is not a valid resolve entry. The format is host:port:address", entry)
+		}
+		if net.ParseIP(fields[2]) == nil {
+			return nil, fmt.Errorf("'%s' is not a valid resolve entry. The address must be an IP address", entry)
+		}
+		d.resolve[net.JoinHostPort(strings.ToLower(fields[0]), fields[1])] = fields[2]
+	}
+
+	for _, entry := range options.ConnectTo {
+		fields, err := splitAddressFields(entry)
+		if err != nil || len(fields) != 4 {
+			return nil, fmt.Errorf("'%s' is not a valid connect-to entry. The format is host:port:connect-host:connect-port", entry)
+		}
+		d.connectTo = append(d.connectTo, connectTo{
+			host:   strings.ToLower(fields[0]),
+			port:   fields[1],
+			toHost: fields[2],
+			toPort: fields[3],
+		})
+	}
+
+	return d.DialContext, nil
+}
+
+// ValidateDialOptions checks the Unix socket, resolve and connect-to settings.
+func ValidateDialOptions(options Options) error {
+	if options.UnixSocket != "" {
+		if len(options.Resolve) > 0 || len(options.ConnectTo) > 0 {
+			return fmt.Errorf("a Unix socket cannot be combined with resolve or connect-to")
+		}
+		if options.Proxy != "" {
+			return fmt.Errorf("a Unix socket cannot be used with a proxy")
+		}
+	}
+	_, err := newDialFunc(options)
+	return err
+}
+
+// DialContext connects to the Unix socket if one is set. Otherwise the first
+// matching connect-to entry is applied before the host is looked up in the
+// resolve entries, like curl does. Connections to a proxy are left as they
+// are: the proxy connects to the target itself, so the rules do not reach it.
+func (d *dialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	if d.unixSocket != "" {
+		return d.dialer.DialContext(ctx, "unix", d.unixSocket)
+	}
+
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil || d.proxies[net.JoinHostPort(strings.ToLower(host), port)] {
+		return d.dialer.DialContext(ctx, network, addr)
+	}
+
+	for _, rule := range d.connectTo {
+		if (rule.host == "" || rule.host == strings.ToLower(host)) && (rule.port == "" || rule.port == port) {
+			if rule.toHost != "" {
+				host = rule.toHost
+			}
+			if rule.toPort != "" {
+				port = rule.toPort
+			}
+			break
+		}
+	}
+
+	if address, ok := d.resolve[net.JoinHostPort(strings.ToLower(host), port)]; ok {
+		host = address
+	}
+	return d.dialer.DialContext(ctx, network, net.JoinHostPort(host, port))
+}
+
+// splitAddressFields splits a colon separated entry. IPv6 addresses are
+// given in brackets, e.g. example.com:443:[::1].
+func splitAddressFields(entry string) ([]string, error) {
+	var fields []string
+	for {
+		var field string
+		if strings.HasPrefix(entry, "[") {
+			end := strings.Index(entry, "]")
+			if end < 0 {
+				return nil, fmt.Errorf("missing ']' in %s", entry)
+			}
+			field, entry = entry[1:end], entry[end+1:]
+			if entry != "" && !strings.HasPrefix(entry, ":") {
+				return nil, fmt.Errorf("expected ':' after ']' in %s", entry)
+			}
+		} else if i := strings.Index(entry, ":"); i >= 0 {
+			field, entry = entry[:i], entry[i:]
+		} else {
+			field, entry = entry, ""
+		}
+		fields = append(fields, field)
+
+		if entry == "" {
+			return fields, nil
+		}
+		entry = entry[1:]
+	}
+}
@@ -0,0 +1,132 @@
+package httpclient
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// hostHandler responds with the Host header and the TLS server name it received
+var hostHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	serverName := ""
+	if r.TLS != nil {
+		serverName = r.TLS.ServerName
+	}
+	w.Write([]byte(r.Host + " " + serverName))
+})
+
+func TestClientUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "app.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(hostHandler)
+	ts.Listener = listener
+	ts.Start()
+	defer ts.Close()
+
+	client, err := NewClient(Options{UnixSocket: socket})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	resp, err := client.Do("GET", "http://sidecar.local/health", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Body != "sidecar.local " {
+		t.Errorf("expected the Host header from the URL, got %q", resp.Body)
+	}
+}
+
+func TestClientResolve(t *testing.T) {
+	ts := httptest.NewTLSServer(hostHandler)
+	defer ts.Close()
+	_, port, _ := net.SplitHostPort(ts.Listener.Addr().String())
+
+	// The certificate of httptest servers is valid for example.com
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", ts.Certificate().Raw)
+	want := "example.com:" + port + " example.com"
+
+	t.Run("resolve", func(t *testing.T) {
+		client, err := NewClient(Options{CAFile: caFile, Resolve: []string{"example.com:" + port + ":127.0.0.1"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", "https://example.com:"+port, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != want {
+			t.Errorf("expected %q, got %q", want, resp.Body)
+		}
+	})
+
+	t.Run("connect-to", func(t *testing.T) {
+		client, err := NewClient(Options{CAFile: caFile, ConnectTo: []string{"example.com:443:127.0.0.1:" + port}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", "https://example.com:"+port, nil)
+		if err == nil {
+			t.Fatalf("expected the rule for port 443 not to match, got %q", resp.Body)
+		}
+
+		client, _ = NewClient(Options{CAFile: caFile, ConnectTo: []string{"example.com::127.0.0.1:"}})
+		resp, err = client.Do("GET", "https://example.com:"+port, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != want {
+			t.Errorf("expected %q, got %q", want, resp.Body)
+		}
+	})
+
+	t.Run("connect-to then resolve", func(t *testing.T) {
+		client, err := NewClient(Options{
+			CAFile:    caFile,
+			ConnectTo: []string{"example.com:" + port + ":backend.internal:" + port},
+			Resolve:   []string{"backend.internal:" + port + ":127.0.0.1"},
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", "https://example.com:"+port, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != want {
+			t.Errorf("expected %q, got %q", want, resp.Body)
+		}
+	})
+}
+
+func TestSplitAddressFields(t *testing.T) {
+	tests := []struct {
+		entry   string
+		want    []string
+		wantErr bool
+	}{
+		{entry: "example.com:443:10.0.0.1", want: []string{"example.com", "443", "10.0.0.1"}},
+		{entry: "example.com:443:[::1]", want: []string{"example.com", "443", "::1"}},
+		{entry: "[::1]:80::", want: []string{"::1", "80", "", ""}},
+		{entry: "example.com:443:[::1", wantErr: true},
+		{entry: "[::1]x:80", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.entry, func(t *testing.T) {
+			got, err := splitAddressFields(tt.entry)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("splitAddressFields() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitAddressFields() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
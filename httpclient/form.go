@@ -0,0 +1,152 @@
+package httpclient
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Content types set by the client
+const (
+	ContentTypeJSON = "application/json"
+	ContentTypeForm = "application/x-www-form-urlencoded"
+)
+
+// Body is a request body along with how it is described to the server
+type Body struct {
+	Reader        io.Reader
+	ContentType   string // application/json when empty
+	ContentLength int64  // Length of a reader that does not tell it, 0 if unknown
+}
+
+// FormField is a field of a form body. In a multipart form, a field with a
+// path uploads the file.
+type FormField struct {
+	Name        string
+	Value       string
+	Path        string // File to upload
+	ContentType string // Content type of the file, guessed from the extension when empty
+}
+
+// ParseFormField parses a form field given as name=value, name=@path or
+// name=@path;type=content/type.
+func ParseFormField(value string) (FormField, error) {
+	name, fieldValue, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return FormField{}, fmt.Errorf("'%s' is not a valid form field, use name=value or name=@path", value)
+	}
+	if !strings.HasPrefix(fieldValue, "@") {
+		return FormField{Name: name, Value: fieldValue}, nil
+	}
+
+	path, contentType, _ := strings.Cut(strings.TrimPrefix(fieldValue, "@"), ";type=")
+	if path == "" {
+		return FormField{}, fmt.Errorf("'%s' is not a valid form field, the file path is empty", value)
+	}
+	return FormField{Name: name, Path: path, ContentType: contentType}, nil
+}
+
+// NewFormBody returns the fields URL-encoded in the order given
+func NewFormBody(fields []FormField) Body {
+	encoded := make([]string, len(fields))
+	for i, field := range fields {
+		encoded[i] = url.QueryEscape(field.Name) + "=" + url.QueryEscape(field.Value)
+	}
+	return Body{Reader: strings.NewReader(strings.Join(encoded, "&")), ContentType: ContentTypeForm}
+}
+
+// NewMultipartBody returns a multipart/form-data body of the fields with a new
+// boundary. Files are streamed from disk rather than read into memory, and the
+// length of the body is worked out up front so it is not sent chunked. The
+// returned function closes the files.
+func NewMultipartBody(fields []FormField) (Body, func(), error) {
+	var files []*os.File
+	cleanup := func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}
+
+	// The multipart writer only writes the part headers and boundaries. The
+	// body is read from the buffered segments with the files in between.
+	var segment bytes.Buffer
+	writer := multipart.NewWriter(&segment)
+	var readers []io.Reader
+	var length int64
+	endSegment := func() {
+		length += int64(segment.Len())
+		readers = append(readers, bytes.NewReader(append([]byte(nil), segment.Bytes()...)))
+		segment.Reset()
+	}
+
+	for _, field := range fields {
+		if field.Path == "" {
+			if err := writer.WriteField(field.Name, field.Value); err != nil {
+				cleanup()
+				return Body{}, nil, err
+			}
+			continue
+		}
+
+		file, err := os.Open(field.Path)
+		if err != nil {
+			cleanup()
+			return Body{}, nil, fmt.Errorf("error opening file: %v", err)
+		}
+		files = append(files, file)
+		info, err := file.Stat()
+		if err != nil {
+			cleanup()
+			return Body{}, nil, fmt.Errorf("error opening file: %v", err)
+		}
+
+		if _, err := writer.CreatePart(filePartHeader(field)); err != nil {
+			cleanup()
+			return Body{}, nil, err
+		}
+		endSegment()
+		readers = append(readers, file)
+		length += info.Size()
+	}
+	if err := writer.Close(); err != nil {
+		cleanup()
+		return Body{}, nil, err
+	}
+	endSegment()
+
+	return Body{
+		Reader:        io.MultiReader(readers...),
+		ContentType:   writer.FormDataContentType(),
+		ContentLength: length,
+	}, cleanup, nil
+}
+
+// filePartHeader returns the header of a file part
+func filePartHeader(field FormField) textproto.MIMEHeader {
+	contentType := field.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(field.Path))
+	}
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="%s"; filename="%s"`, escapeQuotes(field.Name), escapeQuotes(filepath.Base(field.Path))))
+	header.Set("Content-Type", contentType)
+	return header
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// escapeQuotes escapes a quoted parameter like mime/multipart does
+func escapeQuotes(s string) string {
+	return quoteEscaper.Replace(s)
+}
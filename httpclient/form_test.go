@@ -0,0 +1,128 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFormField(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   FormField
+		errMsg string
+	}{
+		{value: "name=x", want: FormField{Name: "name", Value: "x"}},
+		{value: "query=a=b", want: FormField{Name: "query", Value: "a=b"}},
+		{value: "empty=", want: FormField{Name: "empty"}},
+		{value: "file=@photo.png", want: FormField{Name: "file", Path: "photo.png"}},
+		{value: "file=@data.bin;type=application/x-custom", want: FormField{Name: "file", Path: "data.bin", ContentType: "application/x-custom"}},
+		{value: "name", errMsg: "'name' is not a valid form field, use name=value or name=@path"},
+		{value: "=x", errMsg: "'=x' is not a valid form field, use name=value or name=@path"},
+		{value: "file=@", errMsg: "'file=@' is not a valid form field, the file path is empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := ParseFormField(tt.value)
+			if tt.errMsg != "" {
+				if err == nil || err.Error() != tt.errMsg {
+					t.Errorf("ParseFormField() error = %v, want %v", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseFormField() = %+v, %v, want %+v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestClientFormBodies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentType := r.Header.Get("Content-Type")
+		switch {
+		case strings.HasPrefix(contentType, "multipart/form-data"):
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			file, header, err := r.FormFile("upload")
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			content, _ := io.ReadAll(file)
+			fmt.Fprintf(w, "%s|%s|%s|%s|%d", r.FormValue("title"), header.Filename, header.Header.Get("Content-Type"), content, r.ContentLength)
+		case contentType == ContentTypeForm:
+			r.ParseForm()
+			fmt.Fprintf(w, "%s|%s", r.PostForm.Get("q"), r.PostForm.Get("page"))
+		default:
+			body, _ := io.ReadAll(r.Body)
+			fmt.Fprintf(w, "%s|%s", contentType, body)
+		}
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := os.WriteFile(path, []byte(`{"total": 3}`), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	t.Run("multipart", func(t *testing.T) {
+		body, cleanup, err := NewMultipartBody([]FormField{{Name: "title", Value: "Q3"}, {Name: "upload", Path: path}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		defer cleanup()
+		contentLength := body.ContentLength
+		resp, err := client.DoRequest(Request{Method: "POST", URL: ts.URL, Body: body.Reader, ContentType: body.ContentType, ContentLength: body.ContentLength})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		want := fmt.Sprintf(`Q3|report.json|application/json|{"total": 3}|%d`, contentLength)
+		if resp.Body != want || resp.SentBytes != contentLength {
+			t.Errorf("got %q with %d bytes sent, want %q", resp.Body, resp.SentBytes, want)
+		}
+	})
+
+	t.Run("new boundary per body", func(t *testing.T) {
+		first, _, _ := NewMultipartBody([]FormField{{Name: "a", Value: "1"}})
+		second, _, _ := NewMultipartBody([]FormField{{Name: "a", Value: "1"}})
+		if first.ContentType == second.ContentType {
+			t.Errorf("expected a new boundary, got %q twice", first.ContentType)
+		}
+	})
+
+	t.Run("url-encoded form", func(t *testing.T) {
+		body := NewFormBody([]FormField{{Name: "q", Value: "a&b c"}, {Name: "page", Value: "2"}})
+		resp, err := client.DoRequest(Request{Method: "POST", URL: ts.URL, Body: body.Reader, ContentType: body.ContentType})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "a&b c|2" {
+			t.Errorf("got %q, want %q", resp.Body, "a&b c|2")
+		}
+	})
+
+	t.Run("content type", func(t *testing.T) {
+		for contentType, want := range map[string]string{"": "application/json|<x/>", "application/xml": "application/xml|<x/>"} {
+			resp, err := client.DoRequest(Request{Method: "POST", URL: ts.URL, Body: strings.NewReader("<x/>"), ContentType: contentType})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != want {
+				t.Errorf("got %q, want %q", resp.Body, want)
+			}
+		}
+	})
+}
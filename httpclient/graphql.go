@@ -0,0 +1,184 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// graphQLRequest is the envelope GraphQL servers expect in a POST body
+type graphQLRequest struct {
+	Query         string          `json:"query"`
+	OperationName string          `json:"operationName,omitempty"`
+	Variables     json.RawMessage `json:"variables,omitempty"`
+}
+
+// graphQLResponse is the part of a GraphQL response the client looks at
+type graphQLResponse struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+// NewGraphQLBody returns the query, the name of the operation to run and its
+// variables, a JSON object or empty, in a GraphQL request envelope.
+func NewGraphQLBody(query, operationName, variables string) (Body, error) {
+	envelope := graphQLRequest{Query: query, OperationName: operationName}
+	if variables != "" {
+		envelope.Variables = json.RawMessage(variables)
+	}
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		return Body{}, fmt.Errorf("error encoding the GraphQL request: %v", err)
+	}
+	return Body{Reader: bytes.NewReader(data), ContentType: ContentTypeJSON}, nil
+}
+
+// graphQLErrors counts the errors in the errors array of a GraphQL response
+// body and returns the message of the first one. A successful response that
+// is not a GraphQL response counts as one error, as does one cut off at the
+// max body size, since its errors cannot be told.
+func graphQLErrors(body []byte, statusCode int, oversized bool) (int, string) {
+	success := statusCode >= 200 && statusCode < 300
+	var response graphQLResponse
+	if oversized || json.Unmarshal(body, &response) != nil || (response.Data == nil && response.Errors == nil) {
+		if !success {
+			return 0, ""
+		}
+		if oversized {
+			return 1, "the response was cut off at the max body size"
+		}
+		return 1, "the response is not a GraphQL response"
+	}
+	if len(response.Errors) == 0 {
+		return 0, ""
+	}
+	return len(response.Errors), response.Errors[0].Message
+}
+
+// GraphQLOperations returns the names of the operations of a GraphQL document
+// in the order they are defined. A document with a single anonymous operation
+// returns one empty name. Fragments are skipped, and the document is only read
+// as far as needed to find the operations, the server validates the rest.
+func GraphQLOperations(document string) ([]string, error) {
+	var names []string
+	depth, parens := 0, 0
+	header := false    // Between the start of a definition and its selection set
+	nameNext := false  // The next token at the start of an operation is its name
+	operation := false // The definition being read is an operation
+
+	for i := 0; i < len(document); i++ {
+		c := document[i]
+		switch {
+		case c == '#':
+			for i < len(document) && document[i] != '\n' {
+				i++
+			}
+		case c == '"':
+			end, ok := skipGraphQLString(document, i)
+			if !ok {
+				return nil, fmt.Errorf("the GraphQL document has an unterminated string")
+			}
+			i, nameNext = end, false
+		case c == '(' && depth == 0:
+			parens++
+			nameNext = false
+		case c == ')' && depth == 0:
+			parens--
+		case c == '{' && parens == 0:
+			if depth == 0 && !header {
+				// A selection set on its own is an anonymous query
+				operation = true
+				names = append(names, "")
+			}
+			depth++
+			header, nameNext = false, false
+		case c == '}' && parens == 0:
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("the GraphQL document has unbalanced braces")
+			}
+		case isNameStart(c):
+			start := i
+			for i+1 < len(document) && isNameChar(document[i+1]) {
+				i++
+			}
+			word := document[start : i+1]
+			if depth > 0 || parens > 0 {
+				continue
+			}
+			switch {
+			case nameNext && operation:
+				names[len(names)-1] = word
+				nameNext = false
+			case !header:
+				header = true
+				operation = word == "query" || word == "mutation" || word == "subscription"
+				nameNext = operation
+				if operation {
+					names = append(names, "")
+				}
+			}
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+		default:
+			nameNext = false
+		}
+	}
+	if depth != 0 || parens != 0 {
+		return nil, fmt.Errorf("the GraphQL document has unbalanced braces")
+	}
+
+	if len(names) == 0 {
+		return nil, fmt.Errorf("the GraphQL document has no operation")
+	}
+	seen := map[string]bool{}
+	for _, name := range names {
+		if name == "" && len(names) > 1 {
+			return nil, fmt.Errorf("an anonymous operation must be the only operation of the GraphQL document")
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("the operation '%s' is defined twice", name)
+		}
+		seen[name] = true
+	}
+	return names, nil
+}
+
+// skipGraphQLString returns the index of the closing quote of the string or
+// block string starting at i
+func skipGraphQLString(document string, i int) (int, bool) {
+	if strings.HasPrefix(document[i:], `"""`) {
+		end := strings.Index(document[i+3:], `"""`)
+		for end >= 0 && document[i+3+end-1] == '\\' {
+			// An escaped triple quote does not end the block string
+			next := strings.Index(document[i+3+end+1:], `"""`)
+			if next < 0 {
+				return 0, false
+			}
+			end += 1 + next
+		}
+		if end < 0 {
+			return 0, false
+		}
+		return i + 3 + end + 2, true
+	}
+	for i++; i < len(document) && document[i] != '\n'; i++ {
+		switch document[i] {
+		case '\\':
+			i++
+		case '"':
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
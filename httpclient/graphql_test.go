@@ -0,0 +1,147 @@
+package httpclient
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestClientGraphQLErrors(t *testing.T) {
+	// The server answers with the body named by the operation of the request
+	bodies := map[string]struct {
+		status int
+		body   string
+	}{
+		"ok":          {http.StatusOK, `{"data": {"user": {"id": "1"}}}`},
+		"partial":     {http.StatusOK, `{"data": {"user": null}, "errors": [{"message": "user not found"}, {"message": "forbidden"}]}`},
+		"invalid":     {http.StatusBadRequest, `{"errors": [{"message": "Cannot query field \"nme\""}]}`},
+		"html":        {http.StatusOK, `<html>maintenance</html>`},
+		"unavailable": {http.StatusServiceUnavailable, `<html>maintenance</html>`},
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var envelope graphQLRequest
+		if err := json.NewDecoder(r.Body).Decode(&envelope); err != nil || envelope.Query == "" {
+			t.Errorf("expected a GraphQL request envelope, got error %v", err)
+		}
+		response := bodies[envelope.OperationName]
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		operation  string
+		options    Options
+		wantErrors int
+		wantError  string
+		wantBody   bool
+	}{
+		{operation: "ok", wantBody: true},
+		{operation: "partial", wantErrors: 2, wantError: "user not found", wantBody: true},
+		{operation: "invalid", wantErrors: 1, wantError: `Cannot query field "nme"`, wantBody: true},
+		{operation: "html", wantErrors: 1, wantError: "the response is not a GraphQL response", wantBody: true},
+		{operation: "unavailable", wantBody: true},
+		{operation: "ok", options: Options{BodyMode: BodyFailed}},
+		{operation: "partial", options: Options{BodyMode: BodyFailed}, wantErrors: 2, wantError: "user not found", wantBody: true},
+		{operation: "partial", options: Options{BodyMode: BodyDiscard}, wantErrors: 2, wantError: "user not found"},
+		{operation: "ok", options: Options{MaxBodySize: 10}, wantErrors: 1, wantError: "the response was cut off at the max body size", wantBody: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.operation, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, err := NewGraphQLBody("query "+tt.operation+" { user { id } }", tt.operation, `{"id": 1}`)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.DoRequest(Request{Method: "POST", URL: ts.URL, Body: body.Reader, ContentType: body.ContentType, GraphQL: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.GraphQLErrors != tt.wantErrors || resp.GraphQLError != tt.wantError {
+				t.Errorf("got %d errors, first %q, want %d, %q", resp.GraphQLErrors, resp.GraphQLError, tt.wantErrors, tt.wantError)
+			}
+			if (resp.Body != "") != tt.wantBody {
+				t.Errorf("got body %q, want it kept %v", resp.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestNewGraphQLBody(t *testing.T) {
+	tests := []struct {
+		name      string
+		operation string
+		variables string
+		want      string
+		wantErr   bool
+	}{
+		{name: "query only", want: `{"query":"{ me { id } }"}`},
+		{name: "operation and variables", operation: "Me", variables: `{"id": 1}`, want: `{"query":"{ me { id } }","operationName":"Me","variables":{"id":1}}`},
+		{name: "invalid variables", variables: `{"id": }`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := NewGraphQLBody("{ me { id } }", tt.operation, tt.variables)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewGraphQLBody() gotErr = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got, err := io.ReadAll(body.Reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("NewGraphQLBody() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLOperations(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		want     []string
+		errMsg   string
+	}{
+		{name: "shorthand query", document: `{ me { id } }`, want: []string{""}},
+		{name: "anonymous query", document: `query { me { id } }`, want: []string{""}},
+		{
+			name: "named operations and fragments",
+			document: `# Users and orders
+				query GetUser($id: ID!, $filter: Filter = {status: "open"}) { user(id: $id) { ...UserFields } }
+				fragment UserFields on User { id name }
+				mutation CreateOrder($input: OrderInput!) @audit { createOrder(input: $input) { id note(text: "a } in a string") } }
+				subscription OnOrder { order { id } }`,
+			want: []string{"GetUser", "CreateOrder", "OnOrder"},
+		},
+		{name: "block string", document: `query Search { search(text: """a "quoted" { brace""") { id } }`, want: []string{"Search"}},
+		{name: "fragments only", document: `fragment UserFields on User { id }`, errMsg: "the GraphQL document has no operation"},
+		{name: "anonymous among named", document: `query GetUser { me { id } } { me { name } }`, errMsg: "an anonymous operation must be the only operation of the GraphQL document"},
+		{name: "duplicate operation", document: `query GetUser { me { id } } query GetUser { me { name } }`, errMsg: "the operation 'GetUser' is defined twice"},
+		{name: "unbalanced braces", document: `query GetUser { me { id }`, errMsg: "the GraphQL document has unbalanced braces"},
+		{name: "unterminated string", document: `query GetUser { user(name: "me) { id } }`, errMsg: "the GraphQL document has an unterminated string"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GraphQLOperations(tt.document)
+			if (err != nil) != (tt.errMsg != "") || (err != nil && err.Error() != tt.errMsg) {
+				t.Fatalf("GraphQLOperations() gotErr = %v, wantErr %v", err, tt.errMsg)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GraphQLOperations() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
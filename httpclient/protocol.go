@@ -0,0 +1,186 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/net/http2"
+)
+
+// Supported values of Options.Protocol
+const (
+	ProtocolAuto  = "auto"  // HTTP/2 when the server offers it over TLS, HTTP/1.1 otherwise
+	ProtocolHTTP1 = "http1" // HTTP/1.1 only
+	ProtocolHTTP2 = "http2" // HTTP/2 over TLS only
+	ProtocolH2C   = "h2c"   // HTTP/2 over cleartext TCP with prior knowledge
+)
+
+// ValidateProtocol checks the protocol and its multiplexing settings.
+func ValidateProtocol(options Options) error {
+	switch options.Protocol {
+	case "", ProtocolAuto, ProtocolHTTP1, ProtocolHTTP2, ProtocolH2C:
+	default:
+		return fmt.Errorf("'%s' is not a valid protocol. Supported protocols are: http1, http2, h2c, auto", options.Protocol)
+	}
+	if options.Connections < 0 {
+		return fmt.Errorf("the number of connections cannot be negative")
+	}
+	if options.MaxConcurrentStreams < 0 {
+		return fmt.Errorf("max concurrent streams cannot be negative")
+	}
+	return nil
+}
+
+// newTransport creates the round tripper for the protocol. With a number of
+// connections set, requests are spread round-robin over that many transports
+// that each hold on to a single connection.
+func newTransport(options Options, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), dial dialFunc) (http.RoundTripper, error) {
+	if err := ValidateProtocol(options); err != nil {
+		return nil, err
+	}
+
+	if options.MaxIdleConnsPerHost < 0 {
+		return nil, fmt.Errorf("max idle connections cannot be negative")
+	}
+
+	if options.Connections == 0 {
+		return newProtocolTransport(options, tlsConfig, proxy, dial, false)
+	}
+
+	pool := &connectionPool{}
+	for i := 0; i < options.Connections; i++ {
+		transport, err := newProtocolTransport(options, tlsConfig, proxy, dial, true)
+		if err != nil {
+			return nil, err
+		}
+		pooled := pooledTransport{transport: transport}
+		if options.MaxConcurrentStreams > 0 {
+			pooled.streams = make(chan struct{}, options.MaxConcurrentStreams)
+		}
+		pool.transports = append(pool.transports, pooled)
+	}
+	return pool, nil
+}
+
+// newBaseTransport returns a transport with the same settings as http.DefaultTransport
+// apart from the proxy, the dialer and the number of idle connections kept
+func newBaseTransport(options Options, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), dial dialFunc) *http.Transport {
+	maxIdleConns := 100
+	if options.MaxIdleConnsPerHost > maxIdleConns {
+		maxIdleConns = options.MaxIdleConnsPerHost
+	}
+
+	return &http.Transport{
+		Proxy:                 proxy,
+		DialContext:           dial,
+		TLSClientConfig:       tlsConfig,
+		MaxIdleConns:          maxIdleConns,
+		MaxIdleConnsPerHost:   options.MaxIdleConnsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+	}
+}
+
+// newProtocolTransport creates a single transport for the protocol. A single
+// connection transport never opens more than one connection to the server.
+func newProtocolTransport(options Options, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), dial dialFunc, singleConnection bool) (http.RoundTripper, error) {
+	tlsConfig = tlsConfig.Clone()
+
+	switch options.Protocol {
+	case ProtocolH2C:
+		return &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return dial(ctx, network, addr)
+			},
+			StrictMaxConcurrentStreams: singleConnection,
+		}, nil
+
+	case ProtocolHTTP1:
+		transport := newBaseTransport(options, tlsConfig, proxy, dial)
+		tlsConfig.NextProtos = []string{"http/1.1"}
+		// A non-nil empty map disables the HTTP/2 upgrade
+		transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		if singleConnection {
+			transport.MaxConnsPerHost = 1
+		}
+		return transport, nil
+
+	default:
+		transport := newBaseTransport(options, tlsConfig, proxy, dial)
+		if singleConnection {
+			transport.MaxConnsPerHost = 1
+		}
+		http2Transport, err := http2.ConfigureTransports(transport)
+		if err != nil {
+			return nil, fmt.Errorf("error configuring HTTP/2: %v", err)
+		}
+		http2Transport.StrictMaxConcurrentStreams = singleConnection
+		if options.Protocol == ProtocolHTTP2 {
+			// Only offer HTTP/2 during ALPN, ConfigureTransports adds http/1.1 as a fallback
+			tlsConfig.NextProtos = []string{"h2"}
+		}
+		return transport, nil
+	}
+}
+
+// pooledTransport is one connection of a connection pool. The streams
+// channel caps the requests multiplexed over the connection at once.
+type pooledTransport struct {
+	transport http.RoundTripper
+	streams   chan struct{}
+}
+
+// connectionPool spreads requests round-robin over its transports
+type connectionPool struct {
+	transports []pooledTransport
+	next       uint64
+}
+
+func (p *connectionPool) RoundTrip(req *http.Request) (*http.Response, error) {
+	index := atomic.AddUint64(&p.next, 1) % uint64(len(p.transports))
+	pooled := p.transports[index]
+
+	if pooled.streams == nil {
+		return pooled.transport.RoundTrip(req)
+	}
+
+	select {
+	case pooled.streams <- struct{}{}:
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	}
+	release := func() { <-pooled.streams }
+
+	resp, err := pooled.transport.RoundTrip(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	// The stream stays open until the body has been read and closed
+	resp.Body = &releasingBody{ReadCloser: resp.Body, release: release}
+	return resp, nil
+}
+
+// releasingBody releases a stream slot when the response body is closed
+type releasingBody struct {
+	io.ReadCloser
+	release  func()
+	released int32
+}
+
+func (b *releasingBody) Close() error {
+	err := b.ReadCloser.Close()
+	if atomic.CompareAndSwapInt32(&b.released, 0, 1) {
+		b.release()
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// protoHandler responds with the protocol of the request
+var protoHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.Write([]byte(r.Proto))
+})
+
+func newHTTP2Server() *httptest.Server {
+	ts := httptest.NewUnstartedServer(protoHandler)
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	return ts
+}
+
+func TestClientProtocol(t *testing.T) {
+	http2Server := newHTTP2Server()
+	defer http2Server.Close()
+
+	http1Server := httptest.NewTLSServer(protoHandler)
+	defer http1Server.Close()
+
+	h2cServer := httptest.NewServer(h2c.NewHandler(protoHandler, &http2.Server{}))
+	defer h2cServer.Close()
+
+	tests := []struct {
+		name     string
+		protocol string
+		url      string
+		want     string
+		wantErr  bool
+	}{
+		{name: "auto negotiates HTTP/2", protocol: ProtocolAuto, url: http2Server.URL, want: "HTTP/2.0"},
+		{name: "auto falls back to HTTP/1.1", protocol: ProtocolAuto, url: http1Server.URL, want: "HTTP/1.1"},
+		{name: "http1 on an HTTP/2 server", protocol: ProtocolHTTP1, url: http2Server.URL, want: "HTTP/1.1"},
+		{name: "http2", protocol: ProtocolHTTP2, url: http2Server.URL, want: "HTTP/2.0"},
+		{name: "http2 on an HTTP/1.1 server", protocol: ProtocolHTTP2, url: http1Server.URL, wantErr: true},
+		{name: "h2c", protocol: ProtocolH2C, url: h2cServer.URL, want: "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(Options{Protocol: tt.protocol, Insecure: true})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			resp, err := client.Do("GET", tt.url, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got protocol %s", resp.Protocol)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Protocol != tt.want || resp.Body != tt.want {
+				t.Errorf("expected %s on both ends, client got %s and server saw %s", tt.want, resp.Protocol, resp.Body)
+			}
+		})
+	}
+}
+
+func TestClientConnections(t *testing.T) {
+	// Record the client address of every request to count the connections
+	var mu sync.Mutex
+	remoteAddrs := map[string]int{}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		remoteAddrs[r.RemoteAddr]++
+		mu.Unlock()
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	client, err := NewClient(Options{Protocol: ProtocolHTTP2, Insecure: true, Connections: 3, MaxConcurrentStreams: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 30; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.Do("GET", ts.URL, nil); err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if len(remoteAddrs) != 3 {
+		t.Errorf("expected requests over 3 connections, got %d", len(remoteAddrs))
+	}
+	for addr, requests := range remoteAddrs {
+		if requests != 10 {
+			t.Errorf("expected 10 requests on %s, got %d", addr, requests)
+		}
+	}
+}
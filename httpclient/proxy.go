@@ -0,0 +1,85 @@
+package httpclient
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+// newProxyFunc returns the proxy selection of the transport, or nil when no proxy is used.
+// An explicit proxy is used for every request. Otherwise the environment
+// variables HTTP_PROXY, HTTPS_PROXY and NO_PROXY are honored like net/http
+// does, unless turned off or the connections cannot go through a proxy.
+func newProxyFunc(options Options) (func(*http.Request) (*url.URL, error), error) {
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		switch proxyURL.Scheme {
+		case "http", "https", "socks5", "socks5h":
+		default:
+			return nil, fmt.Errorf("'%s' is not a supported proxy scheme. Supported schemes are: http, https, socks5, socks5h", proxyURL.Scheme)
+		}
+		if proxyURL.Host == "" {
+			return nil, fmt.Errorf("the proxy URL has no host: %s", options.Proxy)
+		}
+		return http.ProxyURL(proxyURL), nil
+	}
+
+	if options.NoProxy || options.UnixSocket != "" || options.Protocol == ProtocolH2C {
+		return nil, nil
+	}
+	// Read when the client is created rather than once per process as net/http does
+	fromEnvironment := httpproxy.FromEnvironment().ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return fromEnvironment(req.URL)
+	}, nil
+}
+
+// proxyAddresses returns the host:port of every proxy newProxyFunc can pick,
+// so the dialer can tell the connections to a proxy from those to the target
+func proxyAddresses(options Options) map[string]bool {
+	proxies := []string{options.Proxy}
+	if options.Proxy == "" && !options.NoProxy {
+		environment := httpproxy.FromEnvironment()
+		proxies = []string{environment.HTTPProxy, environment.HTTPSProxy}
+	}
+
+	addresses := map[string]bool{}
+	for _, proxy := range proxies {
+		if proxy == "" {
+			continue
+		}
+		// Like net/http, a proxy without a scheme is an HTTP proxy
+		if !strings.Contains(proxy, "://") {
+			proxy = "http://" + proxy
+		}
+		proxyURL, err := url.Parse(proxy)
+		if err != nil || proxyURL.Hostname() == "" {
+			continue
+		}
+		port := proxyURL.Port()
+		if port == "" {
+			port = map[string]string{"http": "80", "https": "443", "socks5": "1080", "socks5h": "1080"}[proxyURL.Scheme]
+		}
+		addresses[net.JoinHostPort(strings.ToLower(proxyURL.Hostname()), port)] = true
+	}
+	return addresses
+}
+
+// ValidateProxy checks the proxy settings.
+func ValidateProxy(options Options) error {
+	if options.Proxy != "" && options.NoProxy {
+		return fmt.Errorf("a proxy URL cannot be combined with no proxy")
+	}
+	if options.Proxy != "" && options.Protocol == ProtocolH2C {
+		return fmt.Errorf("proxies are not supported with h2c")
+	}
+	_, err := newProxyFunc(options)
+	return err
+}
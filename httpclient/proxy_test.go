@@ -0,0 +1,234 @@
+package httpclient
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newForwardProxy starts an HTTP proxy that requires the given credentials.
+// It forwards plain requests and tunnels CONNECT requests.
+func newForwardProxy(t *testing.T, user, password string) (*httptest.Server, *int) {
+	requests := 0
+	wantAuth := "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+password))
+
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Proxy-Authorization") != wantAuth {
+			w.WriteHeader(http.StatusProxyAuthRequired)
+			return
+		}
+		requests++
+
+		if r.Method == http.MethodConnect {
+			target, err := net.Dial("tcp", r.Host)
+			if err != nil {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				target.Close()
+				return
+			}
+			go io.Copy(target, conn)
+			go io.Copy(conn, target)
+			return
+		}
+
+		r.RequestURI = ""
+		r.Header.Del("Proxy-Authorization")
+		resp, err := http.DefaultTransport.RoundTrip(r)
+		if err != nil {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	return proxy, &requests
+}
+
+// newSOCKS5Proxy starts a minimal SOCKS5 proxy without authentication that
+// only supports CONNECT to IPv4 addresses
+func newSOCKS5Proxy(t *testing.T) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				// Greeting: version, number of methods, methods
+				header := make([]byte, 2)
+				if _, err := io.ReadFull(conn, header); err != nil {
+					return
+				}
+				io.ReadFull(conn, make([]byte, header[1]))
+				conn.Write([]byte{5, 0})
+
+				// Request: version, command, reserved, address type, IPv4, port
+				request := make([]byte, 10)
+				if _, err := io.ReadFull(conn, request); err != nil || request[3] != 1 {
+					return
+				}
+				addr := net.JoinHostPort(net.IP(request[4:8]).String(), strconv.Itoa(int(binary.BigEndian.Uint16(request[8:10]))))
+				target, err := net.Dial("tcp", addr)
+				if err != nil {
+					conn.Write([]byte{5, 1, 0, 1, 0, 0, 0, 0, 0, 0})
+					return
+				}
+				defer target.Close()
+				conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0})
+
+				go io.Copy(target, conn)
+				io.Copy(conn, target)
+			}(conn)
+		}
+	}()
+	return listener
+}
+
+func TestClientProxy(t *testing.T) {
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("target"))
+	}))
+	defer target.Close()
+
+	tlsTarget := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("tls target"))
+	}))
+	defer tlsTarget.Close()
+
+	proxy, proxied := newForwardProxy(t, "bench", "secret")
+	defer proxy.Close()
+	proxyURL := strings.Replace(proxy.URL, "http://", "http://bench:secret@", 1)
+
+	t.Run("http proxy", func(t *testing.T) {
+		client, err := NewClient(Options{Proxy: proxyURL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", target.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "target" || *proxied != 1 {
+			t.Errorf("expected the request to go through the proxy, got %q after %d proxied requests", resp.Body, *proxied)
+		}
+	})
+
+	t.Run("wrong credentials", func(t *testing.T) {
+		client, _ := NewClient(Options{Proxy: proxy.URL})
+		resp, err := client.Do("GET", target.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.StatusCode != http.StatusProxyAuthRequired {
+			t.Errorf("expected status %d, got %d", http.StatusProxyAuthRequired, resp.StatusCode)
+		}
+	})
+
+	t.Run("CONNECT tunnel", func(t *testing.T) {
+		client, err := NewClient(Options{Proxy: proxyURL, Insecure: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", tlsTarget.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "tls target" {
+			t.Errorf("expected the TLS target response, got %q", resp.Body)
+		}
+		if resp.Phases.Proxy <= 0 || resp.Phases.TLS <= 0 {
+			t.Errorf("expected proxy and TLS phases to be recorded, got %+v", resp.Phases)
+		}
+	})
+
+	t.Run("socks5 proxy", func(t *testing.T) {
+		socks := newSOCKS5Proxy(t)
+		defer socks.Close()
+
+		client, err := NewClient(Options{Proxy: "socks5://" + socks.Addr().String(), Insecure: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", tlsTarget.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "tls target" {
+			t.Errorf("expected the TLS target response, got %q", resp.Body)
+		}
+		if resp.Phases.Proxy <= 0 {
+			t.Errorf("expected the SOCKS5 handshake to be recorded, got %+v", resp.Phases)
+		}
+		if resp.ConnectionID != 1 {
+			t.Errorf("expected the connection through the proxy to be numbered 1, got %d", resp.ConnectionID)
+		}
+	})
+
+	// Go does not proxy loopback hosts from the environment, so the target goes by a name
+	_, port, _ := net.SplitHostPort(target.Listener.Addr().String())
+	namedTarget := "http://target.invalid:" + port + "/"
+
+	t.Run("proxy from the environment by default", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", proxyURL)
+		before := *proxied
+		client, err := NewClient(Options{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := client.Do("GET", namedTarget, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if *proxied != before+1 {
+			t.Error("expected the request to go through the proxy of HTTP_PROXY")
+		}
+	})
+
+	t.Run("connect-to leaves the proxy alone", func(t *testing.T) {
+		before := *proxied
+		client, err := NewClient(Options{Proxy: proxyURL, ConnectTo: []string{"::127.0.0.1:" + port}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", target.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "target" || *proxied != before+1 {
+			t.Errorf("expected the request to go through the proxy, got %q after %d proxied requests", resp.Body, *proxied-before)
+		}
+	})
+
+	t.Run("no proxy ignores the environment", func(t *testing.T) {
+		t.Setenv("HTTP_PROXY", proxyURL)
+		before := *proxied
+		client, err := NewClient(Options{NoProxy: true, Resolve: []string{"target.invalid:" + port + ":127.0.0.1"}})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", namedTarget, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "target" || *proxied != before {
+			t.Errorf("expected HTTP_PROXY to be ignored, got %q after %d proxied requests", resp.Body, *proxied-before)
+		}
+	})
+}
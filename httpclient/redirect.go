@@ -0,0 +1,73 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// RedirectHop is a response in a redirect chain that pointed elsewhere
+type RedirectHop struct {
+	URL          string
+	StatusCode   int
+	StartTime    time.Time
+	ResponseTime time.Duration // Until the response headers of the hop arrived
+}
+
+// ValidateRedirects checks the redirect settings.
+func ValidateRedirects(options Options) error {
+	if options.MaxRedirects < 0 {
+		return fmt.Errorf("max redirects cannot be negative")
+	}
+	return nil
+}
+
+// redirectRecorder collects the hops of a single request. The redirects of
+// a request are followed one after another, so it needs no locking.
+type redirectRecorder struct {
+	hopStart time.Time
+	hops     []RedirectHop
+	returned bool // A redirect was returned rather than followed
+}
+
+type redirectRecorderKey struct{}
+
+func withRedirectRecorder(ctx context.Context, recorder *redirectRecorder) context.Context {
+	return context.WithValue(ctx, redirectRecorderKey{}, recorder)
+}
+
+// checkRedirect returns the redirect policy of the client. Redirects are
+// either not followed at all, so the 3xx response is returned, or followed up
+// to the maximum with every hop recorded. A request redirected more often
+// fails, with a maximum of 0 on its first redirect.
+func checkRedirect(options Options) func(req *http.Request, via []*http.Request) error {
+	maxRedirects := options.MaxRedirects
+
+	return func(req *http.Request, via []*http.Request) error {
+		recorder, ok := req.Context().Value(redirectRecorderKey{}).(*redirectRecorder)
+		if options.NoRedirects {
+			if ok {
+				recorder.returned = true
+			}
+			return http.ErrUseLastResponse
+		}
+
+		if len(via) > maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		if ok {
+			now := time.Now()
+			previous := via[len(via)-1]
+			hop := RedirectHop{URL: previous.URL.String(), StartTime: recorder.hopStart, ResponseTime: now.Sub(recorder.hopStart)}
+			if req.Response != nil {
+				hop.StatusCode = req.Response.StatusCode
+			}
+			recorder.hops = append(recorder.hops, hop)
+			recorder.hopStart = now
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,71 @@
+package httpclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestClientRedirects(t *testing.T) {
+	// /hops/N redirects N times before answering
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/hops/"))
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/hops/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name         string
+		options      Options
+		path         string
+		wantStatus   int
+		wantHops     int
+		wantReturned bool
+		errMsg       string
+	}{
+		{name: "no redirect", options: Options{}, path: "/hops/0", wantStatus: http.StatusOK},
+		{name: "followed", options: Options{MaxRedirects: 10}, path: "/hops/3", wantStatus: http.StatusOK, wantHops: 3},
+		{name: "at the limit", options: Options{MaxRedirects: 3}, path: "/hops/3", wantStatus: http.StatusOK, wantHops: 3},
+		{name: "over the limit", options: Options{MaxRedirects: 2}, path: "/hops/3", wantHops: 2, errMsg: "stopped after 2 redirects"},
+		{name: "none allowed", options: Options{}, path: "/hops/1", errMsg: "stopped after 0 redirects"},
+		{name: "not followed", options: Options{NoRedirects: true}, path: "/hops/3", wantStatus: http.StatusFound, wantReturned: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			resp, err := client.Do("GET", ts.URL+tt.path, nil)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Errorf("Do() error = %v, want %q", err, tt.errMsg)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus || len(resp.Hops) != tt.wantHops || resp.RedirectReturned != tt.wantReturned {
+				t.Errorf("got status %d, %d hops, returned %v, want %d, %d, %v", resp.StatusCode, len(resp.Hops), resp.RedirectReturned, tt.wantStatus, tt.wantHops, tt.wantReturned)
+			}
+			for i, hop := range resp.Hops {
+				if hop.StatusCode != http.StatusFound || hop.URL != fmt.Sprintf("%s/hops/%d", ts.URL, 3-i) {
+					t.Errorf("unexpected hop %d: %+v", i, hop)
+				}
+			}
+			if tt.wantHops > 0 && tt.errMsg == "" {
+				last := resp.Hops[len(resp.Hops)-1]
+				if !resp.FinalHopStart.Equal(last.StartTime.Add(last.ResponseTime)) {
+					t.Errorf("expected the final hop to start when the last redirect arrived")
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,307 @@
+package httpclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Supported values of Options.Signing
+const (
+	SigningHMAC  = "hmac"
+	SigningAWSV4 = "aws-sigv4"
+)
+
+// Defaults of the HMAC signer
+const (
+	DefaultHMACCanonical       = `{method}\n{path}\n{timestamp}\n{body_sha256}`
+	DefaultHMACSignatureHeader = "X-Signature"
+	DefaultHMACTimestampHeader = "X-Timestamp"
+)
+
+// hmacPlaceholder matches the placeholders of the HMAC canonical string:
+// {method}, {path}, {query}, {host}, {timestamp}, {body_sha256} and {header:Name}
+var hmacPlaceholder = regexp.MustCompile(`\{([a-z_0-9]+)(?::([^}]+))?\}`)
+
+// ValidateSigning checks the request signing settings.
+func ValidateSigning(options Options) error {
+	switch options.Signing {
+	case "":
+		return nil
+	case SigningHMAC:
+		if options.HMACSecret == "" {
+			return fmt.Errorf("HMAC signing requires a secret")
+		}
+		for _, match := range hmacPlaceholder.FindAllStringSubmatch(options.HMACCanonical, -1) {
+			switch match[1] {
+			case "method", "path", "query", "host", "timestamp", "body_sha256":
+			case "header":
+				if match[2] == "" {
+					return fmt.Errorf("the {header:Name} placeholder needs a header name")
+				}
+			default:
+				return fmt.Errorf("'%s' is not a valid placeholder. Supported placeholders are: {method}, {path}, {query}, {host}, {timestamp}, {body_sha256}, {header:Name}", match[0])
+			}
+		}
+	case SigningAWSV4:
+		if options.AWSRegion == "" || options.AWSService == "" {
+			return fmt.Errorf("AWS signing requires a region and a service")
+		}
+		if id, secret, _ := awsCredentials(options); id == "" || secret == "" {
+			return fmt.Errorf("AWS signing requires an access key, set it or AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+		}
+		if options.BasicAuth != "" || options.BearerToken != "" || options.OAuth2TokenURL != "" {
+			return fmt.Errorf("AWS signing sets the Authorization header and cannot be combined with basic auth, a bearer token or OAuth2")
+		}
+	default:
+		return fmt.Errorf("'%s' is not a valid signing method. Supported methods are: hmac, aws-sigv4", options.Signing)
+	}
+	return nil
+}
+
+// signer signs a request whose headers are final. The body is the request
+// body as it will be sent.
+type signer interface {
+	sign(req *http.Request, body []byte) error
+}
+
+// newSigner returns the signer of the options, or nil if requests are not signed
+func newSigner(options Options) signer {
+	switch options.Signing {
+	case SigningHMAC:
+		canonical := options.HMACCanonical
+		if canonical == "" {
+			canonical = DefaultHMACCanonical
+		}
+		hmacSigner := &hmacSigner{
+			secret:          []byte(options.HMACSecret),
+			canonical:       strings.ReplaceAll(canonical, `\n`, "\n"),
+			signatureHeader: options.HMACSignatureHeader,
+			timestampHeader: options.HMACTimestampHeader,
+			now:             time.Now,
+		}
+		if hmacSigner.signatureHeader == "" {
+			hmacSigner.signatureHeader = DefaultHMACSignatureHeader
+		}
+		if hmacSigner.timestampHeader == "" {
+			hmacSigner.timestampHeader = DefaultHMACTimestampHeader
+		}
+		return hmacSigner
+	case SigningAWSV4:
+		id, secret, token := awsCredentials(options)
+		return &awsSigner{
+			accessKeyID:     id,
+			secretAccessKey: secret,
+			sessionToken:    token,
+			region:          options.AWSRegion,
+			service:         options.AWSService,
+			now:             time.Now,
+		}
+	}
+	return nil
+}
+
+// hmacSigner signs the canonical string with HMAC-SHA256 and sends the hex
+// signature and the Unix timestamp it covers in headers
+type hmacSigner struct {
+	secret          []byte
+	canonical       string // Template with the escapes resolved
+	signatureHeader string
+	timestampHeader string
+	now             func() time.Time
+}
+
+func (s *hmacSigner) sign(req *http.Request, body []byte) error {
+	timestamp := strconv.FormatInt(s.now().Unix(), 10)
+	req.Header.Set(s.timestampHeader, timestamp)
+
+	bodyHash := sha256.Sum256(body)
+	canonical := hmacPlaceholder.ReplaceAllStringFunc(s.canonical, func(placeholder string) string {
+		match := hmacPlaceholder.FindStringSubmatch(placeholder)
+		switch match[1] {
+		case "method":
+			return req.Method
+		case "path":
+			return req.URL.EscapedPath()
+		case "query":
+			return req.URL.RawQuery
+		case "host":
+			return requestHost(req)
+		case "timestamp":
+			return timestamp
+		case "body_sha256":
+			return hex.EncodeToString(bodyHash[:])
+		case "header":
+			return req.Header.Get(match[2])
+		}
+		return placeholder
+	})
+
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(canonical))
+	req.Header.Set(s.signatureHeader, hex.EncodeToString(mac.Sum(nil)))
+	return nil
+}
+
+// awsSigner signs requests with AWS Signature Version 4
+type awsSigner struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	region          string
+	service         string
+	now             func() time.Time
+}
+
+// awsCredentials returns the AWS credentials of the options, falling back to the environment
+func awsCredentials(options Options) (string, string, string) {
+	if options.AWSAccessKeyID != "" {
+		return options.AWSAccessKeyID, options.AWSSecretAccessKey, options.AWSSessionToken
+	}
+	return os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY"), os.Getenv("AWS_SESSION_TOKEN")
+}
+
+func (s *awsSigner) sign(req *http.Request, body []byte) error {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	payload := hex.EncodeToString(payloadHash[:])
+	req.Header.Set("X-Amz-Date", amzDate)
+	if s.service == "s3" {
+		req.Header.Set("X-Amz-Content-Sha256", payload)
+	}
+	if s.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.sessionToken)
+	}
+
+	signedHeaders, canonicalHeaders := awsCanonicalHeaders(req)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsCanonicalPath(req.URL, s.service != "s3"),
+		awsCanonicalQuery(req.URL),
+		canonicalHeaders,
+		signedHeaders,
+		payload,
+	}, "\n")
+
+	scope := date + "/" + s.region + "/" + s.service + "/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretAccessKey), date)
+	for _, part := range []string{s.region, s.service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", s.accessKeyID, scope, signedHeaders, signature))
+	return nil
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// requestHost returns the host the request is sent with
+func requestHost(req *http.Request) string {
+	if req.Host != "" {
+		return req.Host
+	}
+	return req.URL.Host
+}
+
+// awsCanonicalHeaders returns the signed header names and the canonical
+// headers. The host, the content type and the x-amz-* headers are signed.
+func awsCanonicalHeaders(req *http.Request) (string, string) {
+	values := map[string]string{"host": requestHost(req)}
+	for name, headerValues := range req.Header {
+		name = strings.ToLower(name)
+		if name != "content-type" && !strings.HasPrefix(name, "x-amz-") {
+			continue
+		}
+		trimmed := make([]string, len(headerValues))
+		for i, value := range headerValues {
+			trimmed[i] = strings.Join(strings.Fields(value), " ")
+		}
+		values[name] = strings.Join(trimmed, ",")
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, name := range names {
+		canonical.WriteString(name + ":" + values[name] + "\n")
+	}
+	return strings.Join(names, ";"), canonical.String()
+}
+
+// awsCanonicalPath returns the URI-encoded path. Every service except S3
+// encodes the already encoded path segments a second time.
+func awsCanonicalPath(u *url.URL, doubleEncode bool) string {
+	path := u.EscapedPath()
+	if path == "" {
+		return "/"
+	}
+	if !doubleEncode {
+		return path
+	}
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = awsURIEncode(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// awsCanonicalQuery returns the query parameters URI-encoded and sorted by name, then value
+func awsCanonicalQuery(u *url.URL) string {
+	type param struct{ name, value string }
+	var params []param
+	for name, values := range u.Query() {
+		for _, value := range values {
+			params = append(params, param{awsURIEncode(name), awsURIEncode(value)})
+		}
+	}
+	sort.Slice(params, func(i, j int) bool {
+		if params[i].name != params[j].name {
+			return params[i].name < params[j].name
+		}
+		return params[i].value < params[j].value
+	})
+
+	encoded := make([]string, len(params))
+	for i, p := range params {
+		encoded[i] = p.name + "=" + p.value
+	}
+	return strings.Join(encoded, "&")
+}
+
+// awsURIEncode percent-encodes everything but the RFC 3986 unreserved characters
+func awsURIEncode(s string) string {
+	var encoded strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') || c == '-' || c == '_' || c == '.' || c == '~' {
+			encoded.WriteByte(c)
+		} else {
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,156 @@
+package httpclient
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The requests and signatures are from the AWS Signature Version 4 test suite
+func TestAWSSigner(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		url           string
+		header        http.Header
+		body          string
+		wantSigned    string
+		wantSignature string
+	}{
+		{
+			name:          "get vanilla",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/",
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		},
+		{
+			name:          "query order",
+			method:        "GET",
+			url:           "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			wantSigned:    "host;x-amz-date",
+			wantSignature: "b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500",
+		},
+		{
+			name:          "form post",
+			method:        "POST",
+			url:           "https://example.amazonaws.com/",
+			header:        http.Header{"Content-Type": {"application/x-www-form-urlencoded"}},
+			body:          "Param1=value1",
+			wantSigned:    "content-type;host;x-amz-date",
+			wantSignature: "ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a",
+		},
+	}
+
+	signer := &awsSigner{
+		accessKeyID:     "AKIDEXAMPLE",
+		secretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		region:          "us-east-1",
+		service:         "service",
+		now:             func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.url, nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for name, values := range tt.header {
+				req.Header[name] = values
+			}
+			if err := signer.sign(req, []byte(tt.body)); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" + tt.wantSigned + ", Signature=" + tt.wantSignature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %q, want %q", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %q, want 20150830T123600Z", got)
+			}
+		})
+	}
+}
+
+func TestClientHMACSigning(t *testing.T) {
+	secret := "s3cret"
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		bodyHash := sha256.Sum256(body)
+
+		var canonical, signature string
+		if r.Header.Get("X-Custom-Signature") != "" {
+			canonical = strings.Join([]string{r.Method, r.URL.RawQuery, r.Header.Get("X-Custom-Time"), r.Header.Get("X-Request-Id")}, "|")
+			signature = r.Header.Get("X-Custom-Signature")
+		} else {
+			canonical = strings.Join([]string{r.Method, r.URL.Path, r.Header.Get("X-Timestamp"), hex.EncodeToString(bodyHash[:])}, "\n")
+			signature = r.Header.Get("X-Signature")
+		}
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(canonical))
+		if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name       string
+		options    Options
+		method     string
+		path       string
+		body       string
+		header     http.Header
+		wantStatus int
+	}{
+		{name: "default canonical", options: Options{Signing: SigningHMAC, HMACSecret: secret}, method: "POST", path: "/items", body: `{"name": "x"}`, wantStatus: http.StatusOK},
+		{name: "no body", options: Options{Signing: SigningHMAC, HMACSecret: secret}, method: "GET", path: "/items/1", wantStatus: http.StatusOK},
+		{name: "compressed body", options: Options{Signing: SigningHMAC, HMACSecret: secret, RequestEncoding: EncodingGzip}, method: "PUT", path: "/items/1", body: `{"name": "y"}`, wantStatus: http.StatusOK},
+		{
+			name: "custom canonical",
+			options: Options{
+				Signing:             SigningHMAC,
+				HMACSecret:          secret,
+				HMACCanonical:       "{method}|{query}|{timestamp}|{header:X-Request-Id}",
+				HMACSignatureHeader: "X-Custom-Signature",
+				HMACTimestampHeader: "X-Custom-Time",
+			},
+			method:     "GET",
+			path:       "/items?page=2",
+			header:     http.Header{"X-Request-Id": {"abc"}},
+			wantStatus: http.StatusOK,
+		},
+		{name: "wrong secret", options: Options{Signing: SigningHMAC, HMACSecret: "wrong"}, method: "GET", path: "/items", wantStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			resp, err := client.DoRequest(Request{Method: tt.method, URL: ts.URL + tt.path, Header: tt.header, Body: body})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if tt.body != "" && resp.SentBytes == 0 {
+				t.Errorf("expected the signed body to be counted as sent")
+			}
+		})
+	}
+}
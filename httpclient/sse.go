@@ -0,0 +1,203 @@
+package httpclient
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptrace"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ContentTypeEventStream is the content type of server-sent event streams
+const ContentTypeEventStream = "text/event-stream"
+
+// Event is a server-sent event
+type Event struct {
+	ID   string // Last event ID of the stream when the event arrived
+	Type string // "message" unless the event names another type
+	Data string
+}
+
+// EventStream reads the events of an open text/event-stream response. It is
+// not safe for concurrent use.
+type EventStream struct {
+	Response Response // Status, protocol, connection and phases of the stream request
+
+	body        io.ReadCloser
+	received    *countingReader
+	reader      *bufio.Reader
+	lastEventID string
+	retry       time.Duration
+}
+
+// OpenEventStream sends a GET request for a server-sent event stream and
+// returns the stream once the response headers have arrived. Unlike DoRequest
+// there is no timeout, the stream stays open until the context is done or the
+// server closes it. The last event ID is sent so a reconnecting stream resumes
+// where it left off. A stream is returned even when opening it failed, its
+// Response tells how far the request got. A non-2xx response is not an error,
+// but leaves the stream closed.
+func (c *Client) OpenEventStream(ctx context.Context, url string, header http.Header, lastEventID string) (*EventStream, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &EventStream{}, fmt.Errorf("error creating request: %v", err)
+	}
+
+	var tokenWait time.Duration
+	if c.auth != nil {
+		if tokenWait, err = c.auth.authenticate(req); err != nil {
+			return &EventStream{Response: Response{TokenWait: tokenWait}}, err
+		}
+	}
+
+	trace := newTracer(c.usesProxy(req))
+	req = req.WithContext(httptrace.WithClientTrace(ctx, trace.clientTrace()))
+
+	req.Header.Set("Accept", ContentTypeEventStream)
+	req.Header.Set("Cache-Control", "no-cache")
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	setHeaders(req, header)
+	if c.signer != nil {
+		if err := c.signRequest(req); err != nil {
+			return &EventStream{Response: Response{TokenWait: tokenWait}}, fmt.Errorf("error signing request: %v", err)
+		}
+	}
+
+	resp, err := c.streamClient().Do(req)
+	if err != nil {
+		response := trace.snapshot()
+		response.TokenWait = tokenWait
+		return &EventStream{Response: response}, fmt.Errorf("error opening stream: %v", err)
+	}
+	response := trace.snapshot()
+	response.TokenWait = tokenWait
+	response.StatusCode = resp.StatusCode
+	response.Protocol = resp.Proto
+	if resp.TLS != nil {
+		response.TLSVersion = tlsVersionName(resp.TLS.Version)
+		response.CipherSuite = tls.CipherSuiteName(resp.TLS.CipherSuite)
+	}
+
+	// Anything but a 2xx event stream is a failed connection, keep what the server said
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 || mediaType != ContentTypeEventStream {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1<<16))
+		response.Body = string(body)
+		response.BodySize = int64(len(body))
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return &EventStream{Response: response}, fmt.Errorf("the response is not an event stream: %s", resp.Header.Get("Content-Type"))
+		}
+		return &EventStream{Response: response}, nil
+	}
+
+	received := &countingReader{reader: resp.Body}
+	return &EventStream{
+		Response:    response,
+		body:        resp.Body,
+		received:    received,
+		reader:      bufio.NewReader(received),
+		lastEventID: lastEventID,
+	}, nil
+}
+
+// streamClient returns an HTTP client without the request timeout of c
+func (c *Client) streamClient() *http.Client {
+	streamClient := *c.httpClient
+	streamClient.Timeout = 0
+	return &streamClient
+}
+
+// Open reports whether the stream was opened, i.e. the server answered with an event stream
+func (s *EventStream) Open() bool {
+	return s.reader != nil
+}
+
+// Next blocks until the next event arrives. It returns io.EOF when the server
+// closes the stream. Comments, which servers send to keep the connection
+// alive, are skipped. Lines end with \n or \r\n.
+func (s *EventStream) Next() (Event, error) {
+	if s.reader == nil {
+		return Event{}, io.EOF
+	}
+	var data strings.Builder
+	hasData := false
+	eventType := ""
+	for {
+		line, err := s.reader.ReadString('\n')
+		if err != nil {
+			// An event is only dispatched at a blank line, a partial one is dropped
+			return Event{}, err
+		}
+		line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+
+		if line == "" {
+			if !hasData {
+				eventType = ""
+				continue
+			}
+			if eventType == "" {
+				eventType = "message"
+			}
+			return Event{ID: s.lastEventID, Type: eventType, Data: data.String()}, nil
+		}
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "data":
+			if hasData {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+			hasData = true
+		case "event":
+			eventType = value
+		case "id":
+			if !strings.Contains(value, "\x00") {
+				s.lastEventID = value
+			}
+		case "retry":
+			if milliseconds, err := strconv.ParseUint(value, 10, 63); err == nil {
+				s.retry = time.Duration(milliseconds) * time.Millisecond
+			}
+		}
+	}
+}
+
+// LastEventID returns the ID to resume the stream from when reconnecting
+func (s *EventStream) LastEventID() string {
+	return s.lastEventID
+}
+
+// Retry returns the reconnection delay the server asked for, 0 if it did not
+func (s *EventStream) Retry() time.Duration {
+	return s.retry
+}
+
+// ReceivedBytes returns the bytes of the stream read so far
+func (s *EventStream) ReceivedBytes() int64 {
+	if s.received == nil {
+		return s.Response.BodySize
+	}
+	return s.received.bytes()
+}
+
+// Close closes the stream
+func (s *EventStream) Close() error {
+	if s.body == nil {
+		return nil
+	}
+	return s.body.Close()
+}
@@ -0,0 +1,93 @@
+package httpclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestClientEventStream(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/missing":
+			http.Error(w, "no such stream", http.StatusNotFound)
+			return
+		case "/json":
+			w.Header().Set("Content-Type", ContentTypeJSON)
+			w.Write([]byte(`{}`))
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream; charset=utf-8")
+		fmt.Fprintf(w, ": resumed after %s\n\n", r.Header.Get("Last-Event-ID"))
+		fmt.Fprint(w, "retry: 2500\n")
+		fmt.Fprint(w, "id: 7\ndata: first\n\n")
+		fmt.Fprint(w, "event: update\r\ndata:line one\r\ndata: line two\r\n\r\n")
+		fmt.Fprint(w, "event: ignored\n\n")
+		fmt.Fprint(w, "id: 8\ndata\n\n")
+		fmt.Fprint(w, "data: cut off")
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	stream, err := client.OpenEventStream(context.Background(), ts.URL, nil, "6")
+	if err != nil || !stream.Open() {
+		t.Fatalf("OpenEventStream() error = %v, open = %v", err, stream.Open())
+	}
+	defer stream.Close()
+
+	var events []Event
+	for {
+		event, err := stream.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		events = append(events, event)
+	}
+	want := []Event{
+		{ID: "7", Type: "message", Data: "first"},
+		{ID: "7", Type: "update", Data: "line one\nline two"},
+		{ID: "8", Type: "message", Data: ""},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Next() = %+v, want %+v", events, want)
+	}
+	if stream.LastEventID() != "8" || stream.Retry() != 2500*time.Millisecond {
+		t.Errorf("got last event ID %q and retry %s, want 8 and 2.5s", stream.LastEventID(), stream.Retry())
+	}
+	if stream.Response.StatusCode != http.StatusOK || stream.Response.ConnectionID == 0 {
+		t.Errorf("got status %d on connection %d", stream.Response.StatusCode, stream.Response.ConnectionID)
+	}
+
+	t.Run("not found", func(t *testing.T) {
+		stream, err := client.OpenEventStream(context.Background(), ts.URL+"/missing", nil, "")
+		if err != nil || stream.Open() || stream.Response.StatusCode != http.StatusNotFound || stream.Response.Body != "no such stream\n" {
+			t.Errorf("OpenEventStream() = status %d, body %q, open %v, error %v", stream.Response.StatusCode, stream.Response.Body, stream.Open(), err)
+		}
+	})
+
+	t.Run("not an event stream", func(t *testing.T) {
+		stream, err := client.OpenEventStream(context.Background(), ts.URL+"/json", nil, "")
+		if err == nil || stream.Open() {
+			t.Errorf("OpenEventStream() error = %v, open %v, want an error", err, stream.Open())
+		}
+	})
+
+	t.Run("outlives the request timeout", func(t *testing.T) {
+		client, _ := NewClient(Options{Timeout: 30 * time.Second})
+		if client.streamClient().Timeout != 0 || client.httpClient.Timeout != 30*time.Second {
+			t.Errorf("stream timeout = %s, request timeout = %s", client.streamClient().Timeout, client.httpClient.Timeout)
+		}
+	})
+}
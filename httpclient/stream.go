@@ -0,0 +1,69 @@
+package httpclient
+
+import (
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// Supported patterns of synthetic bodies
+const (
+	BodyPatternZero   = "zero"   // Zero bytes
+	BodyPatternRandom = "random" // Pseudo-random bytes that do not compress
+)
+
+// ContentTypeOctetStream is the content type of synthetic bodies
+const ContentTypeOctetStream = "application/octet-stream"
+
+// ValidateBodyPattern checks the pattern of a synthetic body.
+func ValidateBodyPattern(pattern string) error {
+	switch pattern {
+	case "", BodyPatternZero, BodyPatternRandom:
+		return nil
+	}
+	return fmt.Errorf("'%s' is not a valid body pattern. Supported patterns are: zero, random", pattern)
+}
+
+// NewSyntheticBody returns a body of size bytes of the pattern. The bytes are
+// generated as the body is read, so even large bodies take no memory.
+func NewSyntheticBody(size int64, pattern string) Body {
+	var source io.Reader = zeroReader{}
+	if pattern == BodyPatternRandom {
+		source = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+	return Body{
+		Reader:        io.LimitReader(source, size),
+		ContentType:   ContentTypeOctetStream,
+		ContentLength: size,
+	}
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = 0
+	}
+	return len(p), nil
+}
+
+// GetBody is GetRequestBody along with the length of the body, so that a
+// file is streamed with a content length rather than chunked.
+func GetBody(bodyFlag string) (Body, func(), error) {
+	reader, cleanup, err := GetRequestBody(bodyFlag)
+	if err != nil {
+		return Body{}, nil, err
+	}
+	body := Body{Reader: reader}
+	if file, ok := reader.(*os.File); ok {
+		info, err := file.Stat()
+		if err != nil {
+			cleanup()
+			return Body{}, nil, fmt.Errorf("error opening file: %v", err)
+		}
+		body.ContentLength = info.Size()
+	}
+	return body, cleanup, nil
+}
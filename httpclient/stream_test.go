@@ -0,0 +1,97 @@
+package httpclient
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewSyntheticBody(t *testing.T) {
+	const size = 3<<20 + 7
+	for _, pattern := range []string{BodyPatternZero, BodyPatternRandom} {
+		t.Run(pattern, func(t *testing.T) {
+			body := NewSyntheticBody(size, pattern)
+			content, err := io.ReadAll(body.Reader)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(content) != size || body.ContentLength != size || body.ContentType != ContentTypeOctetStream {
+				t.Errorf("NewSyntheticBody() = %d bytes of %s with length %d, want %d bytes", len(content), body.ContentType, body.ContentLength, size)
+			}
+			zeros := bytes.Count(content, []byte{0})
+			if pattern == BodyPatternZero && zeros != size {
+				t.Errorf("NewSyntheticBody() has %d non-zero bytes", size-zeros)
+			}
+			if pattern == BodyPatternRandom && zeros > size/100 {
+				t.Errorf("NewSyntheticBody() has %d zero bytes, want random bytes", zeros)
+			}
+		})
+	}
+}
+
+func TestClientStreamedBodies(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hash := sha256.New()
+		size, _ := io.Copy(hash, r.Body)
+		fmt.Fprintf(w, "%d|%v|%d|%x", r.ContentLength, r.TransferEncoding, size, hash.Sum(nil))
+	}))
+	defer ts.Close()
+
+	content := bytes.Repeat([]byte("0123456789"), 1<<16)
+	sum := sha256.Sum256(content)
+	path := filepath.Join(t.TempDir(), "upload.bin")
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		options Options
+		want    string
+	}{
+		{name: "content length", want: fmt.Sprintf("%d|[]|%d|%x", len(content), len(content), sum)},
+		{name: "chunked", options: Options{Chunked: true}, want: fmt.Sprintf("-1|[chunked]|%d|%x", len(content), sum)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, err := NewClient(tt.options)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			body, cleanup, err := GetBody("@" + path)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			defer cleanup()
+
+			resp, err := client.DoRequest(Request{Method: "POST", URL: ts.URL, Body: body.Reader, ContentLength: body.ContentLength})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if resp.Body != tt.want {
+				t.Errorf("got %q, want %q", resp.Body, tt.want)
+			}
+			if resp.SentBytes != int64(len(content)) || resp.UploadTime <= 0 {
+				t.Errorf("got %d bytes sent in %s, want %d bytes and an upload time", resp.SentBytes, resp.UploadTime, len(content))
+			}
+		})
+	}
+
+	t.Run("no body", func(t *testing.T) {
+		client, _ := NewClient(Options{Chunked: true})
+		resp, err := client.DoRequest(Request{Method: "GET", URL: ts.URL})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "0|[]|0|"+fmt.Sprintf("%x", sha256.Sum256(nil)) || resp.UploadTime != 0 {
+			t.Errorf("got %q with an upload time of %s, want no body", resp.Body, resp.UploadTime)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ParseTLSVersion converts a version such as "1.2" to its crypto/tls constant.
+// An empty version returns 0, which leaves the default in place.
+func ParseTLSVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	if value, ok := tlsVersions[version]; ok {
+		return value, nil
+	}
+	return 0, fmt.Errorf("'%s' is not a valid TLS version. Supported versions are: 1.0, 1.1, 1.2, 1.3", version)
+}
+
+// ParseCipherSuites converts cipher suite names to their IDs. Only TLS 1.0-1.2
+// suites can be selected, TLS 1.3 suites are not configurable.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	known := map[string]uint16{}
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range names {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("'%s' is not a known cipher suite", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// ValidateTLSOptions checks that the TLS options can be turned into a configuration.
+func ValidateTLSOptions(options Options) error {
+	_, err := newTLSConfig(options)
+	return err
+}
+
+// TLSConfig returns the TLS configuration the options describe, for clients
+// of other protocols that share the TLS settings.
+func TLSConfig(options Options) (*tls.Config, error) {
+	return newTLSConfig(options)
+}
+
+// newTLSConfig builds the TLS configuration of the transport from the options
+func newTLSConfig(options Options) (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: options.Insecure,
+		ServerName:         options.ServerName,
+	}
+
+	var err error
+	if config.MinVersion, err = ParseTLSVersion(options.MinTLSVersion); err != nil {
+		return nil, err
+	}
+	if config.MaxVersion, err = ParseTLSVersion(options.MaxTLSVersion); err != nil {
+		return nil, err
+	}
+	if config.MinVersion != 0 && config.MaxVersion != 0 && config.MinVersion > config.MaxVersion {
+		return nil, fmt.Errorf("the minimum TLS version cannot be higher than the maximum")
+	}
+
+	if config.CipherSuites, err = ParseCipherSuites(options.CipherSuites); err != nil {
+		return nil, err
+	}
+
+	if options.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA bundle: %v", err)
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle: %s", options.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	if options.CertFile != "" || options.KeyFile != "" {
+		if options.CertFile == "" || options.KeyFile == "" {
+			return nil, fmt.Errorf("both a client certificate and its key are required for mTLS")
+		}
+		certificate, err := tls.LoadX509KeyPair(options.CertFile, options.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("error loading client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	return config, nil
+}
+
+// tlsVersionName returns a readable name of a negotiated TLS version
+func tlsVersionName(version uint16) string {
+	for name, value := range tlsVersions {
+		if value == version {
+			return "TLS " + name
+		}
+	}
+	return fmt.Sprintf("0x%04X", version)
+}
@@ -0,0 +1,151 @@
+package httpclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate and its key as PEM files
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "benchmark client"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	certFile = filepath.Join(dir, "client.pem")
+	keyFile = filepath.Join(dir, "client-key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "EC PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestClientTLS(t *testing.T) {
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	// The CA bundle of the test server is its own certificate
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	writePEM(t, caFile, "CERTIFICATE", ts.Certificate().Raw)
+
+	t.Run("untrusted certificate", func(t *testing.T) {
+		client, err := NewClient(Options{})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if _, err := client.Do("GET", ts.URL, nil); err == nil {
+			t.Error("expected an error for an untrusted certificate")
+		}
+	})
+
+	t.Run("insecure", func(t *testing.T) {
+		client, err := NewClient(Options{Insecure: true})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.TLSVersion == "" || resp.CipherSuite == "" {
+			t.Errorf("expected the negotiated TLS version and cipher to be recorded, got %+v", resp)
+		}
+	})
+
+	t.Run("custom CA with pinned version and cipher", func(t *testing.T) {
+		client, err := NewClient(Options{
+			CAFile:        caFile,
+			MaxTLSVersion: "1.2",
+			CipherSuites:  []string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"},
+			ServerName:    "example.com", // httptest certificates are valid for example.com
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.TLSVersion != "TLS 1.2" {
+			t.Errorf("expected TLS 1.2, got %q", resp.TLSVersion)
+		}
+		if resp.CipherSuite != "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" {
+			t.Errorf("expected the selected cipher suite, got %q", resp.CipherSuite)
+		}
+	})
+
+	t.Run("invalid CA bundle", func(t *testing.T) {
+		notPEM := filepath.Join(t.TempDir(), "ca.txt")
+		os.WriteFile(notPEM, []byte("not a certificate"), 0600)
+		if _, err := NewClient(Options{CAFile: notPEM}); err == nil || !strings.Contains(err.Error(), "no certificates found") {
+			t.Errorf("unexpected error: %v", err)
+		}
+	})
+}
+
+func TestClientMutualTLS(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.StartTLS()
+	defer ts.Close()
+
+	t.Run("without client certificate", func(t *testing.T) {
+		client, _ := NewClient(Options{Insecure: true})
+		if _, err := client.Do("GET", ts.URL, nil); err == nil {
+			t.Error("expected the handshake to fail without a client certificate")
+		}
+	})
+
+	t.Run("with client certificate", func(t *testing.T) {
+		certFile, keyFile := writeCertificate(t, t.TempDir())
+		client, err := NewClient(Options{Insecure: true, CertFile: certFile, KeyFile: keyFile})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		resp, err := client.Do("GET", ts.URL, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if resp.Body != "benchmark client" {
+			t.Errorf("expected the server to see the client certificate, got %q", resp.Body)
+		}
+	})
+}
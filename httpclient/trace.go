@@ -0,0 +1,123 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Phases breaks the response time of a request down. The connection phases
+// are zero when the request reused a connection. With a proxy, DNS and Connect
+// refer to the proxy and Proxy is the time spent on the proxy handshake
+// (CONNECT or SOCKS5) before the connection to the target was usable.
+type Phases struct {
+	DNS             time.Duration
+	Connect         time.Duration
+	Proxy           time.Duration
+	TLS             time.Duration
+	TimeToFirstByte time.Duration // From the start of the request to the first response byte
+}
+
+// tracer records the connection and phase information of a single request.
+// The callbacks may run concurrently, e.g. when dialing several addresses.
+type tracer struct {
+	usesProxy bool
+	start     time.Time
+
+	mu           sync.Mutex
+	response     Response
+	dnsStart     time.Time
+	connectStart time.Time
+	connectDone  time.Time
+	tlsStart     time.Time
+	wroteHeaders time.Time
+}
+
+func newTracer(usesProxy bool) *tracer {
+	return &tracer{usesProxy: usesProxy, start: time.Now()}
+}
+
+func (t *tracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.response.Phases.DNS = time.Since(t.dnsStart)
+			t.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			if err != nil {
+				return
+			}
+			t.mu.Lock()
+			t.connectDone = time.Now()
+			t.response.Phases.Connect = t.connectDone.Sub(t.connectStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.proxyHandshakeDone(t.tlsStart)
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.response.Phases.TLS = time.Since(t.tlsStart)
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.response.ConnectionID = connectionID(info.Conn)
+			t.response.ConnectionReused = info.Reused
+			if t.tlsStart.IsZero() {
+				t.proxyHandshakeDone(time.Now())
+			}
+			t.mu.Unlock()
+		},
+		WroteHeaders: func() {
+			t.mu.Lock()
+			t.wroteHeaders = time.Now()
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			if !t.wroteHeaders.IsZero() {
+				t.response.UploadTime = time.Since(t.wroteHeaders)
+			}
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.response.Phases.TimeToFirstByte = time.Since(t.start)
+			t.mu.Unlock()
+		},
+	}
+}
+
+// snapshot returns a copy of the traced response. Callbacks of abandoned dials
+// may still run after the request has returned.
+func (t *tracer) snapshot() Response {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.response
+}
+
+// proxyHandshakeDone records the proxy handshake as ending at the given time.
+// The caller holds the lock.
+func (t *tracer) proxyHandshakeDone(at time.Time) {
+	if t.usesProxy && !t.connectDone.IsZero() && t.response.Phases.Proxy == 0 {
+		t.response.Phases.Proxy = at.Sub(t.connectDone)
+	}
+}
@@ -0,0 +1,148 @@
+package httpclient
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// WebSocket close codes the benchmark tells apart
+const (
+	CloseNormal   = websocket.CloseNormalClosure   // 1000
+	CloseAbnormal = websocket.CloseAbnormalClosure // 1006, the connection dropped without a close frame
+)
+
+// closeTimeout is how long Close waits for the server to answer the close frame
+const closeTimeout = time.Second
+
+// WebSocket is an open WebSocket connection. Send and Receive may be called
+// concurrently with each other, but not with themselves.
+type WebSocket struct {
+	Response Response // Handshake status, connection and phases
+
+	conn *websocket.Conn
+}
+
+// DialWebSocket opens a WebSocket connection with the TLS, proxy, connection
+// target, authentication and signing settings of the client. A connection is
+// returned even when the handshake failed, its Response tells how far it got.
+// A handshake the server refused with an HTTP status is not an error, but
+// leaves the connection closed.
+func (c *Client) DialWebSocket(ctx context.Context, url string, header http.Header) (*WebSocket, error) {
+	// The handshake request is built only to be authenticated and signed
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return &WebSocket{}, fmt.Errorf("error creating request: %v", err)
+	}
+	var tokenWait time.Duration
+	if c.auth != nil {
+		if tokenWait, err = c.auth.authenticate(req); err != nil {
+			return &WebSocket{Response: Response{TokenWait: tokenWait}}, err
+		}
+	}
+	setHeaders(req, header)
+	if c.signer != nil {
+		if err := c.signRequest(req); err != nil {
+			return &WebSocket{Response: Response{TokenWait: tokenWait}}, fmt.Errorf("error signing request: %v", err)
+		}
+	}
+
+	// WebSockets are always upgraded from HTTP/1.1
+	tlsConfig := &tls.Config{}
+	if c.tlsConfig != nil {
+		tlsConfig = c.tlsConfig.Clone()
+	}
+	tlsConfig.NextProtos = nil
+	dialer := websocket.Dialer{
+		NetDialContext:   c.dial,
+		Proxy:            c.proxy,
+		TLSClientConfig:  tlsConfig,
+		HandshakeTimeout: 30 * time.Second,
+	}
+
+	trace := newTracer(c.usesProxy(req))
+	ctx = httptrace.WithClientTrace(ctx, trace.clientTrace())
+	header = req.Header.Clone()
+	if req.Host != "" {
+		header.Set("Host", req.Host)
+	}
+	conn, resp, err := dialer.DialContext(ctx, req.URL.String(), header)
+
+	response := trace.snapshot()
+	response.TokenWait = tokenWait
+	if resp != nil {
+		response.StatusCode = resp.StatusCode
+		response.Protocol = resp.Proto
+		if resp.TLS != nil {
+			response.TLSVersion = tlsVersionName(resp.TLS.Version)
+			response.CipherSuite = tls.CipherSuiteName(resp.TLS.CipherSuite)
+		}
+	}
+	if errors.Is(err, websocket.ErrBadHandshake) && resp != nil && resp.StatusCode != http.StatusSwitchingProtocols {
+		// The server answered the handshake with a plain HTTP response, keep what it said
+		body, _ := io.ReadAll(resp.Body)
+		response.Body = string(body)
+		response.BodySize = int64(len(body))
+		return &WebSocket{Response: response}, nil
+	}
+	if err != nil {
+		return &WebSocket{Response: response}, fmt.Errorf("error opening WebSocket: %v", err)
+	}
+	return &WebSocket{Response: response, conn: conn}, nil
+}
+
+// Open reports whether the handshake succeeded
+func (w *WebSocket) Open() bool {
+	return w.conn != nil
+}
+
+// Send sends a text message
+func (w *WebSocket) Send(message string) error {
+	return w.conn.WriteMessage(websocket.TextMessage, []byte(message))
+}
+
+// Receive blocks until the next message arrives. Once the connection is
+// closed it returns an error, CloseCode tells how the connection ended.
+func (w *WebSocket) Receive() (string, error) {
+	_, message, err := w.conn.ReadMessage()
+	return string(message), err
+}
+
+// CloseCode returns the close code of an error returned by Receive. Anything
+// but a close frame is an abnormal closure.
+func CloseCode(err error) int {
+	var closeErr *websocket.CloseError
+	if errors.As(err, &closeErr) {
+		return closeErr.Code
+	}
+	return CloseAbnormal
+}
+
+// Close closes the connection normally. It sends a close frame and, while a
+// Receive is in progress, gives the server a moment to answer it.
+func (w *WebSocket) Close() error {
+	if w.conn == nil {
+		return nil
+	}
+	message := websocket.FormatCloseMessage(CloseNormal, "")
+	err := w.conn.WriteControl(websocket.CloseMessage, message, time.Now().Add(closeTimeout))
+	if err == nil {
+		w.conn.SetReadDeadline(time.Now().Add(closeTimeout))
+	}
+	return err
+}
+
+// Release closes the network connection. Call it once Receive has returned.
+func (w *WebSocket) Release() error {
+	if w.conn == nil {
+		return nil
+	}
+	return w.conn.Close()
+}
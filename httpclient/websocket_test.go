@@ -0,0 +1,96 @@
+package httpclient
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestClientWebSocket(t *testing.T) {
+	upgrader := websocket.Upgrader{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "no such socket", http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+			return
+		}
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		for {
+			kind, message, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if string(message) == "bye" {
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(4000, "bye"))
+				return
+			}
+			if err := conn.WriteMessage(kind, message); err != nil {
+				return
+			}
+		}
+	}))
+	defer ts.Close()
+	wsURL := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	client, err := NewClient(Options{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	socket, err := client.DialWebSocket(context.Background(), wsURL, http.Header{"X-Token": {"secret"}})
+	if err != nil || !socket.Open() {
+		t.Fatalf("DialWebSocket() error = %v, open = %v", err, socket.Open())
+	}
+	defer socket.Release()
+	if socket.Response.StatusCode != http.StatusSwitchingProtocols {
+		t.Errorf("DialWebSocket() status = %d, want %d", socket.Response.StatusCode, http.StatusSwitchingProtocols)
+	}
+
+	for _, message := range []string{`{"id":1}`, "hello"} {
+		if err := socket.Send(message); err != nil {
+			t.Fatalf("Send() unexpected error: %v", err)
+		}
+		reply, err := socket.Receive()
+		if err != nil {
+			t.Fatalf("Receive() unexpected error: %v", err)
+		}
+		if reply != message {
+			t.Errorf("Receive() = %q, want %q", reply, message)
+		}
+	}
+
+	// The server closes with its own code, which is not a normal close
+	if err := socket.Send("bye"); err != nil {
+		t.Fatalf("Send() unexpected error: %v", err)
+	}
+	_, err = socket.Receive()
+	if err == nil {
+		t.Fatalf("Receive() expected the connection to be closed")
+	}
+	if code := CloseCode(err); code != 4000 {
+		t.Errorf("CloseCode() = %d, want 4000", code)
+	}
+
+	// A refused handshake is not an error, but leaves the connection closed
+	refused, err := client.DialWebSocket(context.Background(), wsURL+"/missing", http.Header{"X-Token": {"secret"}})
+	if err != nil {
+		t.Fatalf("DialWebSocket() unexpected error: %v", err)
+	}
+	if refused.Open() {
+		t.Errorf("DialWebSocket() opened a connection refused with %d", refused.Response.StatusCode)
+	}
+	if refused.Response.StatusCode != http.StatusNotFound || !strings.Contains(refused.Response.Body, "no such socket") {
+		t.Errorf("DialWebSocket() response = %d %q, want 404 with the server's body", refused.Response.StatusCode, refused.Response.Body)
+	}
+}
@@ -3,6 +3,8 @@ package metrics
 import (
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"time"
 )
 
@@ -12,19 +14,191 @@ type RequestResult struct {
 	Response     string
 	StatusCode   int
 	ResponseTime time.Duration
+	StartTime    time.Time
 	Error        error
+	Warmup       bool // Sent during the warm-up phase, excluded from aggregates
+	Concurrency  int  // Concurrency limit when the request was started
+	Protocol     string // Negotiated protocol, e.g. HTTP/1.1 or HTTP/2.0
+	TLSVersion   string
+	CipherSuite  string
+
+	ErrorClass string            // Kind of failure, see ClassifyError, empty when the request succeeded
+	Tags       map[string]string // Labels set by the executor of the request, e.g. a custom one
+
+	ConnectionID     int  // Identifies the connection the request was sent on, 0 if none was made
+	ConnectionReused bool // The connection had served earlier requests
+	Phases           PhaseTimings
+
+	BodySize  int64  // Bytes of the response body, whether kept in Response or not
+	BodyHash  string // SHA-256 of the response body when only hashes are kept
+	Oversized bool   // The response body was cut off at the max body size
+
+	ContentEncoding string        // Coding of the response body, e.g. gzip
+	ReceivedBytes   int64         // Response body bytes as received, before decompression
+	RequestBodySize int64         // Request body bytes before compression
+	SentBytes       int64         // Request body bytes as sent
+	UploadTime      time.Duration // Time spent sending the request body, part of the response time
+
+	Target   string // URL of the target when the requests are spread over several
+	Endpoint string // Name of the workload endpoint the request was sampled from
+
+	Redirects int           // Redirects followed before the final response
+	Hops      []RedirectHop // The redirects followed, unless they are separate results
+	Hop       int           // Position in the redirect chain when hops are separate results, 0 otherwise
+	HopURL    string        // URL requested by the hop when hops are separate results
+	Redirect  bool          // A redirect response that was deliberately not followed, not a failure
+
+	TokenWait  time.Duration // Time waited for an OAuth2 token, left out of the response time
+	TokenFetch bool          // A request to the OAuth2 token endpoint, reported apart from the requests
+
+	// A server-sent event stream. ResponseTime is the time to connect, up to
+	// the response headers, and the stream stayed open for StreamTime after.
+	Stream       bool
+	Reconnect    bool            // The stream was reopened after an earlier one of the virtual user ended
+	Events       int             // Events received on the stream
+	FirstEvent   time.Duration   // From the start of the request to the first event, 0 without events
+	EventGaps    []time.Duration // Time between consecutive events
+	StreamTime   time.Duration   // How long the stream or WebSocket was open after connecting
+	Disconnected bool            // The stream ended before the test did
+
+	// A WebSocket connection. ResponseTime is the time to connect, up to the
+	// end of the handshake, and StatusCode is 101 when the handshake succeeded.
+	WebSocket        bool
+	MessagesSent     int
+	MessagesReceived int
+	RoundTrips       []time.Duration // From sending a message to receiving its reply
+	Unanswered       int             // Messages without a reply within the reply timeout
+	CloseCode        int             // Close code when the connection ended before the test did
+	AbnormalClose    bool            // The connection ended before the test did, other than with a normal close
+
+	// A gRPC call. StatusCode is its gRPC status code, 0 (OK) when the call
+	// succeeded, and ResponseTime lasts until the last response message.
+	GRPC           bool
+	GRPCStatus     string        // Name of the status code, e.g. Unavailable
+	Streaming      bool          // A server-streaming call
+	StreamMessages int           // Response messages of a server-streaming call
+	FirstMessage   time.Duration // From the start of a server-streaming call to its first response message
+
+	// A GraphQL request. A response with errors in its errors array is a
+	// failure whatever its HTTP status code.
+	GraphQL       bool
+	Operation     string // Name of the operation run
+	GraphQLErrors int    // Errors in the errors array of the response
+	GraphQLError  string // Message of the first of them
+}
+
+// RedirectHop is a response in a redirect chain that pointed elsewhere
+type RedirectHop struct {
+	URL          string
+	StatusCode   int
+	StartTime    time.Time
+	ResponseTime time.Duration // Until the response headers of the hop arrived
+}
+
+// PhaseTimings breaks the response time of a request down. The connection
+// phases are zero when an existing connection was reused. Behind a proxy DNS
+// and Connect refer to the proxy, and Proxy is the proxy handshake.
+type PhaseTimings struct {
+	DNS             time.Duration
+	Connect         time.Duration
+	Proxy           time.Duration
+	TLS             time.Duration
+	TimeToFirstByte time.Duration
 }
 
 // AggregateMetrics is used for calculating metrics across the whole test
 type AggregateMetrics struct {
 	TotalRequests     int
 	FailedRequests    int
+	ErrorClasses      map[string]int `json:",omitempty"` // failed requests per error class
 	SuccessRequests   int
 	SuccessRate       float64
 	AverageResponse   time.Duration
 	MinResponse       time.Duration
 	MaxResponse       time.Duration
 	TotalResponseTime time.Duration // for calculating average response time
+	P50Response       time.Duration
+	P90Response       time.Duration
+	P95Response       time.Duration
+	P99Response       time.Duration
+	TestDuration      time.Duration // from the first request start to the last response
+	RequestsPerSecond float64
+	WarmupRequests    int // warm-up requests left out of the metrics above
+	Connections       ConnectionMetrics
+	Phases            PhaseMetrics
+	BodyBytes         int64 // response body bytes read, after decompression
+	AverageBodySize   int64
+	ReceivedBytes     int64          // response body bytes as received
+	RequestBodyBytes  int64          // request body bytes before compression
+	SentBytes         int64          // request body bytes as sent
+	ReceiveBandwidth  float64        // received bytes per second
+	SendBandwidth     float64        // sent bytes per second
+	UploadTime        time.Duration  // time spent sending request bodies
+	UploadThroughput  float64        // sent bytes per second of upload time
+	OversizedBodies   int            // responses cut off at the max body size
+	Targets           []GroupMetrics `json:",omitempty"` // per target when the requests were spread over several
+	Endpoints         []GroupMetrics `json:",omitempty"` // per endpoint of a workload
+	Redirected        int            // requests that followed at least one redirect
+	Redirects         int            // redirects followed in total
+	Hops              []GroupMetrics `json:",omitempty"` // per position in the redirect chain when hops are separate results
+	TokenFetches      int            // requests to the OAuth2 token endpoint, left out of the metrics above
+	FailedTokens      int            // token requests that did not return a token
+	AverageTokenFetch time.Duration
+	Streams           *StreamMetrics    `json:",omitempty"` // server-sent event streams, in SSE mode only
+	WebSockets        *WebSocketMetrics `json:",omitempty"` // WebSocket connections, in WebSocket mode only
+	GRPC              *GRPCMetrics      `json:",omitempty"` // gRPC calls, in gRPC mode only
+	GraphQL           *GraphQLMetrics   `json:",omitempty"` // GraphQL errors, in GraphQL mode only
+	Operations        []GroupMetrics    `json:",omitempty"` // per GraphQL operation
+}
+
+// StreamMetrics summarizes the server-sent event streams. Connect times are
+// up to the response headers, first events from the start of the request.
+type StreamMetrics struct {
+	Opened            int // stream requests, including reconnects
+	Connected         int // streams the server answered with an event stream
+	Reconnects        int
+	Disconnects       int // streams that ended before the test did
+	Events            int
+	EventsPerSecond   float64 // over the test duration
+	AverageConnect    time.Duration
+	P95Connect        time.Duration
+	AverageFirstEvent time.Duration
+	P95FirstEvent     time.Duration
+	AverageEventGap   time.Duration
+	P50EventGap       time.Duration
+	P95EventGap       time.Duration
+	P99EventGap       time.Duration
+	MaxEventGap       time.Duration
+}
+
+// GroupMetrics holds the metrics of the requests sharing a target or endpoint
+type GroupMetrics struct {
+	Name    string
+	Metrics AggregateMetrics
+}
+
+// PhaseMetrics holds the average duration of each request phase. The connection
+// phases are averaged over the requests that went through them.
+type PhaseMetrics struct {
+	AverageDNS             time.Duration
+	AverageConnect         time.Duration
+	AverageProxy           time.Duration
+	AverageTLS             time.Duration
+	AverageTimeToFirstByte time.Duration
+}
+
+// ConnectionMetrics describes how the requests used connections. A connection's
+// lifetime is measured from the start of its first request to the end of its last one.
+type ConnectionMetrics struct {
+	NewConnections        int // connections opened during the test
+	ReusedRequests        int // requests sent on a connection that served earlier requests
+	ReuseRate             float64
+	RequestsPerConnection float64
+	AverageLifetime       time.Duration
+	MinLifetime           time.Duration
+	MaxLifetime           time.Duration
+	P50Lifetime           time.Duration
+	P95Lifetime           time.Duration
 }
 
 func NewAggregateMetrics() *AggregateMetrics {
@@ -34,18 +208,154 @@ func NewAggregateMetrics() *AggregateMetrics {
 	}
 }
 
+// Error classes of failed requests
+const (
+	ErrorClassTimeout = "timeout" // The request timed out before its response
+	ErrorClassError   = "error"   // The request failed without a response, e.g. to connect
+	ErrorClassStatus  = "status"  // The status code is not one of success
+	ErrorClassGraphQL = "graphql" // The GraphQL response has errors
+)
+
+// ClassifyError returns the error class of a failed request, or an empty class
+// if the request succeeded. A class the executor of the request set is kept.
+func ClassifyError(result RequestResult) string {
+	switch {
+	case result.ErrorClass != "":
+		return result.ErrorClass
+	case !isFailure(result):
+		return ""
+	case result.Error != nil:
+		// The clients wrap errors as text, so timeouts are told by their message
+		message := strings.ToLower(result.Error.Error())
+		if strings.Contains(message, "deadline exceeded") || strings.Contains(message, "timeout") {
+			return ErrorClassTimeout
+		}
+		return ErrorClassError
+	case result.GraphQLErrors > 0:
+		return ErrorClassGraphQL
+	}
+	return ErrorClassStatus
+}
+
+// isFailure reports whether the request errored or got a non-2xx status code.
+// A redirect that was deliberately not followed is not a failure, a request
+// with an error class always is.
+func isFailure(result RequestResult) bool {
+	if result.ErrorClass != "" {
+		return true
+	}
+	if result.Redirect && result.Error == nil && result.StatusCode >= 300 && result.StatusCode < 400 {
+		return false
+	}
+	if result.WebSocket {
+		// Only a switch of protocols opened the connection
+		return result.Error != nil || result.StatusCode != 101
+	}
+	if result.GRPC {
+		return result.Error != nil || result.StatusCode != 0
+	}
+	if result.GraphQLErrors > 0 {
+		return true
+	}
+	return result.Error != nil || result.StatusCode < 200 || result.StatusCode >= 300
+}
+
 func CalculateMetrics(results []RequestResult) AggregateMetrics {
+	metrics := calculateAggregate(results)
+	metrics.Targets = CalculateGroupMetrics(results, func(result RequestResult) string { return result.Target })
+	metrics.Endpoints = CalculateGroupMetrics(results, func(result RequestResult) string { return result.Endpoint })
+	metrics.Hops = CalculateGroupMetrics(results, hopName)
+	metrics.Operations = CalculateGroupMetrics(results, func(result RequestResult) string { return result.Operation })
+	return metrics
+}
+
+// CalculateGroupMetrics calculates the metrics of each group of results, sorted
+// by name. Results with an empty group name are left out.
+func CalculateGroupMetrics(results []RequestResult, group func(RequestResult) string) []GroupMetrics {
+	groups := map[string][]RequestResult{}
+	for _, result := range results {
+		if name := group(result); name != "" {
+			groups[name] = append(groups[name], result)
+		}
+	}
+	if len(groups) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(groups))
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	groupMetrics := make([]GroupMetrics, len(names))
+	for i, name := range names {
+		groupMetrics[i] = GroupMetrics{Name: name, Metrics: calculateAggregate(groups[name])}
+	}
+	return groupMetrics
+}
+
+// hopName names the group of a result by its position in the redirect chain
+func hopName(result RequestResult) string {
+	if result.Hop == 0 {
+		return ""
+	}
+	return fmt.Sprintf("Hop %d", result.Hop)
+}
+
+// calculateAggregate calculates the metrics of the results as a whole
+func calculateAggregate(results []RequestResult) AggregateMetrics {
 	metrics := NewAggregateMetrics()
+	var responseTimes []time.Duration
+	var firstStart, lastEnd time.Time
 
+	var tokenFetchTime time.Duration
 	for _, result := range results {
+		if result.TokenFetch {
+			metrics.TokenFetches++
+			tokenFetchTime += result.ResponseTime
+			if isFailure(result) {
+				metrics.FailedTokens++
+			}
+			continue
+		}
+		if result.Warmup {
+			metrics.WarmupRequests++
+			continue
+		}
+
+		if metrics.TotalRequests == 0 || result.StartTime.Before(firstStart) {
+			firstStart = result.StartTime
+		}
+		if end := result.StartTime.Add(result.ResponseTime + result.StreamTime); end.After(lastEnd) {
+			lastEnd = end
+		}
+
 		metrics.TotalRequests++
+		metrics.BodyBytes += result.BodySize
+		metrics.ReceivedBytes += result.ReceivedBytes
+		metrics.RequestBodyBytes += result.RequestBodySize
+		metrics.SentBytes += result.SentBytes
+		metrics.UploadTime += result.UploadTime
+		if result.Oversized {
+			metrics.OversizedBodies++
+		}
+		if result.Redirects > 0 {
+			metrics.Redirected++
+			metrics.Redirects += result.Redirects
+		}
 
-		if result.Error != nil || result.StatusCode < 200 || result.StatusCode >= 300 {
+		if isFailure(result) {
 			metrics.FailedRequests++
+			if metrics.ErrorClasses == nil {
+				metrics.ErrorClasses = map[string]int{}
+			}
+			metrics.ErrorClasses[ClassifyError(result)]++
 		} else {
 			// Only successful requests are considered for these metrics
 			metrics.SuccessRequests++
 			metrics.TotalResponseTime += result.ResponseTime
+			responseTimes = append(responseTimes, result.ResponseTime)
 
 			if result.ResponseTime < metrics.MinResponse {
 				metrics.MinResponse = result.ResponseTime
@@ -56,30 +366,538 @@ func CalculateMetrics(results []RequestResult) AggregateMetrics {
 		}
 	}
 
+	if metrics.TokenFetches > 0 {
+		metrics.AverageTokenFetch = tokenFetchTime / time.Duration(metrics.TokenFetches)
+	}
+
 	// Calculate the average response time for successful requests
 	if metrics.SuccessRequests > 0 {
 		metrics.AverageResponse = metrics.TotalResponseTime / time.Duration(metrics.SuccessRequests)
 	}
 
+	// Calculate the response time percentiles for successful requests
+	sort.Slice(responseTimes, func(i, j int) bool { return responseTimes[i] < responseTimes[j] })
+	metrics.P50Response = Percentile(responseTimes, 50)
+	metrics.P90Response = Percentile(responseTimes, 90)
+	metrics.P95Response = Percentile(responseTimes, 95)
+	metrics.P99Response = Percentile(responseTimes, 99)
+
 	// Calculate the success rate
 	if metrics.TotalRequests > 0 {
+		metrics.AverageBodySize = metrics.BodyBytes / int64(metrics.TotalRequests)
 		metrics.SuccessRate = (float64(metrics.SuccessRequests) / float64(metrics.TotalRequests)) * 100
 	}
 
+	// Calculate the throughput over the measured part of the test
+	if metrics.TotalRequests > 0 {
+		metrics.TestDuration = lastEnd.Sub(firstStart)
+		if metrics.TestDuration > 0 {
+			metrics.RequestsPerSecond = float64(metrics.TotalRequests) / metrics.TestDuration.Seconds()
+			metrics.ReceiveBandwidth = float64(metrics.ReceivedBytes) / metrics.TestDuration.Seconds()
+			metrics.SendBandwidth = float64(metrics.SentBytes) / metrics.TestDuration.Seconds()
+		}
+	}
+
+	// Upload throughput only counts the time the bodies were being sent
+	if metrics.UploadTime > 0 {
+		metrics.UploadThroughput = float64(metrics.SentBytes) / metrics.UploadTime.Seconds()
+	}
+
 	// Reset MinResponse if no successful requests were recorded
 	if metrics.MinResponse == time.Duration(math.MaxInt64) {
 		metrics.MinResponse = 0
 	}
 
+	metrics.Connections = CalculateConnectionMetrics(results)
+	metrics.Phases = CalculatePhaseMetrics(results)
+	metrics.Streams = CalculateStreamMetrics(results, metrics.TestDuration)
+	metrics.WebSockets = CalculateWebSocketMetrics(results, metrics.TestDuration)
+	metrics.GRPC = CalculateGRPCMetrics(results, metrics.TestDuration)
+	metrics.GraphQL = CalculateGraphQLMetrics(results)
+
 	return *metrics
 }
 
+// GRPCMetrics summarizes the gRPC calls by status code, like the success and
+// failure counts do for HTTP. First messages are from the start of the call.
+type GRPCMetrics struct {
+	Calls               int
+	StatusCodes         []StatusCount // calls per status code, in code order
+	StreamingCalls      int           // server-streaming calls
+	StreamMessages      int           // response messages of the server-streaming calls
+	MessagesPerSecond   float64       // stream messages over the test duration
+	AverageFirstMessage time.Duration // over the streaming calls with messages
+	P95FirstMessage     time.Duration
+}
+
+// StatusCount is the number of calls that ended with a status code
+type StatusCount struct {
+	Code  int
+	Name  string
+	Count int
+}
+
+// CalculateGRPCMetrics summarizes the measured gRPC calls over the test
+// duration. It returns nil if there are none.
+func CalculateGRPCMetrics(results []RequestResult, testDuration time.Duration) *GRPCMetrics {
+	var grpcMetrics GRPCMetrics
+	counts := map[int]*StatusCount{}
+	var firstMessages []time.Duration
+	for _, result := range results {
+		if !result.GRPC || result.Warmup || result.TokenFetch {
+			continue
+		}
+		grpcMetrics.Calls++
+		if result.Error == nil {
+			if counts[result.StatusCode] == nil {
+				counts[result.StatusCode] = &StatusCount{Code: result.StatusCode, Name: result.GRPCStatus}
+			}
+			counts[result.StatusCode].Count++
+		}
+		if result.Streaming {
+			grpcMetrics.StreamingCalls++
+			grpcMetrics.StreamMessages += result.StreamMessages
+			if result.StreamMessages > 0 {
+				firstMessages = append(firstMessages, result.FirstMessage)
+			}
+		}
+	}
+	if grpcMetrics.Calls == 0 {
+		return nil
+	}
+
+	for _, count := range counts {
+		grpcMetrics.StatusCodes = append(grpcMetrics.StatusCodes, *count)
+	}
+	sort.Slice(grpcMetrics.StatusCodes, func(i, j int) bool { return grpcMetrics.StatusCodes[i].Code < grpcMetrics.StatusCodes[j].Code })
+	grpcMetrics.AverageFirstMessage, grpcMetrics.P95FirstMessage = averageAndP95(firstMessages)
+	if testDuration > 0 {
+		grpcMetrics.MessagesPerSecond = float64(grpcMetrics.StreamMessages) / testDuration.Seconds()
+	}
+	return &grpcMetrics
+}
+
+// GraphQLMetrics counts the GraphQL errors. Responses with errors that came
+// with a 2xx status code are the failures HTTP alone would not have shown.
+type GraphQLMetrics struct {
+	Requests       int
+	ErrorResponses int // responses with errors, failures whatever their status code
+	Errors         int // errors over all responses
+	HiddenErrors   int // responses with errors and a 2xx status code
+}
+
+// CalculateGraphQLMetrics counts the errors of the measured GraphQL requests.
+// It returns nil if there are none.
+func CalculateGraphQLMetrics(results []RequestResult) *GraphQLMetrics {
+	var graphQLMetrics GraphQLMetrics
+	for _, result := range results {
+		if !result.GraphQL || result.Warmup || result.TokenFetch {
+			continue
+		}
+		graphQLMetrics.Requests++
+		if result.GraphQLErrors == 0 {
+			continue
+		}
+		graphQLMetrics.ErrorResponses++
+		graphQLMetrics.Errors += result.GraphQLErrors
+		if result.StatusCode >= 200 && result.StatusCode < 300 {
+			graphQLMetrics.HiddenErrors++
+		}
+	}
+	if graphQLMetrics.Requests == 0 {
+		return nil
+	}
+	return &graphQLMetrics
+}
+
+// WebSocketMetrics summarizes the WebSocket connections. Connect times are up
+// to the end of the handshake.
+type WebSocketMetrics struct {
+	Opened            int // connections attempted
+	Connected         int // connections with a successful handshake
+	AbnormalCloses    int // connections that ended before the test did, other than with a normal close
+	MessagesSent      int
+	MessagesReceived  int
+	Unanswered        int     // messages without a reply within the reply timeout
+	SentPerSecond     float64 // over the test duration
+	ReceivedPerSecond float64 // over the test duration
+	AverageConnect    time.Duration
+	P95Connect        time.Duration
+	AverageRoundTrip  time.Duration
+	P50RoundTrip      time.Duration
+	P95RoundTrip      time.Duration
+	P99RoundTrip      time.Duration
+	MaxRoundTrip      time.Duration
+}
+
+// CalculateStreamMetrics summarizes the measured server-sent event streams
+// over the test duration. It returns nil if there are none.
+func CalculateStreamMetrics(results []RequestResult, testDuration time.Duration) *StreamMetrics {
+	var streamMetrics StreamMetrics
+	var connectTimes, firstEvents, gaps []time.Duration
+	var totalGaps time.Duration
+	for _, result := range results {
+		if !result.Stream || result.Warmup || result.TokenFetch {
+			continue
+		}
+		streamMetrics.Opened++
+		if result.Reconnect {
+			streamMetrics.Reconnects++
+		}
+		if isFailure(result) {
+			continue
+		}
+		streamMetrics.Connected++
+		connectTimes = append(connectTimes, result.ResponseTime)
+		if result.Disconnected {
+			streamMetrics.Disconnects++
+		}
+		streamMetrics.Events += result.Events
+		if result.Events > 0 {
+			firstEvents = append(firstEvents, result.FirstEvent)
+		}
+		for _, gap := range result.EventGaps {
+			gaps = append(gaps, gap)
+			totalGaps += gap
+		}
+	}
+	if streamMetrics.Opened == 0 {
+		return nil
+	}
+
+	streamMetrics.AverageConnect, streamMetrics.P95Connect = averageAndP95(connectTimes)
+	streamMetrics.AverageFirstEvent, streamMetrics.P95FirstEvent = averageAndP95(firstEvents)
+	if len(gaps) > 0 {
+		sort.Slice(gaps, func(i, j int) bool { return gaps[i] < gaps[j] })
+		streamMetrics.AverageEventGap = totalGaps / time.Duration(len(gaps))
+		streamMetrics.P50EventGap = Percentile(gaps, 50)
+		streamMetrics.P95EventGap = Percentile(gaps, 95)
+		streamMetrics.P99EventGap = Percentile(gaps, 99)
+		streamMetrics.MaxEventGap = gaps[len(gaps)-1]
+	}
+	if testDuration > 0 {
+		streamMetrics.EventsPerSecond = float64(streamMetrics.Events) / testDuration.Seconds()
+	}
+	return &streamMetrics
+}
+
+// CalculateWebSocketMetrics summarizes the measured WebSocket connections over
+// the test duration. It returns nil if there are none.
+func CalculateWebSocketMetrics(results []RequestResult, testDuration time.Duration) *WebSocketMetrics {
+	var webSocketMetrics WebSocketMetrics
+	var connectTimes, roundTrips []time.Duration
+	var totalRoundTrips time.Duration
+	for _, result := range results {
+		if !result.WebSocket || result.Warmup || result.TokenFetch {
+			continue
+		}
+		webSocketMetrics.Opened++
+		if isFailure(result) {
+			continue
+		}
+		webSocketMetrics.Connected++
+		connectTimes = append(connectTimes, result.ResponseTime)
+		if result.AbnormalClose {
+			webSocketMetrics.AbnormalCloses++
+		}
+		webSocketMetrics.MessagesSent += result.MessagesSent
+		webSocketMetrics.MessagesReceived += result.MessagesReceived
+		webSocketMetrics.Unanswered += result.Unanswered
+		for _, roundTrip := range result.RoundTrips {
+			roundTrips = append(roundTrips, roundTrip)
+			totalRoundTrips += roundTrip
+		}
+	}
+	if webSocketMetrics.Opened == 0 {
+		return nil
+	}
+
+	webSocketMetrics.AverageConnect, webSocketMetrics.P95Connect = averageAndP95(connectTimes)
+	if len(roundTrips) > 0 {
+		sort.Slice(roundTrips, func(i, j int) bool { return roundTrips[i] < roundTrips[j] })
+		webSocketMetrics.AverageRoundTrip = totalRoundTrips / time.Duration(len(roundTrips))
+		webSocketMetrics.P50RoundTrip = Percentile(roundTrips, 50)
+		webSocketMetrics.P95RoundTrip = Percentile(roundTrips, 95)
+		webSocketMetrics.P99RoundTrip = Percentile(roundTrips, 99)
+		webSocketMetrics.MaxRoundTrip = roundTrips[len(roundTrips)-1]
+	}
+	if testDuration > 0 {
+		webSocketMetrics.SentPerSecond = float64(webSocketMetrics.MessagesSent) / testDuration.Seconds()
+		webSocketMetrics.ReceivedPerSecond = float64(webSocketMetrics.MessagesReceived) / testDuration.Seconds()
+	}
+	return &webSocketMetrics
+}
+
+// averageAndP95 returns the average and the p95 of the durations, sorting them
+func averageAndP95(durations []time.Duration) (time.Duration, time.Duration) {
+	if len(durations) == 0 {
+		return 0, 0
+	}
+	var total time.Duration
+	for _, duration := range durations {
+		total += duration
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	return total / time.Duration(len(durations)), Percentile(durations, 95)
+}
+
+// CalculateConnectionMetrics summarizes the connection usage of the measured requests
+func CalculateConnectionMetrics(results []RequestResult) ConnectionMetrics {
+	var connectionMetrics ConnectionMetrics
+	type span struct{ start, end time.Time }
+	spans := map[int]*span{}
+	requests := 0
+
+	for _, result := range results {
+		if result.Warmup || result.ConnectionID == 0 {
+			continue
+		}
+		requests++
+
+		if result.ConnectionReused {
+			connectionMetrics.ReusedRequests++
+		} else {
+			connectionMetrics.NewConnections++
+		}
+
+		end := result.StartTime.Add(result.ResponseTime)
+		s, ok := spans[result.ConnectionID]
+		if !ok {
+			spans[result.ConnectionID] = &span{start: result.StartTime, end: end}
+			continue
+		}
+		if result.StartTime.Before(s.start) {
+			s.start = result.StartTime
+		}
+		if end.After(s.end) {
+			s.end = end
+		}
+	}
+
+	if requests == 0 {
+		return connectionMetrics
+	}
+	connectionMetrics.ReuseRate = float64(connectionMetrics.ReusedRequests) / float64(requests) * 100
+	connectionMetrics.RequestsPerConnection = float64(requests) / float64(len(spans))
+
+	var lifetimes []time.Duration
+	var total time.Duration
+	for _, s := range spans {
+		lifetime := s.end.Sub(s.start)
+		lifetimes = append(lifetimes, lifetime)
+		total += lifetime
+	}
+	sort.Slice(lifetimes, func(i, j int) bool { return lifetimes[i] < lifetimes[j] })
+	connectionMetrics.AverageLifetime = total / time.Duration(len(lifetimes))
+	connectionMetrics.MinLifetime = lifetimes[0]
+	connectionMetrics.MaxLifetime = lifetimes[len(lifetimes)-1]
+	connectionMetrics.P50Lifetime = Percentile(lifetimes, 50)
+	connectionMetrics.P95Lifetime = Percentile(lifetimes, 95)
+
+	return connectionMetrics
+}
+
+// CalculatePhaseMetrics averages the phases of the measured requests
+func CalculatePhaseMetrics(results []RequestResult) PhaseMetrics {
+	var totals, counts [5]int64
+	for _, result := range results {
+		if result.Warmup {
+			continue
+		}
+		phases := []time.Duration{result.Phases.DNS, result.Phases.Connect, result.Phases.Proxy, result.Phases.TLS, result.Phases.TimeToFirstByte}
+		for i, phase := range phases {
+			if phase > 0 {
+				totals[i] += int64(phase)
+				counts[i]++
+			}
+		}
+	}
+
+	var averages [5]time.Duration
+	for i := range averages {
+		if counts[i] > 0 {
+			averages[i] = time.Duration(totals[i] / counts[i])
+		}
+	}
+
+	return PhaseMetrics{
+		AverageDNS:             averages[0],
+		AverageConnect:         averages[1],
+		AverageProxy:           averages[2],
+		AverageTLS:             averages[3],
+		AverageTimeToFirstByte: averages[4],
+	}
+}
+
+// Percentile returns the nearest-rank percentile p (0-100) of the sorted durations
+func Percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > len(sorted) {
+		rank = len(sorted)
+	}
+	return sorted[rank-1]
+}
+
 func PrintMetrics(metrics AggregateMetrics) {
 	fmt.Printf("Total Requests: %d\n", metrics.TotalRequests)
 	fmt.Printf("Successful Requests: %d\n", metrics.SuccessRequests)
 	fmt.Printf("Failed Requests: %d\n", metrics.FailedRequests)
+	if len(metrics.ErrorClasses) > 0 {
+		fmt.Printf("Failures by Class: %s\n", describeErrorClasses(metrics.ErrorClasses))
+	}
 	fmt.Printf("Success Rate: %.2f%%\n", metrics.SuccessRate)
 	fmt.Printf("Average Response Time: %s\n", metrics.AverageResponse)
 	fmt.Printf("Minimum Response Time: %s\n", metrics.MinResponse)
 	fmt.Printf("Maximum Response Time: %s\n", metrics.MaxResponse)
+	fmt.Printf("Response Time Percentiles: p50 %s, p90 %s, p95 %s, p99 %s\n", metrics.P50Response, metrics.P90Response, metrics.P95Response, metrics.P99Response)
+	fmt.Printf("Throughput: %.2f requests/s\n", metrics.RequestsPerSecond)
+	fmt.Printf("Average Phases: DNS %s, connect %s, proxy %s, TLS %s, time to first byte %s\n", metrics.Phases.AverageDNS, metrics.Phases.AverageConnect, metrics.Phases.AverageProxy, metrics.Phases.AverageTLS, metrics.Phases.AverageTimeToFirstByte)
+	fmt.Printf("Response Bodies: %d bytes, %d bytes on average\n", metrics.BodyBytes, metrics.AverageBodySize)
+	if metrics.ReceivedBytes != metrics.BodyBytes {
+		fmt.Printf("Compressed Response Bodies: %d bytes\n", metrics.ReceivedBytes)
+	}
+	if metrics.SentBytes != metrics.RequestBodyBytes {
+		fmt.Printf("Request Bodies: %d bytes, %d bytes compressed\n", metrics.RequestBodyBytes, metrics.SentBytes)
+	}
+	fmt.Printf("Bandwidth: %.0f bytes/s received, %.0f bytes/s sent\n", metrics.ReceiveBandwidth, metrics.SendBandwidth)
+	if metrics.UploadTime > 0 {
+		fmt.Printf("Upload Throughput: %.0f bytes/s over %s spent sending request bodies\n", metrics.UploadThroughput, metrics.UploadTime)
+	}
+	if metrics.OversizedBodies > 0 {
+		fmt.Printf("Oversized Responses: %d\n", metrics.OversizedBodies)
+	}
+	fmt.Printf("Connections Opened: %d (%.2f%% of requests reused a connection, %.2f requests per connection)\n", metrics.Connections.NewConnections, metrics.Connections.ReuseRate, metrics.Connections.RequestsPerConnection)
+	if metrics.Redirected > 0 {
+		fmt.Printf("Redirects: %d requests followed %d redirects\n", metrics.Redirected, metrics.Redirects)
+	}
+	if metrics.TokenFetches > 0 {
+		fmt.Printf("OAuth2 Token Fetches (excluded): %d, %d failed, average %s\n", metrics.TokenFetches, metrics.FailedTokens, metrics.AverageTokenFetch)
+	}
+	if metrics.WarmupRequests > 0 {
+		fmt.Printf("Warm-up Requests (excluded): %d\n", metrics.WarmupRequests)
+	}
+	if streams := metrics.Streams; streams != nil {
+		fmt.Printf("Streams: %d opened, %d connected, %d reconnects, %d disconnects\n", streams.Opened, streams.Connected, streams.Reconnects, streams.Disconnects)
+		fmt.Printf("Stream Connect Time: average %s, p95 %s\n", streams.AverageConnect, streams.P95Connect)
+		fmt.Printf("Time to First Event: average %s, p95 %s\n", streams.AverageFirstEvent, streams.P95FirstEvent)
+		fmt.Printf("Events: %d, %.2f events/s\n", streams.Events, streams.EventsPerSecond)
+		fmt.Printf("Inter-event Latency: average %s, p50 %s, p95 %s, p99 %s, max %s\n", streams.AverageEventGap, streams.P50EventGap, streams.P95EventGap, streams.P99EventGap, streams.MaxEventGap)
+	}
+	if webSockets := metrics.WebSockets; webSockets != nil {
+		fmt.Printf("WebSockets: %d opened, %d connected, %d abnormal closes\n", webSockets.Opened, webSockets.Connected, webSockets.AbnormalCloses)
+		fmt.Printf("WebSocket Connect Time: average %s, p95 %s\n", webSockets.AverageConnect, webSockets.P95Connect)
+		fmt.Printf("Messages: %d sent (%.2f/s), %d received (%.2f/s), %d unanswered\n", webSockets.MessagesSent, webSockets.SentPerSecond, webSockets.MessagesReceived, webSockets.ReceivedPerSecond, webSockets.Unanswered)
+		fmt.Printf("Message Round Trip: average %s, p50 %s, p95 %s, p99 %s, max %s\n", webSockets.AverageRoundTrip, webSockets.P50RoundTrip, webSockets.P95RoundTrip, webSockets.P99RoundTrip, webSockets.MaxRoundTrip)
+	}
+	if calls := metrics.GRPC; calls != nil {
+		codes := make([]string, 0, len(calls.StatusCodes))
+		for _, count := range calls.StatusCodes {
+			codes = append(codes, fmt.Sprintf("%s %d", count.Name, count.Count))
+		}
+		if len(codes) == 0 {
+			codes = append(codes, "none, no call reached the server")
+		}
+		fmt.Printf("gRPC Status Codes: %s\n", strings.Join(codes, ", "))
+		if calls.StreamingCalls > 0 {
+			fmt.Printf("Streaming Calls: %d, %d messages, %.2f messages/s\n", calls.StreamingCalls, calls.StreamMessages, calls.MessagesPerSecond)
+			fmt.Printf("Time to First Message: average %s, p95 %s\n", calls.AverageFirstMessage, calls.P95FirstMessage)
+		}
+	}
+	if graphQL := metrics.GraphQL; graphQL != nil {
+		fmt.Printf("GraphQL Errors: %d responses with %d errors, %d of them with a 2xx status code\n", graphQL.ErrorResponses, graphQL.Errors, graphQL.HiddenErrors)
+	}
+	for _, operation := range metrics.Operations {
+		fmt.Printf("Operation %s: %d requests, %.2f%% success, average %s, p95 %s, %.2f requests/s\n", operation.Name, operation.Metrics.TotalRequests, operation.Metrics.SuccessRate, operation.Metrics.AverageResponse, operation.Metrics.P95Response, operation.Metrics.RequestsPerSecond)
+	}
+	for _, endpoint := range metrics.Endpoints {
+		fmt.Printf("Endpoint %s: %d requests, %.2f%% success, average %s, p95 %s, %.2f requests/s\n", endpoint.Name, endpoint.Metrics.TotalRequests, endpoint.Metrics.SuccessRate, endpoint.Metrics.AverageResponse, endpoint.Metrics.P95Response, endpoint.Metrics.RequestsPerSecond)
+	}
+	for _, target := range metrics.Targets {
+		fmt.Printf("Target %s: %d requests, %.2f%% success, average %s, p95 %s, %.2f requests/s\n", target.Name, target.Metrics.TotalRequests, target.Metrics.SuccessRate, target.Metrics.AverageResponse, target.Metrics.P95Response, target.Metrics.RequestsPerSecond)
+	}
+	for _, hop := range metrics.Hops {
+		fmt.Printf("%s: %d responses, %.2f%% success, average %s, p95 %s\n", hop.Name, hop.Metrics.TotalRequests, hop.Metrics.SuccessRate, hop.Metrics.AverageResponse, hop.Metrics.P95Response)
+	}
+}
+
+// describeErrorClasses lists the failures per error class, sorted by class
+func describeErrorClasses(classes map[string]int) string {
+	names := make([]string, 0, len(classes))
+	for class := range classes {
+		names = append(names, class)
+	}
+	sort.Strings(names)
+	for i, class := range names {
+		names[i] = fmt.Sprintf("%s %d", class, classes[class])
+	}
+	return strings.Join(names, ", ")
+}
+
+// TimeBucket holds the metrics of the requests started within one interval of the test
+type TimeBucket struct {
+	Offset          time.Duration // Start of the bucket relative to the first request
+	Requests        int
+	FailedRequests  int
+	AverageResponse time.Duration
+	NewConnections  int  // Connections opened by the requests in the bucket
+	Concurrency     int  // Highest concurrency limit of the requests in the bucket
+	Warmup          bool // The bucket contains warm-up requests
+}
+
+// TimeSeries groups the results into buckets of the given interval by request start time.
+func TimeSeries(results []RequestResult, interval time.Duration) []TimeBucket {
+	if len(results) == 0 || interval <= 0 {
+		return nil
+	}
+
+	// Token fetches are left out, including the one made before the run
+	var first time.Time
+	for _, result := range results {
+		if !result.TokenFetch && (first.IsZero() || result.StartTime.Before(first)) {
+			first = result.StartTime
+		}
+	}
+
+	var buckets []TimeBucket
+	totals := []time.Duration{}
+	for _, result := range results {
+		if result.TokenFetch {
+			continue
+		}
+		index := int(result.StartTime.Sub(first) / interval)
+		for len(buckets) <= index {
+			buckets = append(buckets, TimeBucket{Offset: time.Duration(len(buckets)) * interval})
+			totals = append(totals, 0)
+		}
+
+		bucket := &buckets[index]
+		bucket.Requests++
+		totals[index] += result.ResponseTime
+		if isFailure(result) {
+			bucket.FailedRequests++
+		}
+		if result.ConnectionID != 0 && !result.ConnectionReused {
+			bucket.NewConnections++
+		}
+		if result.Concurrency > bucket.Concurrency {
+			bucket.Concurrency = result.Concurrency
+		}
+		if result.Warmup {
+			bucket.Warmup = true
+		}
+	}
+
+	for i := range buckets {
+		if buckets[i].Requests > 0 {
+			buckets[i].AverageResponse = totals[i] / time.Duration(buckets[i].Requests)
+		}
+	}
+
+	return buckets
 }
@@ -1,6 +1,8 @@
 package metrics
 
 import (
+	"errors"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -25,6 +27,17 @@ func failedRequest() RequestResult {
 	}
 }
 
+// helper function to tag a RequestResult as sent during warm-up
+func warmupRequest(result RequestResult) RequestResult {
+	result.Warmup = true
+	return result
+}
+
+// helper function to calculate the expected throughput
+func requestsPerSecond(requests int, duration time.Duration) float64 {
+	return float64(requests) / duration.Seconds()
+}
+
 func TestCalculateMetrics(t *testing.T) {
 	// Define test cases
 	tests := []struct {
@@ -48,6 +61,12 @@ func TestCalculateMetrics(t *testing.T) {
 				MinResponse:       100 * time.Millisecond,
 				MaxResponse:       200 * time.Millisecond,
 				TotalResponseTime: 450 * time.Millisecond,
+				P50Response:       150 * time.Millisecond,
+				P90Response:       200 * time.Millisecond,
+				P95Response:       200 * time.Millisecond,
+				P99Response:       200 * time.Millisecond,
+				TestDuration:      200 * time.Millisecond,
+				RequestsPerSecond: requestsPerSecond(3, 200*time.Millisecond),
 			},
 		},
 		{
@@ -61,12 +80,44 @@ func TestCalculateMetrics(t *testing.T) {
 			want: AggregateMetrics{
 				TotalRequests:     4,
 				FailedRequests:    2,
+				ErrorClasses:      map[string]int{ErrorClassStatus: 2},
 				SuccessRequests:   2,
 				SuccessRate:       50.0,
 				AverageResponse:   150 * time.Millisecond,
 				MinResponse:       120 * time.Millisecond,
 				MaxResponse:       180 * time.Millisecond,
 				TotalResponseTime: 300 * time.Millisecond,
+				P50Response:       120 * time.Millisecond,
+				P90Response:       180 * time.Millisecond,
+				P95Response:       180 * time.Millisecond,
+				P99Response:       180 * time.Millisecond,
+				TestDuration:      180 * time.Millisecond,
+				RequestsPerSecond: requestsPerSecond(4, 180*time.Millisecond),
+			},
+		},
+		{
+			name: "Warm-up requests excluded",
+			requestResults: []RequestResult{
+				warmupRequest(failedRequest()),
+				warmupRequest(successfulRequest(900 * time.Millisecond)),
+				successfulRequest(100 * time.Millisecond),
+			},
+			want: AggregateMetrics{
+				TotalRequests:     1,
+				FailedRequests:    0,
+				SuccessRequests:   1,
+				SuccessRate:       100.0,
+				AverageResponse:   100 * time.Millisecond,
+				MinResponse:       100 * time.Millisecond,
+				MaxResponse:       100 * time.Millisecond,
+				TotalResponseTime: 100 * time.Millisecond,
+				P50Response:       100 * time.Millisecond,
+				P90Response:       100 * time.Millisecond,
+				P95Response:       100 * time.Millisecond,
+				P99Response:       100 * time.Millisecond,
+				TestDuration:      100 * time.Millisecond,
+				RequestsPerSecond: requestsPerSecond(1, 100*time.Millisecond),
+				WarmupRequests:    2,
 			},
 		},
 		{
@@ -89,9 +140,598 @@ func TestCalculateMetrics(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			got := CalculateMetrics(tt.requestResults)
-			if got != tt.want {
+			if !reflect.DeepEqual(got, tt.want) {
 				t.Errorf("CalculateMetrics() = %v, want %v", got, tt.want)
 			}
 		})
 	}
 }
+
+// helper function to set the concurrency limit a RequestResult was started with
+func withConcurrency(result RequestResult, concurrency int) RequestResult {
+	result.Concurrency = concurrency
+	return result
+}
+
+func TestTimeSeries(t *testing.T) {
+	start := time.Now()
+	at := func(offset time.Duration, result RequestResult) RequestResult {
+		result.StartTime = start.Add(offset)
+		return result
+	}
+
+	results := []RequestResult{
+		at(0, warmupRequest(successfulRequest(300*time.Millisecond))),
+		at(500*time.Millisecond, successfulRequest(100*time.Millisecond)),
+		at(2100*time.Millisecond, withConcurrency(failedRequest(), 8)),
+		at(2900*time.Millisecond, withConcurrency(successfulRequest(200*time.Millisecond), 4)),
+	}
+
+	want := []TimeBucket{
+		{Offset: 0, Requests: 2, AverageResponse: 200 * time.Millisecond, Warmup: true},
+		{Offset: time.Second},
+		{Offset: 2 * time.Second, Requests: 2, FailedRequests: 1, AverageResponse: 150 * time.Millisecond, Concurrency: 8},
+	}
+
+	got := TimeSeries(results, time.Second)
+	if len(got) != len(want) {
+		t.Fatalf("TimeSeries() returned %d buckets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("TimeSeries()[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	sorted := []time.Duration{10, 20, 30, 40, 50, 60, 70, 80, 90, 100}
+
+	tests := []struct {
+		p    float64
+		want time.Duration
+	}{
+		{0, 10},
+		{50, 50},
+		{90, 90},
+		{95, 100},
+		{99, 100},
+		{100, 100},
+	}
+
+	for _, tt := range tests {
+		if got := Percentile(sorted, tt.p); got != tt.want {
+			t.Errorf("Percentile(%v) = %v, want %v", tt.p, got, tt.want)
+		}
+	}
+
+	if got := Percentile(nil, 50); got != 0 {
+		t.Errorf("Percentile() of no durations = %v, want 0", got)
+	}
+}
+
+func TestCalculateConnectionMetrics(t *testing.T) {
+	start := time.Now()
+	on := func(connectionID int, reused bool, offset, responseTime time.Duration) RequestResult {
+		result := successfulRequest(responseTime)
+		result.StartTime = start.Add(offset)
+		result.ConnectionID = connectionID
+		result.ConnectionReused = reused
+		return result
+	}
+
+	results := []RequestResult{
+		on(1, false, 0, 100*time.Millisecond),
+		on(1, true, 200*time.Millisecond, 100*time.Millisecond),
+		on(1, true, 900*time.Millisecond, 100*time.Millisecond),
+		on(2, false, 0, 300*time.Millisecond),
+		warmupRequest(on(3, false, 0, 100*time.Millisecond)),
+		failedRequest(), // never got a connection
+	}
+
+	want := ConnectionMetrics{
+		NewConnections:        2,
+		ReusedRequests:        2,
+		ReuseRate:             50,
+		RequestsPerConnection: 2,
+		AverageLifetime:       650 * time.Millisecond,
+		MinLifetime:           300 * time.Millisecond,
+		MaxLifetime:           time.Second,
+		P50Lifetime:           300 * time.Millisecond,
+		P95Lifetime:           time.Second,
+	}
+
+	if got := CalculateConnectionMetrics(results); got != want {
+		t.Errorf("CalculateConnectionMetrics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCalculatePhaseMetrics(t *testing.T) {
+	withPhases := func(phases PhaseTimings) RequestResult {
+		result := successfulRequest(100 * time.Millisecond)
+		result.Phases = phases
+		return result
+	}
+
+	results := []RequestResult{
+		withPhases(PhaseTimings{DNS: 4 * time.Millisecond, Connect: 10 * time.Millisecond, Proxy: 6 * time.Millisecond, TLS: 20 * time.Millisecond, TimeToFirstByte: 90 * time.Millisecond}),
+		withPhases(PhaseTimings{DNS: 2 * time.Millisecond, Connect: 20 * time.Millisecond, TLS: 30 * time.Millisecond, TimeToFirstByte: 70 * time.Millisecond}),
+		withPhases(PhaseTimings{TimeToFirstByte: 50 * time.Millisecond}), // reused connection
+		warmupRequest(withPhases(PhaseTimings{DNS: time.Second, TimeToFirstByte: time.Second})),
+	}
+
+	want := PhaseMetrics{
+		AverageDNS:             3 * time.Millisecond,
+		AverageConnect:         15 * time.Millisecond,
+		AverageProxy:           6 * time.Millisecond,
+		AverageTLS:             25 * time.Millisecond,
+		AverageTimeToFirstByte: 70 * time.Millisecond,
+	}
+
+	if got := CalculatePhaseMetrics(results); got != want {
+		t.Errorf("CalculatePhaseMetrics() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCalculateMetricsTargets(t *testing.T) {
+	onTarget := func(target string, result RequestResult) RequestResult {
+		result.Target = target
+		return result
+	}
+
+	results := []RequestResult{
+		onTarget("http://pod-2", successfulRequest(400*time.Millisecond)),
+		onTarget("http://pod-1", successfulRequest(100*time.Millisecond)),
+		onTarget("http://pod-2", failedRequest()),
+		onTarget("http://pod-1", successfulRequest(120*time.Millisecond)),
+	}
+
+	got := CalculateMetrics(results)
+	if got.TotalRequests != 4 {
+		t.Errorf("CalculateMetrics() counted %d requests, want 4", got.TotalRequests)
+	}
+	if len(got.Targets) != 2 {
+		t.Fatalf("CalculateMetrics() returned %d targets, want 2", len(got.Targets))
+	}
+
+	pod1, pod2 := got.Targets[0], got.Targets[1]
+	if pod1.Name != "http://pod-1" || pod1.Metrics.TotalRequests != 2 || pod1.Metrics.P95Response != 120*time.Millisecond {
+		t.Errorf("CalculateMetrics() first target = %+v", pod1)
+	}
+	if pod2.Name != "http://pod-2" || pod2.Metrics.FailedRequests != 1 || pod2.Metrics.P95Response != 400*time.Millisecond {
+		t.Errorf("CalculateMetrics() second target = %+v", pod2)
+	}
+	if pod1.Metrics.Targets != nil {
+		t.Errorf("expected no nested targets, got %+v", pod1.Metrics.Targets)
+	}
+}
+
+func TestCalculateMetricsEndpoints(t *testing.T) {
+	onEndpoint := func(endpoint string, result RequestResult) RequestResult {
+		result.Endpoint = endpoint
+		return result
+	}
+
+	results := []RequestResult{
+		onEndpoint("list", successfulRequest(100*time.Millisecond)),
+		onEndpoint("list", successfulRequest(140*time.Millisecond)),
+		onEndpoint("create", failedRequest()),
+	}
+
+	got := CalculateMetrics(results)
+	if got.TotalRequests != 3 || got.FailedRequests != 1 {
+		t.Errorf("CalculateMetrics() total = %d requests, %d failed, want 3 and 1", got.TotalRequests, got.FailedRequests)
+	}
+	if len(got.Endpoints) != 2 {
+		t.Fatalf("CalculateMetrics() returned %d endpoints, want 2", len(got.Endpoints))
+	}
+	if create := got.Endpoints[0]; create.Name != "create" || create.Metrics.FailedRequests != 1 {
+		t.Errorf("CalculateMetrics() first endpoint = %+v", create)
+	}
+	if list := got.Endpoints[1]; list.Name != "list" || list.Metrics.AverageResponse != 120*time.Millisecond {
+		t.Errorf("CalculateMetrics() second endpoint = %+v", list)
+	}
+	if got.Targets != nil {
+		t.Errorf("expected no targets, got %+v", got.Targets)
+	}
+}
+
+func TestCalculateMetricsBodies(t *testing.T) {
+	withBody := func(size int64, oversized bool) RequestResult {
+		result := successfulRequest(100 * time.Millisecond)
+		result.BodySize = size
+		result.Oversized = oversized
+		return result
+	}
+
+	results := []RequestResult{
+		withBody(100, false),
+		withBody(300, false),
+		withBody(1024, true),
+		warmupRequest(withBody(5000, true)),
+	}
+
+	got := CalculateMetrics(results)
+	if got.BodyBytes != 1424 || got.AverageBodySize != 474 || got.OversizedBodies != 1 {
+		t.Errorf("CalculateMetrics() bodies = %d bytes, %d on average, %d oversized, want 1424, 474, 1", got.BodyBytes, got.AverageBodySize, got.OversizedBodies)
+	}
+}
+
+func TestCalculateMetricsBandwidth(t *testing.T) {
+	start := time.Now()
+	withTransfer := func(offset time.Duration, received, decompressed, sent int64) RequestResult {
+		result := successfulRequest(500 * time.Millisecond)
+		result.StartTime = start.Add(offset)
+		result.ReceivedBytes = received
+		result.BodySize = decompressed
+		result.SentBytes = sent
+		result.RequestBodySize = sent * 4
+		return result
+	}
+
+	// Two requests over two seconds
+	results := []RequestResult{
+		withTransfer(0, 1000, 4000, 200),
+		withTransfer(1500*time.Millisecond, 3000, 12000, 600),
+	}
+
+	got := CalculateMetrics(results)
+	if got.ReceivedBytes != 4000 || got.BodyBytes != 16000 || got.SentBytes != 800 || got.RequestBodyBytes != 3200 {
+		t.Errorf("CalculateMetrics() bytes = %d received, %d decompressed, %d sent, %d before compression", got.ReceivedBytes, got.BodyBytes, got.SentBytes, got.RequestBodyBytes)
+	}
+	if got.ReceiveBandwidth != 2000 || got.SendBandwidth != 400 {
+		t.Errorf("CalculateMetrics() bandwidth = %.0f received, %.0f sent bytes/s, want 2000 and 400", got.ReceiveBandwidth, got.SendBandwidth)
+	}
+}
+
+func TestCalculateMetricsUploadThroughput(t *testing.T) {
+	upload := func(sent int64, uploadTime time.Duration) RequestResult {
+		result := successfulRequest(2 * time.Second)
+		result.SentBytes = sent
+		result.RequestBodySize = sent
+		result.UploadTime = uploadTime
+		return result
+	}
+
+	// 3 MB sent over 1.5 seconds of uploading, the bodiless request adds no upload time
+	results := []RequestResult{
+		upload(1<<20, 500*time.Millisecond),
+		upload(2<<20, time.Second),
+		successfulRequest(time.Second),
+	}
+
+	got := CalculateMetrics(results)
+	if got.UploadTime != 1500*time.Millisecond || got.UploadThroughput != 2<<20 {
+		t.Errorf("CalculateMetrics() upload = %.0f bytes/s over %s, want %d bytes/s over 1.5s", got.UploadThroughput, got.UploadTime, 2<<20)
+	}
+	if got.AverageResponse == got.UploadTime {
+		t.Errorf("CalculateMetrics() response time should be reported apart from the upload time")
+	}
+}
+
+func TestCalculateMetricsRedirects(t *testing.T) {
+	redirected := successfulRequest(300 * time.Millisecond)
+	redirected.Redirects = 2
+	notFollowed := successfulRequest(100 * time.Millisecond)
+	notFollowed.StatusCode = 302
+	notFollowed.Redirect = true
+	unexpected := successfulRequest(100 * time.Millisecond)
+	unexpected.StatusCode = 304
+
+	// A chain split into hops: two redirects and the final response
+	hop := func(position, statusCode int, responseTime time.Duration) RequestResult {
+		result := successfulRequest(responseTime)
+		result.StatusCode = statusCode
+		result.Hop = position
+		result.Redirect = statusCode == 302
+		return result
+	}
+	results := []RequestResult{
+		redirected,
+		notFollowed,
+		unexpected,
+		hop(1, 302, 100*time.Millisecond),
+		hop(2, 302, 200*time.Millisecond),
+		hop(3, 200, 300*time.Millisecond),
+	}
+
+	got := CalculateMetrics(results)
+	if got.Redirected != 1 || got.Redirects != 2 {
+		t.Errorf("CalculateMetrics() redirects = %d requests, %d redirects, want 1 and 2", got.Redirected, got.Redirects)
+	}
+	if got.FailedRequests != 1 {
+		t.Errorf("CalculateMetrics() failed = %d, want only the unexpected 304", got.FailedRequests)
+	}
+
+	wantHops := []string{"Hop 1", "Hop 2", "Hop 3"}
+	if len(got.Hops) != len(wantHops) {
+		t.Fatalf("CalculateMetrics() hops = %d, want %d", len(got.Hops), len(wantHops))
+	}
+	for i, name := range wantHops {
+		if got.Hops[i].Name != name || got.Hops[i].Metrics.TotalRequests != 1 || got.Hops[i].Metrics.SuccessRequests != 1 {
+			t.Errorf("CalculateMetrics() hop %d = %+v, want %s with one successful response", i, got.Hops[i], name)
+		}
+	}
+}
+
+func TestCalculateMetricsTokenFetches(t *testing.T) {
+	start := time.Now()
+	tokenFetch := func(offset, responseTime time.Duration, statusCode int) RequestResult {
+		return RequestResult{StartTime: start.Add(offset), ResponseTime: responseTime, StatusCode: statusCode, TokenFetch: true}
+	}
+	request := successfulRequest(100 * time.Millisecond)
+	request.StartTime = start.Add(time.Second)
+
+	// A fetch before the run and a failed refresh during it
+	results := []RequestResult{tokenFetch(0, 300*time.Millisecond, 200), request, tokenFetch(time.Second, 100*time.Millisecond, 500)}
+
+	got := CalculateMetrics(results)
+	if got.TotalRequests != 1 || got.FailedRequests != 0 || got.AverageResponse != 100*time.Millisecond {
+		t.Errorf("CalculateMetrics() = %d requests, %d failed, average %s, want only the benchmark request", got.TotalRequests, got.FailedRequests, got.AverageResponse)
+	}
+	if got.TokenFetches != 2 || got.FailedTokens != 1 || got.AverageTokenFetch != 200*time.Millisecond {
+		t.Errorf("CalculateMetrics() token fetches = %d, %d failed, average %s, want 2, 1, 200ms", got.TokenFetches, got.FailedTokens, got.AverageTokenFetch)
+	}
+
+	buckets := TimeSeries(results, time.Second)
+	if len(buckets) != 1 || buckets[0].Requests != 1 {
+		t.Errorf("TimeSeries() = %+v, want one bucket with the benchmark request", buckets)
+	}
+}
+
+func TestCalculateMetricsStreams(t *testing.T) {
+	start := time.Now()
+	stream := func(connect, open time.Duration, gaps ...time.Duration) RequestResult {
+		result := successfulRequest(connect)
+		result.StartTime = start
+		result.Stream = true
+		result.StreamTime = open
+		if len(gaps) > 0 {
+			result.Events = len(gaps)
+			result.FirstEvent = connect + gaps[0]
+			result.EventGaps = gaps[1:]
+		}
+		return result
+	}
+
+	// Two streams over the 10 second test, one of them reconnected after the
+	// server closed it, and a reconnect the server refused
+	first := stream(100*time.Millisecond, 4900*time.Millisecond, 50*time.Millisecond, time.Second, time.Second)
+	first.Disconnected = true
+	second := stream(300*time.Millisecond, 9700*time.Millisecond, 150*time.Millisecond, 2*time.Second, 2*time.Second, 4*time.Second)
+	reconnected := stream(100*time.Millisecond, 4800*time.Millisecond, 100*time.Millisecond)
+	reconnected.StartTime = start.Add(5100 * time.Millisecond)
+	reconnected.Reconnect = true
+	refused := failedRequest()
+	refused.StartTime = start.Add(5 * time.Second)
+	refused.Stream = true
+	refused.Reconnect = true
+
+	got := CalculateMetrics([]RequestResult{first, second, reconnected, refused})
+	if got.TestDuration != 10*time.Second {
+		t.Fatalf("CalculateMetrics() duration = %s, want the 10s the streams were open", got.TestDuration)
+	}
+	want := &StreamMetrics{
+		Opened:            4,
+		Connected:         3,
+		Reconnects:        2,
+		Disconnects:       1,
+		Events:            8,
+		EventsPerSecond:   0.8,
+		AverageConnect:    500 * time.Millisecond / 3,
+		P95Connect:        300 * time.Millisecond,
+		AverageFirstEvent: 800 * time.Millisecond / 3,
+		P95FirstEvent:     450 * time.Millisecond,
+		AverageEventGap:   2 * time.Second,
+		P50EventGap:       2 * time.Second,
+		P95EventGap:       4 * time.Second,
+		P99EventGap:       4 * time.Second,
+		MaxEventGap:       4 * time.Second,
+	}
+	if !reflect.DeepEqual(got.Streams, want) {
+		t.Errorf("CalculateMetrics() streams = %+v, want %+v", got.Streams, want)
+	}
+
+	if got := CalculateMetrics([]RequestResult{successfulRequest(time.Second)}); got.Streams != nil {
+		t.Errorf("CalculateMetrics() streams = %+v, want nil without streams", got.Streams)
+	}
+}
+
+func TestCalculateMetricsWebSockets(t *testing.T) {
+	start := time.Now()
+	connection := func(connect, open time.Duration, sent int, roundTrips ...time.Duration) RequestResult {
+		return RequestResult{
+			StatusCode:       101,
+			ResponseTime:     connect,
+			StartTime:        start,
+			WebSocket:        true,
+			StreamTime:       open,
+			MessagesSent:     sent,
+			MessagesReceived: len(roundTrips),
+			RoundTrips:       roundTrips,
+			Unanswered:       sent - len(roundTrips),
+		}
+	}
+
+	// Two connections over the 10 second test, one closed early by the server
+	// with an error code, and handshakes the server refused or ignored
+	first := connection(100*time.Millisecond, 9900*time.Millisecond, 10,
+		10*time.Millisecond, 20*time.Millisecond, 30*time.Millisecond, 40*time.Millisecond, 50*time.Millisecond,
+		60*time.Millisecond, 70*time.Millisecond, 80*time.Millisecond, 90*time.Millisecond)
+	second := connection(200*time.Millisecond, 4800*time.Millisecond, 2, 100*time.Millisecond, 200*time.Millisecond)
+	second.CloseCode = 1011
+	second.AbnormalClose = true
+	refused := failedRequest()
+	refused.StatusCode = 403
+	refused.StartTime = start
+	refused.WebSocket = true
+
+	// A server that answers the handshake like any other request did not open a connection
+	notUpgraded := successfulRequest(50 * time.Millisecond)
+	notUpgraded.StartTime = start
+	notUpgraded.WebSocket = true
+
+	got := CalculateMetrics([]RequestResult{first, second, refused, notUpgraded})
+	if got.TestDuration != 10*time.Second {
+		t.Fatalf("CalculateMetrics() duration = %s, want the 10s the connections were open", got.TestDuration)
+	}
+	if got.SuccessRequests != 2 || got.FailedRequests != 2 {
+		t.Errorf("CalculateMetrics() successful = %d, failed = %d, want 2 and 2", got.SuccessRequests, got.FailedRequests)
+	}
+	want := &WebSocketMetrics{
+		Opened:            4,
+		Connected:         2,
+		AbnormalCloses:    1,
+		MessagesSent:      12,
+		MessagesReceived:  11,
+		Unanswered:        1,
+		SentPerSecond:     1.2,
+		ReceivedPerSecond: 1.1,
+		AverageConnect:    150 * time.Millisecond,
+		P95Connect:        200 * time.Millisecond,
+		AverageRoundTrip:  750 * time.Millisecond / 11,
+		P50RoundTrip:      60 * time.Millisecond,
+		P95RoundTrip:      200 * time.Millisecond,
+		P99RoundTrip:      200 * time.Millisecond,
+		MaxRoundTrip:      200 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(got.WebSockets, want) {
+		t.Errorf("CalculateMetrics() websockets = %+v, want %+v", got.WebSockets, want)
+	}
+
+	if got := CalculateMetrics([]RequestResult{successfulRequest(time.Second)}); got.WebSockets != nil {
+		t.Errorf("CalculateMetrics() websockets = %+v, want nil without connections", got.WebSockets)
+	}
+}
+
+func TestCalculateMetricsGRPC(t *testing.T) {
+	start := time.Now()
+	call := func(code int, name string, responseTime time.Duration) RequestResult {
+		return RequestResult{StatusCode: code, GRPCStatus: name, ResponseTime: responseTime, StartTime: start, GRPC: true}
+	}
+	stream := func(messages int, firstMessage, responseTime time.Duration) RequestResult {
+		result := call(0, "OK", responseTime)
+		result.Streaming = true
+		result.StreamMessages = messages
+		result.FirstMessage = firstMessage
+		return result
+	}
+
+	// Unary calls that succeeded or failed with a status, one that never left
+	// the client, and two streams over the 2 second test
+	unknownMethod := call(0, "", 0)
+	unknownMethod.Error = errors.New("the service orders.Orders has no method List")
+	results := []RequestResult{
+		call(0, "OK", 100*time.Millisecond),
+		call(14, "Unavailable", 50*time.Millisecond),
+		call(0, "OK", 300*time.Millisecond),
+		call(5, "NotFound", 10*time.Millisecond),
+		call(14, "Unavailable", 20*time.Millisecond),
+		unknownMethod,
+		stream(30, 100*time.Millisecond, 2*time.Second),
+		stream(0, 0, time.Second),
+	}
+
+	got := CalculateMetrics(results)
+	if got.SuccessRequests != 4 || got.FailedRequests != 4 {
+		t.Errorf("CalculateMetrics() successful = %d, failed = %d, want 4 and 4", got.SuccessRequests, got.FailedRequests)
+	}
+	want := &GRPCMetrics{
+		Calls: 8,
+		StatusCodes: []StatusCount{
+			{Code: 0, Name: "OK", Count: 4},
+			{Code: 5, Name: "NotFound", Count: 1},
+			{Code: 14, Name: "Unavailable", Count: 2},
+		},
+		StreamingCalls:      2,
+		StreamMessages:      30,
+		MessagesPerSecond:   15,
+		AverageFirstMessage: 100 * time.Millisecond,
+		P95FirstMessage:     100 * time.Millisecond,
+	}
+	if !reflect.DeepEqual(got.GRPC, want) {
+		t.Errorf("CalculateMetrics() gRPC = %+v, want %+v", got.GRPC, want)
+	}
+
+	if got := CalculateMetrics([]RequestResult{successfulRequest(time.Second)}); got.GRPC != nil {
+		t.Errorf("CalculateMetrics() gRPC = %+v, want nil without calls", got.GRPC)
+	}
+}
+
+func TestCalculateMetricsGraphQL(t *testing.T) {
+	start := time.Now()
+	request := func(operation string, statusCode, graphQLErrors int) RequestResult {
+		return RequestResult{StatusCode: statusCode, ResponseTime: 10 * time.Millisecond, StartTime: start, GraphQL: true, Operation: operation, GraphQLErrors: graphQLErrors}
+	}
+
+	// A 200 response carrying errors is a failure, as is a 400 one
+	results := []RequestResult{
+		request("GetUser", 200, 0),
+		request("GetUser", 200, 2),
+		request("GetUser", 200, 0),
+		request("CreateOrder", 400, 1),
+		request("CreateOrder", 500, 0),
+		request("CreateOrder", 200, 0),
+	}
+
+	got := CalculateMetrics(results)
+	if got.SuccessRequests != 3 || got.FailedRequests != 3 {
+		t.Errorf("CalculateMetrics() successful = %d, failed = %d, want 3 and 3", got.SuccessRequests, got.FailedRequests)
+	}
+	want := &GraphQLMetrics{Requests: 6, ErrorResponses: 2, Errors: 3, HiddenErrors: 1}
+	if !reflect.DeepEqual(got.GraphQL, want) {
+		t.Errorf("CalculateMetrics() GraphQL = %+v, want %+v", got.GraphQL, want)
+	}
+
+	if len(got.Operations) != 2 {
+		t.Fatalf("CalculateMetrics() operations = %d, want 2", len(got.Operations))
+	}
+	for i, want := range []struct {
+		name     string
+		requests int
+		failed   int
+	}{
+		{name: "CreateOrder", requests: 3, failed: 2},
+		{name: "GetUser", requests: 3, failed: 1},
+	} {
+		operation := got.Operations[i]
+		if operation.Name != want.name || operation.Metrics.TotalRequests != want.requests || operation.Metrics.FailedRequests != want.failed {
+			t.Errorf("CalculateMetrics() operation %d = %s with %d requests, %d failed, want %s with %d, %d failed", i, operation.Name, operation.Metrics.TotalRequests, operation.Metrics.FailedRequests, want.name, want.requests, want.failed)
+		}
+	}
+
+	if got := CalculateMetrics([]RequestResult{successfulRequest(time.Second)}); got.GraphQL != nil || got.Operations != nil {
+		t.Errorf("CalculateMetrics() GraphQL = %+v, operations = %v, want none without GraphQL requests", got.GraphQL, got.Operations)
+	}
+}
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name   string
+		result RequestResult
+		want   string
+	}{
+		{name: "success", result: successfulRequest(time.Second), want: ""},
+		{name: "server error", result: failedRequest(), want: ErrorClassStatus},
+		{name: "client timeout", result: RequestResult{Error: errors.New("error making request: Get \"http://localhost\": context deadline exceeded")}, want: ErrorClassTimeout},
+		{name: "read timeout", result: RequestResult{Error: errors.New("error reading response body: Client.Timeout or context cancellation while reading body")}, want: ErrorClassTimeout},
+		{name: "connection refused", result: RequestResult{Error: errors.New("error making request: dial tcp: connection refused")}, want: ErrorClassError},
+		{name: "GraphQL errors", result: RequestResult{StatusCode: 200, GraphQL: true, GraphQLErrors: 1}, want: ErrorClassGraphQL},
+		{name: "gRPC status", result: RequestResult{StatusCode: 14, GRPC: true, GRPCStatus: "Unavailable"}, want: ErrorClassStatus},
+		{name: "class set by the executor", result: RequestResult{StatusCode: 200, ErrorClass: "stale_read"}, want: "stale_read"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.result); got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// A class set by the executor fails a request whatever its status code
+	got := CalculateMetrics([]RequestResult{successfulRequest(time.Second), {StatusCode: 200, ErrorClass: "stale_read"}})
+	if got.FailedRequests != 1 || got.ErrorClasses["stale_read"] != 1 {
+		t.Errorf("CalculateMetrics() failed = %d, error classes = %v, want 1 stale_read", got.FailedRequests, got.ErrorClasses)
+	}
+}
@@ -0,0 +1,128 @@
+package replay
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Entry is a single request read from an access log
+type Entry struct {
+	Time   time.Time `json:"time"`
+	Method string    `json:"method"`
+	Path   string    `json:"path"`
+	Body   string    `json:"body,omitempty"`
+}
+
+// commonLogPattern matches the Apache/Nginx common log format. The combined
+// format only adds the referer and user agent after these fields.
+var commonLogPattern = regexp.MustCompile(`^\S+ \S+ \S+ \[([^\]]+)\] "(\S+) (\S+)(?: [^"]*)?" \d{3} \S+`)
+
+const commonLogTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// ReadLogFile reads the entries of a log file in the given format ("common",
+// "combined" or "jsonl"), along with the number of lines skipped as ParseLog does.
+func ReadLogFile(path, format string) ([]Entry, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error opening log file: %v", err)
+	}
+	defer file.Close()
+
+	return ParseLog(file, format)
+}
+
+// ParseLog parses the entries of a log and sorts them by time. Lines that are
+// not requests in the format, such as the "-" request line a web server logs
+// for a connection closed before it sent anything, are skipped and counted.
+func ParseLog(r io.Reader, format string) ([]Entry, int, error) {
+	var parseLine func(string) (Entry, error)
+	switch format {
+	case "common", "combined":
+		parseLine = parseCommonLogLine
+	case "jsonl":
+		parseLine = parseJSONLine
+	default:
+		return nil, 0, fmt.Errorf("'%s' is not a valid log format. Supported formats are: common, combined, jsonl", format)
+	}
+
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024) // JSONL lines may carry large bodies
+	skipped := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		entry, err := parseLine(line)
+		if err != nil {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("error reading log: %v", err)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Time.Before(entries[j].Time) })
+	return entries, skipped, nil
+}
+
+func parseCommonLogLine(line string) (Entry, error) {
+	match := commonLogPattern.FindStringSubmatch(line)
+	if match == nil {
+		return Entry{}, fmt.Errorf("not in common log format")
+	}
+
+	timestamp, err := time.Parse(commonLogTimeLayout, match[1])
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid timestamp: %v", err)
+	}
+
+	return Entry{Time: timestamp, Method: match[2], Path: match[3]}, nil
+}
+
+func parseJSONLine(line string) (Entry, error) {
+	var entry Entry
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return Entry{}, fmt.Errorf("invalid JSON: %v", err)
+	}
+	if entry.Time.IsZero() || entry.Path == "" {
+		return Entry{}, fmt.Errorf("time and path are required")
+	}
+	if entry.Method == "" {
+		entry.Method = "GET"
+	}
+	return entry, nil
+}
+
+// Filter keeps the entries whose path matches include and does not match exclude.
+// Either pattern may be nil.
+func Filter(entries []Entry, include, exclude *regexp.Regexp) []Entry {
+	filtered := make([]Entry, 0, len(entries))
+	for _, entry := range entries {
+		if include != nil && !include.MatchString(entry.Path) {
+			continue
+		}
+		if exclude != nil && exclude.MatchString(entry.Path) {
+			continue
+		}
+		filtered = append(filtered, entry)
+	}
+	return filtered
+}
+
+// Offset returns when the entry should be replayed relative to the first entry,
+// with the time scaled by the given speed factor.
+func Offset(first, entry Entry, speed float64) time.Duration {
+	return time.Duration(float64(entry.Time.Sub(first.Time)) / speed)
+}
@@ -0,0 +1,112 @@
+package replay
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseLog(t *testing.T) {
+	t.Run("combined format", func(t *testing.T) {
+		log := `10.0.0.2 - - [10/Oct/2023:13:55:37 +0000] "POST /posts HTTP/1.1" 201 53 "-" "curl/8.0"
+10.0.0.1 - frank [10/Oct/2023:13:55:36 +0000] "GET /posts?page=2 HTTP/1.1" 200 2326 "http://example.com/" "Mozilla/5.0"
+`
+		entries, skipped, err := ParseLog(strings.NewReader(log), "combined")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entries) != 2 || skipped != 0 {
+			t.Fatalf("expected 2 entries and none skipped, got %d and %d skipped", len(entries), skipped)
+		}
+
+		// Entries are sorted by time
+		if entries[0].Method != "GET" || entries[0].Path != "/posts?page=2" {
+			t.Errorf("unexpected first entry %+v", entries[0])
+		}
+		if entries[1].Method != "POST" || entries[1].Path != "/posts" {
+			t.Errorf("unexpected second entry %+v", entries[1])
+		}
+		if got := entries[1].Time.Sub(entries[0].Time); got != time.Second {
+			t.Errorf("expected entries to be 1s apart, got %s", got)
+		}
+	})
+
+	t.Run("jsonl format", func(t *testing.T) {
+		log := `{"time": "2023-10-10T13:55:36Z", "method": "PUT", "path": "/posts/2", "body": "{\"id\": 2}"}
+{"time": "2023-10-10T13:55:36.5Z", "path": "/posts"}
+`
+		entries, _, err := ParseLog(strings.NewReader(log), "jsonl")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("expected 2 entries, got %d", len(entries))
+		}
+		if entries[0].Body != `{"id": 2}` {
+			t.Errorf("expected body to be kept, got %q", entries[0].Body)
+		}
+		if entries[1].Method != "GET" {
+			t.Errorf("expected method to default to GET, got %q", entries[1].Method)
+		}
+	})
+
+	t.Run("lines that are not requests", func(t *testing.T) {
+		// Servers log a "-" request for connections closed before a request was sent
+		log := `10.0.0.1 - - [10/Oct/2023:13:55:36 +0000] "GET /posts HTTP/1.1" 200 2326 "-" "curl/8.0"
+10.0.0.3 - - [10/Oct/2023:13:55:37 +0000] "-" 400 0 "-" "-"
+10.0.0.4 - - [10/Oct/2023:13:55:37 +0000] "\x16\x03\x01\x02\x00\x01" 400 157 "-" "-"
+not a log line
+10.0.0.2 - - [10/Oct/2023:13:55:38 +0000] "POST /posts HTTP/1.1" 201 53 "-" "curl/8.0"
+`
+		entries, skipped, err := ParseLog(strings.NewReader(log), "combined")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entries) != 2 || skipped != 3 {
+			t.Fatalf("expected 2 entries and 3 skipped, got %d and %d skipped", len(entries), skipped)
+		}
+		if entries[0].Path != "/posts" || entries[1].Method != "POST" {
+			t.Errorf("unexpected entries %+v", entries)
+		}
+	})
+
+	t.Run("invalid JSON line", func(t *testing.T) {
+		log := `{"time": "2023-10-10T13:55:36Z", "path": "/posts"}
+{"time": "2023-10-10T13:55:37Z", "path":
+{"method": "GET"}
+`
+		entries, skipped, err := ParseLog(strings.NewReader(log), "jsonl")
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(entries) != 1 || skipped != 2 {
+			t.Errorf("expected 1 entry and 2 skipped, got %d and %d skipped", len(entries), skipped)
+		}
+	})
+
+	t.Run("unknown format", func(t *testing.T) {
+		if _, _, err := ParseLog(strings.NewReader(""), "xml"); err == nil {
+			t.Error("expected an error for an unknown format")
+		}
+	})
+}
+
+func TestFilter(t *testing.T) {
+	entries := []Entry{{Path: "/api/posts"}, {Path: "/api/logo.png"}, {Path: "/health"}}
+
+	got := Filter(entries, regexp.MustCompile(`^/api/`), regexp.MustCompile(`\.png$`))
+	if len(got) != 1 || got[0].Path != "/api/posts" {
+		t.Errorf("Filter() = %+v, want only /api/posts", got)
+	}
+}
+
+func TestOffset(t *testing.T) {
+	start := time.Now()
+	first := Entry{Time: start}
+	entry := Entry{Time: start.Add(10 * time.Second)}
+
+	if got := Offset(first, entry, 2); got != 5*time.Second {
+		t.Errorf("Offset() at 2x speed = %s, want 5s", got)
+	}
+}
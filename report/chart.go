@@ -0,0 +1,86 @@
+package report
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"strings"
+)
+
+const (
+	chartWidth   = 800
+	chartHeight  = 300
+	chartPadding = 50
+)
+
+// chartSeries is a single line drawn on a chart
+type chartSeries struct {
+	Name   string
+	Color  string
+	Points []chartPoint
+}
+
+type chartPoint struct {
+	X, Y float64
+}
+
+// chartBand is a shaded range along the x axis, e.g. the warm-up phase
+type chartBand struct {
+	Label string
+	From  float64
+	To    float64
+	Color string
+}
+
+// lineChart renders the series as an inline SVG line chart.
+func lineChart(xLabel, yLabel string, series []chartSeries, bands []chartBand) template.HTML {
+	maxX, maxY := 0.0, 0.0
+	for _, s := range series {
+		for _, p := range s.Points {
+			maxX = math.Max(maxX, p.X)
+			maxY = math.Max(maxY, p.Y)
+		}
+	}
+	for _, b := range bands {
+		maxX = math.Max(maxX, b.To)
+	}
+	if maxX == 0 {
+		maxX = 1
+	}
+	if maxY == 0 {
+		maxY = 1
+	}
+
+	plotWidth := float64(chartWidth - 2*chartPadding)
+	plotHeight := float64(chartHeight - 2*chartPadding)
+	scaleX := func(x float64) float64 { return chartPadding + x/maxX*plotWidth }
+	scaleY := func(y float64) float64 { return chartHeight - chartPadding - y/maxY*plotHeight }
+
+	var svg strings.Builder
+	fmt.Fprintf(&svg, `<svg width="%d" height="%d" xmlns="http://www.w3.org/2000/svg">`, chartWidth, chartHeight)
+
+	for _, b := range bands {
+		fmt.Fprintf(&svg, `<rect x="%.1f" y="%d" width="%.1f" height="%.1f" fill="%s" opacity="0.3"><title>%s</title></rect>`,
+			scaleX(b.From), chartPadding, scaleX(b.To)-scaleX(b.From), plotHeight, b.Color, template.HTMLEscapeString(b.Label))
+	}
+
+	// Axes and labels
+	fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`, chartPadding, chartHeight-chartPadding, chartWidth-chartPadding, chartHeight-chartPadding)
+	fmt.Fprintf(&svg, `<line x1="%d" y1="%d" x2="%d" y2="%d" stroke="black"/>`, chartPadding, chartPadding, chartPadding, chartHeight-chartPadding)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="middle" font-size="12">%s</text>`, chartWidth/2, chartHeight-10, template.HTMLEscapeString(xLabel))
+	fmt.Fprintf(&svg, `<text x="15" y="%d" text-anchor="middle" font-size="12" transform="rotate(-90 15 %d)">%s</text>`, chartHeight/2, chartHeight/2, template.HTMLEscapeString(yLabel))
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end" font-size="10">%.4g</text>`, chartPadding-5, chartPadding+4, maxY)
+	fmt.Fprintf(&svg, `<text x="%d" y="%d" text-anchor="end" font-size="10">%.4g</text>`, chartWidth-chartPadding, chartHeight-chartPadding+15, maxX)
+
+	for i, s := range series {
+		points := make([]string, len(s.Points))
+		for j, p := range s.Points {
+			points[j] = fmt.Sprintf("%.1f,%.1f", scaleX(p.X), scaleY(p.Y))
+		}
+		fmt.Fprintf(&svg, `<polyline fill="none" stroke="%s" stroke-width="2" points="%s"/>`, s.Color, strings.Join(points, " "))
+		fmt.Fprintf(&svg, `<text x="%d" y="%d" font-size="12" fill="%s">%s</text>`, chartPadding+10, chartPadding-10-15*i, s.Color, template.HTMLEscapeString(s.Name))
+	}
+
+	svg.WriteString(`</svg>`)
+	return template.HTML(svg.String())
+}
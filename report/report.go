@@ -6,10 +6,12 @@ import (
 	"html/template"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"github.com/komuvill/api_benchmarker/benchmark"
 	"github.com/komuvill/api_benchmarker/metrics"
+	"github.com/komuvill/api_benchmarker/workload"
 )
 
 //go:embed report_template.html
@@ -21,15 +23,52 @@ type ReportData struct {
 	StartTime        string
 	AggregateMetrics metrics.AggregateMetrics
 	RequestResults   []metrics.RequestResult
+	TimeSeriesChart  template.HTML
+	ConcurrencyChart template.HTML
+	ConnectionChart  template.HTML
+	Targets          []TargetRow
+	Endpoints        []EndpointRow
 }
 
+// TargetRow is a row of the per-target comparison. A target is marked slow
+// when its p95 is well above the median p95 of all targets.
+type TargetRow struct {
+	metrics.GroupMetrics
+	Slow bool
+}
+
+// EndpointRow is a row of the per-endpoint breakdown. The share of the
+// requests an endpoint got is shown next to the share its weight asked for.
+type EndpointRow struct {
+	metrics.GroupMetrics
+	ExpectedShare float64 // percent
+	Share         float64 // percent
+}
+
+// slowTargetFactor is how far above the median p95 a target is marked slow
+const slowTargetFactor = 1.5
+
 func GenerateHTMLReport(config benchmark.BenchmarkConfig, aggregateMetrics metrics.AggregateMetrics, requestResults []metrics.RequestResult, startTime time.Time, outputDir string) error {
+	// The charts are drawn from all results so the time axis starts with the run,
+	// but warm-up requests are only shown when asked for
+	buckets := metrics.TimeSeries(requestResults, time.Second)
+	if !config.ReportWarmup {
+		requestResults = withoutWarmup(requestResults)
+	}
+
 	// Create a ReportData struct with all necessary data
 	data := ReportData{
 		Config:           config,
 		StartTime:        startTime.Format(time.RFC1123), // Format the start time as a string
 		AggregateMetrics: aggregateMetrics,
 		RequestResults:   requestResults,
+		TimeSeriesChart:  timeSeriesChart(buckets, config),
+		ConnectionChart:  connectionChart(buckets, config),
+		Targets:          targetRows(aggregateMetrics.Targets),
+		Endpoints:        endpointRows(aggregateMetrics, config.Workload),
+	}
+	if config.AdaptiveMode != "" {
+		data.ConcurrencyChart = concurrencyChart(buckets, config)
 	}
 
 	// Define name and output path for the report
@@ -58,3 +97,130 @@ func GenerateHTMLReport(config benchmark.BenchmarkConfig, aggregateMetrics metri
 
 	return nil
 }
+
+// withoutWarmup filters out the requests sent during the warm-up phase
+func withoutWarmup(results []metrics.RequestResult) []metrics.RequestResult {
+	filtered := make([]metrics.RequestResult, 0, len(results))
+	for _, result := range results {
+		if !result.Warmup {
+			filtered = append(filtered, result)
+		}
+	}
+	return filtered
+}
+
+// targetRows marks the targets whose p95 stands out from the rest
+func targetRows(targets []metrics.GroupMetrics) []TargetRow {
+	if len(targets) == 0 {
+		return nil
+	}
+
+	p95s := make([]time.Duration, len(targets))
+	for i, target := range targets {
+		p95s[i] = target.Metrics.P95Response
+	}
+	sort.Slice(p95s, func(i, j int) bool { return p95s[i] < p95s[j] })
+	median := metrics.Percentile(p95s, 50)
+
+	rows := make([]TargetRow, len(targets))
+	for i, target := range targets {
+		rows[i] = TargetRow{
+			GroupMetrics: target,
+			Slow:         len(targets) > 1 && float64(target.Metrics.P95Response) > slowTargetFactor*float64(median),
+		}
+	}
+	return rows
+}
+
+// endpointRows lists the endpoints in the order of the workload
+func endpointRows(aggregateMetrics metrics.AggregateMetrics, endpoints []workload.Endpoint) []EndpointRow {
+	if len(aggregateMetrics.Endpoints) == 0 {
+		return nil
+	}
+
+	byName := map[string]metrics.GroupMetrics{}
+	for _, group := range aggregateMetrics.Endpoints {
+		byName[group.Name] = group
+	}
+
+	var rows []EndpointRow
+	for i, share := range workload.Shares(endpoints) {
+		group, ok := byName[endpoints[i].Name]
+		if !ok {
+			group = metrics.GroupMetrics{Name: endpoints[i].Name}
+		}
+		row := EndpointRow{GroupMetrics: group, ExpectedShare: share * 100}
+		if aggregateMetrics.TotalRequests > 0 {
+			row.Share = float64(group.Metrics.TotalRequests) / float64(aggregateMetrics.TotalRequests) * 100
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// timeSeriesChart plots the average response time for every second of the test
+// with the warm-up phase and any traffic spikes shaded
+func timeSeriesChart(buckets []metrics.TimeBucket, config benchmark.BenchmarkConfig) template.HTML {
+	latency := chartSeries{Name: "Average response time (ms)", Color: "steelblue"}
+	var bands []chartBand
+	for _, bucket := range buckets {
+		if bucket.Warmup && !config.ReportWarmup {
+			continue
+		}
+		second := bucket.Offset.Seconds()
+		latency.Points = append(latency.Points, chartPoint{X: second, Y: float64(bucket.AverageResponse) / float64(time.Millisecond)})
+		if bucket.Warmup {
+			bands = append(bands, chartBand{Label: "Warm-up", From: second, To: second + 1, Color: "orange"})
+		}
+	}
+	if len(latency.Points) == 0 {
+		return ""
+	}
+
+	runLength := time.Duration(len(buckets)) * time.Second
+	for _, spike := range config.Burst.Windows(runLength) {
+		label := fmt.Sprintf("Spike %gx", config.Burst.Multiplier)
+		bands = append(bands, chartBand{Label: label, From: spike.Start.Seconds(), To: spike.End.Seconds(), Color: "red"})
+	}
+
+	return lineChart("Time since start (s)", "Response time (ms)", []chartSeries{latency}, bands)
+}
+
+// concurrencyChart plots the concurrency limit chosen by the adaptive controller over time
+func concurrencyChart(buckets []metrics.TimeBucket, config benchmark.BenchmarkConfig) template.HTML {
+	concurrency := chartSeries{Name: "Concurrency limit", Color: "seagreen"}
+	for _, bucket := range buckets {
+		if bucket.Warmup && !config.ReportWarmup {
+			continue
+		}
+		if bucket.Requests > 0 {
+			concurrency.Points = append(concurrency.Points, chartPoint{X: bucket.Offset.Seconds(), Y: float64(bucket.Concurrency)})
+		}
+	}
+
+	if len(concurrency.Points) == 0 {
+		return ""
+	}
+
+	return lineChart("Time since start (s)", "Concurrent requests", []chartSeries{concurrency}, nil)
+}
+
+// connectionChart plots the requests and newly opened connections per second.
+// When the two lines are close, the server is closing keep-alive connections.
+func connectionChart(buckets []metrics.TimeBucket, config benchmark.BenchmarkConfig) template.HTML {
+	requests := chartSeries{Name: "Requests per second", Color: "steelblue"}
+	connections := chartSeries{Name: "New connections per second", Color: "darkorange"}
+	for _, bucket := range buckets {
+		if bucket.Warmup && !config.ReportWarmup {
+			continue
+		}
+		second := bucket.Offset.Seconds()
+		requests.Points = append(requests.Points, chartPoint{X: second, Y: float64(bucket.Requests)})
+		connections.Points = append(connections.Points, chartPoint{X: second, Y: float64(bucket.NewConnections)})
+	}
+	if len(requests.Points) == 0 {
+		return ""
+	}
+
+	return lineChart("Time since start (s)", "Per second", []chartSeries{requests, connections}, nil)
+}
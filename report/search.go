@@ -0,0 +1,80 @@
+package report
+
+import (
+	"embed"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/komuvill/api_benchmarker/benchmark"
+)
+
+//go:embed search_report_template.html
+var searchReportTemplate embed.FS
+
+// SearchReportData holds all the data necessary for the capacity search report
+type SearchReportData struct {
+	Config    benchmark.BenchmarkConfig
+	Search    benchmark.SearchConfig
+	StartTime string
+	Result    benchmark.SearchResult
+	Curve     template.HTML
+}
+
+func GenerateSearchReport(config benchmark.BenchmarkConfig, search benchmark.SearchConfig, result benchmark.SearchResult, startTime time.Time, outputDir string) error {
+	data := SearchReportData{
+		Config:    config,
+		Search:    search,
+		StartTime: startTime.Format(time.RFC1123),
+		Result:    result,
+		Curve:     searchCurve(search, result),
+	}
+
+	filenameTimestamp := startTime.Format("020106-150405") // DDMMYY-HHMMSS format
+	fileName := fmt.Sprintf("%s_search_report.html", filenameTimestamp)
+	filePath := filepath.Join(outputDir, fileName)
+
+	tmpl, err := template.ParseFS(searchReportTemplate, "search_report_template.html")
+	if err != nil {
+		return fmt.Errorf("error parsing template: %w", err)
+	}
+
+	outputFile, err := os.Create(filePath)
+	if err != nil {
+		return fmt.Errorf("error creating report file: %w", err)
+	}
+	defer outputFile.Close()
+
+	err = tmpl.Execute(outputFile, data)
+	if err != nil {
+		return fmt.Errorf("error executing template: %w", err)
+	}
+
+	return nil
+}
+
+// searchCurve plots the response time percentile against the throughput of every search step
+func searchCurve(search benchmark.SearchConfig, result benchmark.SearchResult) template.HTML {
+	if len(result.Steps) == 0 {
+		return ""
+	}
+
+	curve := chartSeries{Name: fmt.Sprintf("p%g response time (ms)", search.LatencyPercentile), Color: "steelblue"}
+	for _, step := range result.Steps {
+		curve.Points = append(curve.Points, chartPoint{
+			X: step.Metrics.RequestsPerSecond,
+			Y: float64(step.Latency) / float64(time.Millisecond),
+		})
+	}
+
+	var bands []chartBand
+	if result.KneeFound {
+		// Mark the knee point with a narrow band at its throughput
+		knee := result.Knee.Metrics.RequestsPerSecond
+		bands = append(bands, chartBand{Label: "Knee point", From: knee * 0.99, To: knee * 1.01, Color: "red"})
+	}
+
+	return lineChart("Throughput (requests/s)", "Response time (ms)", []chartSeries{curve}, bands)
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/komuvill/api_benchmarker/benchmark"
 	"github.com/komuvill/api_benchmarker/metrics"
 )
 
@@ -22,7 +23,86 @@ type RequestResultForStorage struct {
 	Response     string        `json:"response"`
 	StatusCode   int           `json:"status_code"`
 	ResponseTime time.Duration `json:"response_time"`
+	StartTime    time.Time     `json:"start_time"`
 	Error        string        `json:"error,omitempty"`
+	Warmup       bool          `json:"warmup,omitempty"`
+	Protocol     string        `json:"protocol,omitempty"`
+	TLSVersion   string        `json:"tls_version,omitempty"`
+	CipherSuite  string        `json:"cipher_suite,omitempty"`
+
+	ErrorClass string            `json:"error_class,omitempty"`
+	Tags       map[string]string `json:"tags,omitempty"`
+
+	ConnectionID     int  `json:"connection_id,omitempty"`
+	ConnectionReused bool `json:"connection_reused"`
+
+	Phases PhaseTimingsForStorage `json:"phases"`
+
+	BodySize  int64  `json:"body_size"`
+	BodyHash  string `json:"body_hash,omitempty"`
+	Oversized bool   `json:"oversized,omitempty"`
+
+	ContentEncoding string        `json:"content_encoding,omitempty"`
+	ReceivedBytes   int64         `json:"received_bytes"`
+	RequestBodySize int64         `json:"request_body_size"`
+	SentBytes       int64         `json:"sent_bytes"`
+	UploadTime      time.Duration `json:"upload_time,omitempty"`
+
+	Target   string `json:"target,omitempty"`
+	Endpoint string `json:"endpoint,omitempty"`
+
+	Redirects int                     `json:"redirects,omitempty"`
+	Hops      []RedirectHopForStorage `json:"hops,omitempty"`
+	Hop       int                     `json:"hop,omitempty"`
+	HopURL    string                  `json:"hop_url,omitempty"`
+	Redirect  bool                    `json:"redirect,omitempty"`
+
+	TokenWait  time.Duration `json:"token_wait,omitempty"`
+	TokenFetch bool          `json:"token_fetch,omitempty"`
+
+	Stream       bool            `json:"stream,omitempty"`
+	Reconnect    bool            `json:"reconnect,omitempty"`
+	Events       int             `json:"events,omitempty"`
+	FirstEvent   time.Duration   `json:"first_event,omitempty"`
+	EventGaps    []time.Duration `json:"event_gaps,omitempty"`
+	StreamTime   time.Duration   `json:"stream_time,omitempty"`
+	Disconnected bool            `json:"disconnected,omitempty"`
+
+	WebSocket        bool            `json:"websocket,omitempty"`
+	MessagesSent     int             `json:"messages_sent,omitempty"`
+	MessagesReceived int             `json:"messages_received,omitempty"`
+	RoundTrips       []time.Duration `json:"round_trips,omitempty"`
+	Unanswered       int             `json:"unanswered,omitempty"`
+	CloseCode        int             `json:"close_code,omitempty"`
+	AbnormalClose    bool            `json:"abnormal_close,omitempty"`
+
+	GRPC           bool          `json:"grpc,omitempty"`
+	GRPCStatus     string        `json:"grpc_status,omitempty"`
+	Streaming      bool          `json:"streaming,omitempty"`
+	StreamMessages int           `json:"stream_messages,omitempty"`
+	FirstMessage   time.Duration `json:"first_message,omitempty"`
+
+	GraphQL       bool   `json:"graphql,omitempty"`
+	Operation     string `json:"operation,omitempty"`
+	GraphQLErrors int    `json:"graphql_errors,omitempty"`
+	GraphQLError  string `json:"graphql_error,omitempty"`
+}
+
+// RedirectHopForStorage is RedirectHop with JSON field names
+type RedirectHopForStorage struct {
+	URL          string        `json:"url"`
+	StatusCode   int           `json:"status_code"`
+	StartTime    time.Time     `json:"start_time"`
+	ResponseTime time.Duration `json:"response_time"`
+}
+
+// PhaseTimingsForStorage is PhaseTimings with JSON field names
+type PhaseTimingsForStorage struct {
+	DNS             time.Duration `json:"dns"`
+	Connect         time.Duration `json:"connect"`
+	Proxy           time.Duration `json:"proxy"`
+	TLS             time.Duration `json:"tls"`
+	TimeToFirstByte time.Duration `json:"time_to_first_byte"`
 }
 
 // ConvertRequestResults prepares a slice of RequestResult for storage by converting the Error field.
@@ -34,7 +114,71 @@ func ConvertRequestResults(results []metrics.RequestResult) []RequestResultForSt
 			Response:     result.Response,
 			StatusCode:   result.StatusCode,
 			ResponseTime: result.ResponseTime,
+			StartTime:    result.StartTime,
 			Error:        "", // Default empty string if there's no error
+			Warmup:       result.Warmup,
+			Protocol:     result.Protocol,
+			TLSVersion:   result.TLSVersion,
+			CipherSuite:  result.CipherSuite,
+
+			ErrorClass: metrics.ClassifyError(result),
+			Tags:       result.Tags,
+
+			ConnectionID:     result.ConnectionID,
+			ConnectionReused: result.ConnectionReused,
+
+			Phases: PhaseTimingsForStorage(result.Phases),
+
+			BodySize:  result.BodySize,
+			BodyHash:  result.BodyHash,
+			Oversized: result.Oversized,
+
+			ContentEncoding: result.ContentEncoding,
+			ReceivedBytes:   result.ReceivedBytes,
+			RequestBodySize: result.RequestBodySize,
+			SentBytes:       result.SentBytes,
+			UploadTime:      result.UploadTime,
+
+			Target:   result.Target,
+			Endpoint: result.Endpoint,
+
+			Redirects: result.Redirects,
+			Hop:       result.Hop,
+			HopURL:    result.HopURL,
+			Redirect:  result.Redirect,
+
+			TokenWait:  result.TokenWait,
+			TokenFetch: result.TokenFetch,
+
+			Stream:       result.Stream,
+			Reconnect:    result.Reconnect,
+			Events:       result.Events,
+			FirstEvent:   result.FirstEvent,
+			EventGaps:    result.EventGaps,
+			StreamTime:   result.StreamTime,
+			Disconnected: result.Disconnected,
+
+			WebSocket:        result.WebSocket,
+			MessagesSent:     result.MessagesSent,
+			MessagesReceived: result.MessagesReceived,
+			RoundTrips:       result.RoundTrips,
+			Unanswered:       result.Unanswered,
+			CloseCode:        result.CloseCode,
+			AbnormalClose:    result.AbnormalClose,
+
+			GRPC:           result.GRPC,
+			GRPCStatus:     result.GRPCStatus,
+			Streaming:      result.Streaming,
+			StreamMessages: result.StreamMessages,
+			FirstMessage:   result.FirstMessage,
+
+			GraphQL:       result.GraphQL,
+			Operation:     result.Operation,
+			GraphQLErrors: result.GraphQLErrors,
+			GraphQLError:  result.GraphQLError,
+		}
+		for _, hop := range result.Hops {
+			storageResults[i].Hops = append(storageResults[i].Hops, RedirectHopForStorage(hop))
 		}
 		if result.Error != nil {
 			storageResults[i].Error = result.Error.Error() // Convert the error to a string
@@ -79,3 +223,47 @@ func SaveAggregatedMetrics(metrics metrics.AggregateMetrics, outputDir string) e
 	encoder.SetIndent("", "  ")
 	return encoder.Encode(metrics)
 }
+
+// SaveSearchResult serializes the steps and knee point of a capacity search to JSON and saves it to a file with a timestamp.
+func SaveSearchResult(result benchmark.SearchResult, outputDir string) error {
+	filename := generateTimestampedFilename("search")
+	filePath := filepath.Join(outputDir, filename)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// RunMetadata describes how a benchmark run was configured, including when the traffic spikes occurred.
+type RunMetadata struct {
+	Config    benchmark.BenchmarkConfig `json:"config"`
+	StartTime time.Time                 `json:"start_time"`
+	Spikes    []benchmark.SpikeWindow   `json:"spikes,omitempty"`
+}
+
+// SaveRunMetadata serializes the configuration and traffic pattern of a run to JSON and saves it to a file with a timestamp.
+func SaveRunMetadata(config benchmark.BenchmarkConfig, startTime time.Time, outputDir string) error {
+	runLength := config.WarmupDuration + time.Duration(config.Duration)*time.Second
+	metadata := RunMetadata{
+		Config:    config,
+		StartTime: startTime,
+		Spikes:    config.Burst.Windows(runLength),
+	}
+
+	filename := generateTimestampedFilename("metadata")
+	filePath := filepath.Join(outputDir, filename)
+	file, err := os.Create(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(metadata)
+}
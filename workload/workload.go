@@ -0,0 +1,112 @@
+package workload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Endpoint is a request template of a workload. Requests are sampled among
+// the endpoints in proportion to their weights.
+type Endpoint struct {
+	Name    string            `json:"name,omitempty"`
+	Method  string            `json:"method,omitempty"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    string            `json:"body,omitempty"` // Prefix with @ to point to a file
+	Weight  int               `json:"weight,omitempty"`
+}
+
+// Workload is the definition read from a workload file
+type Workload struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+// ReadFile reads the endpoints of a workload file.
+func ReadFile(path string) ([]Endpoint, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening workload file: %v", err)
+	}
+	defer file.Close()
+
+	return Parse(file)
+}
+
+// Parse parses a workload definition in JSON. The method defaults to GET,
+// the weight to 1 and the name to the method and path of the endpoint.
+func Parse(r io.Reader) ([]Endpoint, error) {
+	var workload Workload
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&workload); err != nil {
+		return nil, fmt.Errorf("error parsing workload: %v", err)
+	}
+	if len(workload.Endpoints) == 0 {
+		return nil, fmt.Errorf("the workload has no endpoints")
+	}
+
+	names := map[string]bool{}
+	for i := range workload.Endpoints {
+		endpoint := &workload.Endpoints[i]
+		if endpoint.Method == "" {
+			endpoint.Method = "GET"
+		}
+		endpoint.Method = strings.ToUpper(endpoint.Method)
+		if endpoint.Weight == 0 {
+			endpoint.Weight = 1
+		}
+		if endpoint.Name == "" {
+			endpoint.Name = endpoint.Method + " " + endpoint.Path
+		}
+
+		if err := validateEndpoint(*endpoint); err != nil {
+			return nil, fmt.Errorf("endpoint %d: %v", i+1, err)
+		}
+		if names[endpoint.Name] {
+			return nil, fmt.Errorf("endpoint %d: the name '%s' is used more than once", i+1, endpoint.Name)
+		}
+		names[endpoint.Name] = true
+	}
+
+	return workload.Endpoints, nil
+}
+
+func validateEndpoint(endpoint Endpoint) error {
+	switch endpoint.Method {
+	case "GET", "POST", "PUT", "DELETE":
+	default:
+		return fmt.Errorf("'%s' is not a valid HTTP method. Supported methods are: GET, POST, PUT, DELETE", endpoint.Method)
+	}
+	if !strings.HasPrefix(endpoint.Path, "/") {
+		return fmt.Errorf("the path must start with /, got '%s'", endpoint.Path)
+	}
+	if endpoint.Weight < 0 {
+		return fmt.Errorf("the weight cannot be negative")
+	}
+	if strings.HasPrefix(endpoint.Body, "@") {
+		filePath := strings.TrimPrefix(endpoint.Body, "@")
+		if _, err := os.Stat(filePath); os.IsNotExist(err) {
+			return fmt.Errorf("the file specified for the request body does not exist: %s", filePath)
+		}
+	}
+	return nil
+}
+
+// Shares returns the fraction of the requests each endpoint is expected to get
+func Shares(endpoints []Endpoint) []float64 {
+	total := 0
+	for _, endpoint := range endpoints {
+		total += endpoint.Weight
+	}
+
+	shares := make([]float64, len(endpoints))
+	for i, endpoint := range endpoints {
+		if total > 0 {
+			shares[i] = float64(endpoint.Weight) / float64(total)
+		}
+	}
+	return shares
+}
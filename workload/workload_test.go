@@ -0,0 +1,71 @@
+package workload
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("defaults", func(t *testing.T) {
+		definition := `{"endpoints": [
+			{"name": "list", "path": "/items", "weight": 70},
+			{"path": "/items/1", "weight": 20, "headers": {"Accept": "application/json"}},
+			{"method": "post", "path": "/items", "body": "{\"name\": \"x\"}", "weight": 10},
+			{"path": "/health"}
+		]}`
+		endpoints, err := Parse(strings.NewReader(definition))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if len(endpoints) != 4 {
+			t.Fatalf("expected 4 endpoints, got %d", len(endpoints))
+		}
+
+		if endpoints[0].Name != "list" || endpoints[0].Method != "GET" {
+			t.Errorf("unexpected first endpoint %+v", endpoints[0])
+		}
+		if endpoints[1].Name != "GET /items/1" || endpoints[1].Headers["Accept"] != "application/json" {
+			t.Errorf("unexpected second endpoint %+v", endpoints[1])
+		}
+		if endpoints[2].Name != "POST /items" || endpoints[2].Method != "POST" {
+			t.Errorf("unexpected third endpoint %+v", endpoints[2])
+		}
+		if endpoints[3].Weight != 1 {
+			t.Errorf("expected the weight to default to 1, got %d", endpoints[3].Weight)
+		}
+	})
+
+	tests := []struct {
+		name       string
+		definition string
+		errMsg     string
+	}{
+		{name: "no endpoints", definition: `{"endpoints": []}`, errMsg: "the workload has no endpoints"},
+		{name: "unknown field", definition: `{"endpoints": [{"path": "/", "wieght": 2}]}`, errMsg: "unknown field"},
+		{name: "invalid method", definition: `{"endpoints": [{"method": "TRACE", "path": "/"}]}`, errMsg: "endpoint 1: 'TRACE' is not a valid HTTP method"},
+		{name: "relative path", definition: `{"endpoints": [{"path": "items"}]}`, errMsg: "endpoint 1: the path must start with /"},
+		{name: "negative weight", definition: `{"endpoints": [{"path": "/", "weight": -1}]}`, errMsg: "endpoint 1: the weight cannot be negative"},
+		{name: "duplicate names", definition: `{"endpoints": [{"path": "/a"}, {"path": "/a"}]}`, errMsg: "endpoint 2: the name 'GET /a' is used more than once"},
+		{name: "missing body file", definition: `{"endpoints": [{"method": "POST", "path": "/", "body": "@/nonexistent.json"}]}`, errMsg: "does not exist"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(tt.definition))
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("Parse() error = %v, want %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestShares(t *testing.T) {
+	shares := Shares([]Endpoint{{Weight: 7}, {Weight: 2}, {Weight: 1}})
+	want := []float64{0.7, 0.2, 0.1}
+	for i := range want {
+		if math.Abs(shares[i]-want[i]) > 1e-9 {
+			t.Errorf("Shares()[%d] = %v, want %v", i, shares[i], want[i])
+		}
+	}
+}